@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"defibotgo/internal/competition"
+	"defibotgo/internal/models"
+	protocolconfig "defibotgo/internal/protocols/config"
+	"defibotgo/internal/web3"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// competitorsCommand is the subcommand summarizing the competition on the pools
+const competitorsCommand = "competitors"
+
+// runCompetitors prints, for every pool of the chain in the configuration file, who harvested it and how they bid.
+//
+// Parameters:
+// - ctx: the context of the on-chain scan
+// - args: the arguments following the subcommand
+//
+// Returns:
+// - error: non-nil if the arguments are invalid or the reinvests cannot be scanned
+func runCompetitors(ctx context.Context, args []string) error {
+	flags := flagSet(competitorsCommand)
+	configPath := flags.String("config", protocolconfig.DefaultPath, "Configuration file of the chains, wallets and pools")
+	chainStr := flags.String("chain", "", "Chain of the pools (required)")
+	poolStr := flags.String("pool", "", "Only summarize this pool (default every pool of the chain)")
+	blocks := flags.Uint64("blocks", competition.DefaultWindow, "Number of blocks scanned back from the latest one")
+	formatStr := flags.String("format", "text", "Output format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	format := strings.ToLower(*formatStr)
+	if format != "text" && format != "json" {
+		return fmt.Errorf("invalid format %q", *formatStr)
+	}
+
+	// The pools are only read, the private keys of their wallets are not required
+	chain := models.Chain(strings.ToUpper(*chainStr))
+	if _, ok := web3.ChainIDs[chain]; !ok {
+		return fmt.Errorf("invalid chain %q", *chainStr)
+	}
+	cfg, err := loadConfig(*configPath, protocolconfig.ChainSelection(chain, false))
+	if err != nil {
+		return err
+	}
+	pools := cfg.ChainPools(chain)
+	if len(pools) == 0 {
+		return fmt.Errorf("invalid chain %q", *chainStr)
+	}
+	pool := models.Pool(strings.ToUpper(*poolStr))
+
+	ethClient, err := web3.BuildWeb3Client(chain, true)
+	if err != nil {
+		return fmt.Errorf("failed to build eth client: %w", err)
+	}
+	ethClient.Start(ctx)
+	defer ethClient.Close()
+
+	toBlock, err := ethClient.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get block number: %w", err)
+	}
+	fromBlock := uint64(0)
+	if toBlock > *blocks {
+		fromBlock = toBlock - *blocks + 1
+	}
+
+	labels := competition.Labels(protocolconfig.KnownBots, cfg.Senders(chain)...)
+	var summaries []competition.Summary
+	for _, protocolOpts := range pools {
+		poolOpts := protocolOpts.Opts()
+		if pool != "" && poolOpts.Pool != pool {
+			continue
+		}
+		reinvests, err := competition.ScanReinvests(ctx, ethClient, competition.ScanQuery{Chain: chain, Pool: poolOpts.Pool, Lender: poolOpts.ContractLender, FromBlock: fromBlock, ToBlock: toBlock})
+		if err != nil {
+			return fmt.Errorf("failed to scan %s: %w", poolOpts.Pool, err)
+		}
+		summaries = append(summaries, competition.Summarize(chain, poolOpts.Pool, reinvests, labels))
+	}
+	if pool != "" && len(summaries) == 0 {
+		return fmt.Errorf("invalid pool %q", *poolStr)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Pool < summaries[j].Pool })
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summaries)
+	}
+	return printSummaries(os.Stdout, summaries)
+}
+
+// printSummaries writes the summaries as text tables, one per pool.
+func printSummaries(w io.Writer, summaries []competition.Summary) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, summary := range summaries {
+		ours := summary.Ours()
+		_, _ = fmt.Fprintf(table, "%s %s: %d reinvests from block %d to %d, median tip %s, our win rate %.1f%%\n",
+			summary.Chain, summary.Pool, summary.Reinvests, summary.FromBlock, summary.ToBlock, summary.MedianTip, ours.WinRate)
+		_, _ = fmt.Fprintln(table, "SENDER\tLABEL\tWINS\tWIN RATE\tMEDIAN TIP\tTIP PREMIUM\tMEDIAN GAS\tBOUNTY")
+		for _, sender := range summary.Senders {
+			_, _ = fmt.Fprintf(table, "%s\t%s\t%d\t%.1f%%\t%s\t%+.1f%%\t%d\t%s\n",
+				sender.Sender.Hex(), sender.Label, sender.Wins, sender.WinRate, sender.MedianTip, sender.TipPremium, sender.MedianGasUsed, sender.Bounty)
+		}
+		_, _ = fmt.Fprintln(table)
+	}
+	return table.Flush()
+}
@@ -0,0 +1,167 @@
+package accounting
+
+import (
+	"defibotgo/internal/competition"
+	"defibotgo/internal/models"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// DefaultLedgerPath is the database the ledger is stored in.
+const DefaultLedgerPath = "data/defibot.db"
+
+// Ledger persists the pool configs, the iteration decisions, the sent transactions and their realized harvests.
+// The records are written in the background, a record is never waited for by the harvest loop.
+type Ledger interface {
+	RecordPool(opts models.ProtocolOpts)
+	RecordIteration(iteration Iteration)
+	RecordTransaction(tx SentTransaction)
+	Record(harvest Harvest)
+	RecordReinvest(reinvest competition.Reinvest)
+	// Totals sums the harvests of a position, including the ones still being written
+	Totals(position Position) Totals
+}
+
+// Decision is the outcome of an iteration of the harvest loop.
+type Decision string
+
+const (
+	// DecisionUnworthy means the reward does not cover the L2 fee
+	DecisionUnworthy Decision = "UNWORTHY"
+	// DecisionUnprofitable means the reward does not cover the L2 and L1 fees
+	DecisionUnprofitable Decision = "UNPROFITABLE"
+	// DecisionReverted means the simulated reinvest reverted
+	DecisionReverted Decision = "REVERTED"
+	// DecisionSent means the reinvest was sent
+	DecisionSent Decision = "SENT"
+	// DecisionSendFailed means the reinvest could not be sent
+	DecisionSendFailed Decision = "SEND_FAILED"
+)
+
+// Iteration is the decision taken on a block.
+type Iteration struct {
+	Time        time.Time
+	Chain       models.Chain
+	Pool        models.Pool
+	Wallet      common.Address
+	BlockNumber uint64
+	Earned      *big.Int // The vault pending reward predicted for the next block
+	RewardEth   *big.Int // The reinvest bounty in ETH
+	L2Fee       *big.Int // The L2 fee quoted
+	L1Fee       *big.Int // The L1 fee quoted, nil when the L2 fee alone was not covered
+	Diff        float64  // The difference between the reward and the fees, in percent
+	Decision    Decision
+}
+
+// SentTransaction is a reinvest handed over for inclusion.
+type SentTransaction struct {
+	Time        time.Time
+	Chain       models.Chain
+	Pool        models.Pool
+	Wallet      common.Address
+	Hash        common.Hash
+	Nonce       uint64
+	TargetBlock uint64
+	GasTipCap   *big.Int
+	GasFeeCap   *big.Int
+	GasLimit    uint64
+}
+
+// Position identifies the harvests of a wallet on a pool.
+type Position struct {
+	Chain  models.Chain
+	Pool   models.Pool
+	Wallet common.Address
+}
+
+// Totals are the cumulated results of a position.
+type Totals struct {
+	Harvests    int
+	Failed      int
+	RewardToken *big.Int
+	RewardEth   *big.Int
+	Fee         *big.Int
+	ProfitEth   *big.Int
+}
+
+func newTotals() *Totals {
+	return &Totals{RewardToken: big.NewInt(0), RewardEth: big.NewInt(0), Fee: big.NewInt(0), ProfitEth: big.NewInt(0)}
+}
+
+func (t *Totals) add(harvest *Harvest) {
+	t.Harvests++
+	if !harvest.Success {
+		t.Failed++
+	}
+	t.RewardToken.Add(t.RewardToken, harvest.RewardToken)
+	t.RewardEth.Add(t.RewardEth, harvest.RewardEth)
+	t.Fee.Add(t.Fee, harvest.Fee())
+	t.ProfitEth.Add(t.ProfitEth, harvest.ProfitEth)
+}
+
+func (t *Totals) remove(harvest *Harvest) {
+	t.Harvests--
+	if !harvest.Success {
+		t.Failed--
+	}
+	t.RewardToken.Sub(t.RewardToken, harvest.RewardToken)
+	t.RewardEth.Sub(t.RewardEth, harvest.RewardEth)
+	t.Fee.Sub(t.Fee, harvest.Fee())
+	t.ProfitEth.Sub(t.ProfitEth, harvest.ProfitEth)
+}
+
+// totalsBook keeps the totals of every position in memory.
+type totalsBook struct {
+	mu       sync.Mutex
+	totals   map[Position]*Totals
+	harvests map[common.Hash]*Harvest // The harvest counted for each transaction, replaced when it is recorded again
+}
+
+func newTotalsBook() *totalsBook {
+	return &totalsBook{totals: map[Position]*Totals{}, harvests: map[common.Hash]*Harvest{}}
+}
+
+// add counts the harvest in the totals of its position. A harvest of a transaction already counted replaces it, as
+// its row does in the ledger.
+func (b *totalsBook) add(harvest *Harvest) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if previous, ok := b.harvests[harvest.TxHash]; ok {
+		b.position(previous).remove(previous)
+	}
+	b.harvests[harvest.TxHash] = harvest
+	b.position(harvest).add(harvest)
+}
+
+// position returns the totals of the position of the harvest, b.mu must be held.
+func (b *totalsBook) position(harvest *Harvest) *Totals {
+	position := Position{Chain: harvest.Chain, Pool: harvest.Pool, Wallet: harvest.Wallet}
+	totals, ok := b.totals[position]
+	if !ok {
+		totals = newTotals()
+		b.totals[position] = totals
+	}
+	return totals
+}
+
+// get returns a copy of the totals of a position, zero when it has no harvest.
+func (b *totalsBook) get(position Position) Totals {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	totals, ok := b.totals[position]
+	if !ok {
+		return *newTotals()
+	}
+	return Totals{
+		Harvests:    totals.Harvests,
+		Failed:      totals.Failed,
+		RewardToken: new(big.Int).Set(totals.RewardToken),
+		RewardEth:   new(big.Int).Set(totals.RewardEth),
+		Fee:         new(big.Int).Set(totals.Fee),
+		ProfitEth:   new(big.Int).Set(totals.ProfitEth),
+	}
+}
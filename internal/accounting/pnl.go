@@ -0,0 +1,76 @@
+package accounting
+
+import (
+	"defibotgo/internal/models"
+	"defibotgo/internal/utils"
+	"defibotgo/internal/web3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"time"
+)
+
+// Harvest is the realized result of a mined transaction of the bot, read from its receipt.
+type Harvest struct {
+	Time        time.Time      `json:"time"`
+	Chain       models.Chain   `json:"chain"`
+	Pool        models.Pool    `json:"pool"`
+	Wallet      common.Address `json:"wallet"`
+	TxHash      common.Hash    `json:"txHash"`
+	BlockNumber uint64         `json:"blockNumber"`
+	Success     bool           `json:"success"`
+
+	RewardToken *big.Int `json:"rewardToken"` // The reward tokens transferred to the wallet
+	RewardEth   *big.Int `json:"rewardEth"`   // RewardToken converted with the reward pair value
+	L2Fee       *big.Int `json:"l2Fee"`       // gasUsed * effectiveGasPrice
+	L1Fee       *big.Int `json:"l1Fee"`       // The L1 data fee of the OP stack
+	ProfitEth   *big.Int `json:"profitEth"`   // RewardEth - L2Fee - L1Fee
+
+	PredictedRewardEth *big.Int `json:"predictedRewardEth"` // The reward expected when the transaction was sent
+	PredictedFee       *big.Int `json:"predictedFee"`       // The L2 and L1 fees expected when the transaction was sent
+}
+
+// Fee returns the total fee paid, on L2 and on L1.
+func (h *Harvest) Fee() *big.Int {
+	return new(big.Int).Add(h.L2Fee, h.L1Fee)
+}
+
+// Realize computes the realized result of a mined transaction.
+//
+// The reward is the sum of the ERC-20 transfers to the wallet in the receipt logs, the fee is the L2 execution
+// fee plus the L1 data fee, both read from the receipt.
+//
+// Parameters:
+//   - receipt: The receipt of the transaction.
+//   - chain: The chain of the transaction.
+//   - pool: The pool harvested.
+//   - wallet: The sender of the transaction, receiving the reward.
+//   - rewardToken: The reward token, every token transferred to the wallet is counted when it is the zero address.
+//   - rewardPair: The value of the reward token in ETH, scaled by 1e18.
+//
+// Returns:
+//   - Harvest: The realized result, without the predicted values.
+func Realize(receipt *web3.OpReceipt, chain models.Chain, pool models.Pool, wallet common.Address, rewardToken common.Address, rewardPair *big.Int) Harvest {
+	harvest := Harvest{
+		Time:        time.Now().UTC(),
+		Chain:       chain,
+		Pool:        pool,
+		Wallet:      wallet,
+		TxHash:      receipt.TxHash,
+		Success:     uint64(receipt.Status) == types.ReceiptStatusSuccessful,
+		RewardToken: receipt.TransfersTo(wallet, rewardToken),
+		L2Fee:       receipt.L2Fee(),
+		L1Fee:       receipt.L1DataFee(),
+	}
+	if receipt.BlockNumber != nil {
+		harvest.BlockNumber = receipt.BlockNumber.ToInt().Uint64()
+	}
+
+	harvest.RewardEth = big.NewInt(0)
+	if rewardPair != nil {
+		harvest.RewardEth = utils.ConvertToEth(harvest.RewardToken, rewardPair)
+	}
+	harvest.ProfitEth = new(big.Int).Sub(harvest.RewardEth, harvest.Fee())
+
+	return harvest
+}
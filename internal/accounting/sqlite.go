@@ -0,0 +1,314 @@
+package accounting
+
+import (
+	"database/sql"
+	"defibotgo/internal/competition"
+	"defibotgo/internal/models"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+	"math/big"
+	_ "modernc.org/sqlite"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ledgerQueueSize is the number of records waiting to be written before new ones are dropped.
+const ledgerQueueSize = 1024
+
+const ledgerSchema = `
+CREATE TABLE IF NOT EXISTS pools (
+	chain      TEXT NOT NULL,
+	pool       TEXT NOT NULL,
+	wallet     TEXT NOT NULL,
+	lender     TEXT NOT NULL,
+	gauge      TEXT NOT NULL,
+	opts       TEXT NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (chain, pool, wallet)
+);
+CREATE TABLE IF NOT EXISTS iterations (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	time         TIMESTAMP NOT NULL,
+	chain        TEXT NOT NULL,
+	pool         TEXT NOT NULL,
+	wallet       TEXT NOT NULL,
+	block_number INTEGER NOT NULL,
+	earned       TEXT NOT NULL,
+	reward_eth   TEXT NOT NULL,
+	l2_fee       TEXT NOT NULL,
+	l1_fee       TEXT,
+	diff         REAL NOT NULL,
+	decision     TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS iterations_pool ON iterations (chain, pool, wallet, block_number);
+CREATE TABLE IF NOT EXISTS transactions (
+	hash         TEXT PRIMARY KEY,
+	time         TIMESTAMP NOT NULL,
+	chain        TEXT NOT NULL,
+	pool         TEXT NOT NULL,
+	wallet       TEXT NOT NULL,
+	nonce        INTEGER NOT NULL,
+	target_block INTEGER NOT NULL,
+	gas_tip_cap  TEXT NOT NULL,
+	gas_fee_cap  TEXT NOT NULL,
+	gas_limit    INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS harvests (
+	tx_hash              TEXT PRIMARY KEY,
+	time                 TIMESTAMP NOT NULL,
+	chain                TEXT NOT NULL,
+	pool                 TEXT NOT NULL,
+	wallet               TEXT NOT NULL,
+	block_number         INTEGER NOT NULL,
+	success              BOOLEAN NOT NULL,
+	reward_token         TEXT NOT NULL,
+	reward_eth           TEXT NOT NULL,
+	l2_fee               TEXT NOT NULL,
+	l1_fee               TEXT NOT NULL,
+	profit_eth           TEXT NOT NULL,
+	predicted_reward_eth TEXT,
+	predicted_fee        TEXT
+);
+CREATE TABLE IF NOT EXISTS reinvests (
+	tx_hash      TEXT PRIMARY KEY,
+	time         TIMESTAMP NOT NULL,
+	chain        TEXT NOT NULL,
+	pool         TEXT NOT NULL,
+	lender       TEXT NOT NULL,
+	sender       TEXT NOT NULL,
+	block_number INTEGER NOT NULL,
+	reward       TEXT NOT NULL,
+	bounty       TEXT NOT NULL,
+	gas_used     INTEGER NOT NULL,
+	tip          TEXT NOT NULL,
+	l2_fee       TEXT NOT NULL,
+	l1_fee       TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS reinvests_pool ON reinvests (chain, pool, block_number);
+`
+
+// SqliteLedger stores the ledger in an embedded SQLite database.
+//
+// The records are queued and written by a single goroutine, a record is dropped with a warning when the
+// queue is full. The totals are kept in memory, restored from the harvests table on open.
+type SqliteLedger struct {
+	db     *sql.DB
+	writes chan ledgerWrite
+	done   chan struct{}
+	totals *totalsBook
+
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+// ledgerWrite is a statement queued for the writer.
+type ledgerWrite struct {
+	table string
+	query string
+	args  []interface{}
+}
+
+// OpenSqliteLedger opens the ledger database, creating it and its tables if needed, and starts its writer.
+//
+// Parameters:
+//   - path: The path of the database file.
+//
+// Returns:
+//   - *SqliteLedger: The ledger, to be closed to flush the queued records.
+//   - error: An error if the database cannot be opened or its harvests read.
+func OpenSqliteLedger(path string) (*SqliteLedger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create ledger directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger: %w", err)
+	}
+	// A single connection serializes the writer and the reads
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(ledgerSchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to create ledger tables: %w", err)
+	}
+
+	ledger := &SqliteLedger{db: db, writes: make(chan ledgerWrite, ledgerQueueSize), done: make(chan struct{}), totals: newTotalsBook()}
+	harvests, err := ledger.Harvests()
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	for i := range harvests {
+		ledger.totals.add(&harvests[i])
+	}
+
+	go ledger.write()
+
+	return ledger, nil
+}
+
+// RecordPool stores the options of the pool run, replacing the previous ones.
+func (l *SqliteLedger) RecordPool(protocolOpts models.ProtocolOpts) {
+	opts := protocolOpts.Opts()
+	rawOpts, err := json.Marshal(protocolOpts)
+	if err != nil {
+		log.Error().Err(err).Str("chain", string(opts.Chain)).Msg("Failed to encode pool options for the ledger")
+		return
+	}
+
+	l.enqueue("pools", `INSERT OR REPLACE INTO pools (chain, pool, wallet, lender, gauge, opts, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		string(opts.Chain), string(opts.Pool), opts.Sender.Hex(), opts.ContractLender.Hex(), opts.ContractGauge.Hex(), string(rawOpts), time.Now().UTC())
+}
+
+// RecordIteration stores the decision taken on a block.
+func (l *SqliteLedger) RecordIteration(iteration Iteration) {
+	l.enqueue("iterations", `INSERT INTO iterations (time, chain, pool, wallet, block_number, earned, reward_eth, l2_fee, l1_fee, diff, decision) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		iteration.Time, string(iteration.Chain), string(iteration.Pool), iteration.Wallet.Hex(), iteration.BlockNumber,
+		bigText(iteration.Earned), bigText(iteration.RewardEth), bigText(iteration.L2Fee), nullBigText(iteration.L1Fee), iteration.Diff, string(iteration.Decision))
+}
+
+// RecordTransaction stores a sent transaction.
+func (l *SqliteLedger) RecordTransaction(tx SentTransaction) {
+	l.enqueue("transactions", `INSERT OR REPLACE INTO transactions (hash, time, chain, pool, wallet, nonce, target_block, gas_tip_cap, gas_fee_cap, gas_limit) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		tx.Hash.Hex(), tx.Time, string(tx.Chain), string(tx.Pool), tx.Wallet.Hex(), tx.Nonce, tx.TargetBlock, bigText(tx.GasTipCap), bigText(tx.GasFeeCap), tx.GasLimit)
+}
+
+// Record stores a realized harvest and adds it to the totals of its position, replacing the harvest already recorded for
+// the same transaction.
+func (l *SqliteLedger) Record(harvest Harvest) {
+	l.totals.add(&harvest)
+	l.enqueue("harvests", `INSERT OR REPLACE INTO harvests (tx_hash, time, chain, pool, wallet, block_number, success, reward_token, reward_eth, l2_fee, l1_fee, profit_eth, predicted_reward_eth, predicted_fee) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		harvest.TxHash.Hex(), harvest.Time, string(harvest.Chain), string(harvest.Pool), harvest.Wallet.Hex(), harvest.BlockNumber, harvest.Success,
+		bigText(harvest.RewardToken), bigText(harvest.RewardEth), bigText(harvest.L2Fee), bigText(harvest.L1Fee), bigText(harvest.ProfitEth),
+		nullBigText(harvest.PredictedRewardEth), nullBigText(harvest.PredictedFee))
+}
+
+// RecordReinvest stores a reinvest mined on a lender, by the bot or by a competitor.
+// A reinvest decoded from its event alone, without gas used, keeps the receipt fields already stored.
+func (l *SqliteLedger) RecordReinvest(reinvest competition.Reinvest) {
+	l.enqueue("reinvests", `INSERT INTO reinvests (tx_hash, time, chain, pool, lender, sender, block_number, reward, bounty, gas_used, tip, l2_fee, l1_fee) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (tx_hash) DO UPDATE SET
+	reward = excluded.reward, bounty = excluded.bounty,
+	gas_used = CASE WHEN excluded.gas_used > 0 THEN excluded.gas_used ELSE reinvests.gas_used END,
+	tip = CASE WHEN excluded.gas_used > 0 THEN excluded.tip ELSE reinvests.tip END,
+	l2_fee = CASE WHEN excluded.gas_used > 0 THEN excluded.l2_fee ELSE reinvests.l2_fee END,
+	l1_fee = CASE WHEN excluded.gas_used > 0 THEN excluded.l1_fee ELSE reinvests.l1_fee END`,
+		reinvest.TxHash.Hex(), reinvest.Time, string(reinvest.Chain), string(reinvest.Pool), reinvest.Lender.Hex(), reinvest.Sender.Hex(), reinvest.BlockNumber,
+		bigText(reinvest.Reward), bigText(reinvest.Bounty), reinvest.GasUsed, bigText(reinvest.Tip), bigText(reinvest.L2Fee), bigText(reinvest.L1Fee))
+}
+
+// Totals returns the totals of a position, zero when it has no harvest.
+func (l *SqliteLedger) Totals(position Position) Totals {
+	return l.totals.get(position)
+}
+
+// Harvests reads the harvests written, ordered by time.
+func (l *SqliteLedger) Harvests() ([]Harvest, error) {
+	rows, err := l.db.Query(`SELECT tx_hash, time, chain, pool, wallet, block_number, success, reward_token, reward_eth, l2_fee, l1_fee, profit_eth, predicted_reward_eth, predicted_fee FROM harvests ORDER BY time`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read harvests: %w", err)
+	}
+	defer rows.Close()
+
+	var harvests []Harvest
+	for rows.Next() {
+		var harvest Harvest
+		var txHash, chain, pool, wallet, rewardToken, rewardEth, l2Fee, l1Fee, profitEth string
+		var predictedRewardEth, predictedFee sql.NullString
+		if err := rows.Scan(&txHash, &harvest.Time, &chain, &pool, &wallet, &harvest.BlockNumber, &harvest.Success, &rewardToken, &rewardEth, &l2Fee, &l1Fee, &profitEth, &predictedRewardEth, &predictedFee); err != nil {
+			return nil, fmt.Errorf("failed to read harvest: %w", err)
+		}
+
+		harvest.TxHash = common.HexToHash(txHash)
+		harvest.Chain = models.Chain(chain)
+		harvest.Pool = models.Pool(pool)
+		harvest.Wallet = common.HexToAddress(wallet)
+		harvest.RewardToken = parseBig(rewardToken)
+		harvest.RewardEth = parseBig(rewardEth)
+		harvest.L2Fee = parseBig(l2Fee)
+		harvest.L1Fee = parseBig(l1Fee)
+		harvest.ProfitEth = parseBig(profitEth)
+		if predictedRewardEth.Valid {
+			harvest.PredictedRewardEth = parseBig(predictedRewardEth.String)
+		}
+		if predictedFee.Valid {
+			harvest.PredictedFee = parseBig(predictedFee.String)
+		}
+		harvests = append(harvests, harvest)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read harvests: %w", err)
+	}
+
+	return harvests, nil
+}
+
+// Close writes the queued records and closes the database, the records of a closed ledger are dropped.
+func (l *SqliteLedger) Close() error {
+	l.closeMu.Lock()
+	if l.closed {
+		l.closeMu.Unlock()
+		return nil
+	}
+	l.closed = true
+	close(l.writes)
+	l.closeMu.Unlock()
+
+	<-l.done
+	return l.db.Close()
+}
+
+// enqueue queues a statement without waiting, it is dropped when the queue is full or the ledger closed.
+func (l *SqliteLedger) enqueue(table string, query string, args ...interface{}) {
+	l.closeMu.RLock()
+	defer l.closeMu.RUnlock()
+	if l.closed {
+		log.Warn().Str("table", table).Msg("Ledger is closed, dropping record")
+		return
+	}
+
+	select {
+	case l.writes <- ledgerWrite{table: table, query: query, args: args}:
+	default:
+		log.Warn().Str("table", table).Msg("Ledger queue is full, dropping record")
+	}
+}
+
+// write executes the queued statements until the ledger is closed.
+func (l *SqliteLedger) write() {
+	defer close(l.done)
+
+	for write := range l.writes {
+		if _, err := l.db.Exec(write.query, write.args...); err != nil {
+			log.Error().Err(err).Str("table", write.table).Msg("Failed to write ledger record")
+		}
+	}
+}
+
+// bigText stores a big.Int as a decimal string, SQLite integers being 64 bits.
+func bigText(value *big.Int) string {
+	if value == nil {
+		return "0"
+	}
+	return value.String()
+}
+
+func nullBigText(value *big.Int) interface{} {
+	if value == nil {
+		return nil
+	}
+	return value.String()
+}
+
+func parseBig(value string) *big.Int {
+	parsed, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return big.NewInt(0)
+	}
+	return parsed
+}
@@ -0,0 +1,185 @@
+package competition
+
+import (
+	"context"
+	"defibotgo/internal/contract_abi"
+	"defibotgo/internal/models"
+	"defibotgo/internal/web3"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"time"
+)
+
+// scanBlockChunk is the number of blocks of each eth_getLogs, the providers limiting the range of a query
+const scanBlockChunk = uint64(5000)
+
+// scanBatchSize is the number of receipts and headers fetched in one batch
+const scanBatchSize = 100
+
+// Reinvest is a reinvest mined on a lender contract, by the bot or by a competitor.
+type Reinvest struct {
+	Time        time.Time
+	Chain       models.Chain
+	Pool        models.Pool
+	Lender      common.Address
+	Sender      common.Address // The caller of the reinvest, receiving the bounty
+	BlockNumber uint64
+	TxHash      common.Hash
+	Reward      *big.Int // The reward reinvested
+	Bounty      *big.Int // The bounty paid to the sender, in reward token
+	GasUsed     uint64
+	Tip         *big.Int // The priority fee per gas paid, effective gas price minus base fee
+	L2Fee       *big.Int // gasUsed * effectiveGasPrice
+	L1Fee       *big.Int // The L1 data fee of the OP stack
+}
+
+// ScanQuery selects the Reinvest events to scan.
+type ScanQuery struct {
+	Chain     models.Chain
+	Pool      models.Pool
+	Lender    common.Address
+	Caller    *common.Address // Only the reinvests of this caller, every caller when nil
+	FromBlock uint64
+	ToBlock   uint64
+}
+
+// reinvestEvent is a decoded Reinvest event
+type reinvestEvent struct {
+	Reward *big.Int
+	Bounty *big.Int
+}
+
+// ScanReinvests fetches the Reinvest events of a lender contract with the receipts and blocks of their transactions.
+//
+// Parameters:
+//   - ctx: The context of the requests.
+//   - client: The client of the chain.
+//   - query: The lender, caller and block range to scan.
+//
+// Returns:
+//   - []Reinvest: The reinvests, ordered by block.
+//   - error: An error if the logs, receipts or headers cannot be fetched.
+func ScanReinvests(ctx context.Context, client web3.Client, query ScanQuery) ([]Reinvest, error) {
+	lenderAbi, err := web3.LoadAbi(contract_abi.CONTRACT_ABI_LENDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load lender abi: %w", err)
+	}
+	reinvestId := lenderAbi.Events["Reinvest"].ID
+
+	topics := [][]common.Hash{{reinvestId}}
+	if query.Caller != nil {
+		topics = append(topics, []common.Hash{common.BytesToHash(query.Caller.Bytes())})
+	}
+
+	var logs []types.Log
+	for from := query.FromBlock; from <= query.ToBlock; from += scanBlockChunk {
+		to := min(from+scanBlockChunk-1, query.ToBlock)
+		chunkLogs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Addresses: []common.Address{query.Lender},
+			Topics:    topics,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get reinvest logs from %d to %d: %w", from, to, err)
+		}
+		logs = append(logs, chunkLogs...)
+	}
+
+	var reinvests []Reinvest
+	for start := 0; start < len(logs); start += scanBatchSize {
+		chunk := logs[start:min(start+scanBatchSize, len(logs))]
+
+		batch := web3.NewBatch()
+		receipts := make([]*web3.BatchResult[*web3.OpReceipt], len(chunk))
+		headers := map[uint64]*web3.BatchResult[*types.Header]{}
+		for i, reinvestLog := range chunk {
+			receipts[i] = batch.OpReceipt(reinvestLog.TxHash)
+			if _, ok := headers[reinvestLog.BlockNumber]; !ok {
+				headers[reinvestLog.BlockNumber] = batch.HeaderByNumber(new(big.Int).SetUint64(reinvestLog.BlockNumber))
+			}
+		}
+		if err := batch.Send(ctx, client); err != nil {
+			return nil, fmt.Errorf("failed to get reinvest receipts: %w", err)
+		}
+
+		for i, reinvestLog := range chunk {
+			if receipts[i].Err != nil {
+				return nil, fmt.Errorf("failed to get receipt of %s: %w", reinvestLog.TxHash.Hex(), receipts[i].Err)
+			}
+			header := headers[reinvestLog.BlockNumber]
+			if header.Err != nil {
+				return nil, fmt.Errorf("failed to get block %d: %w", reinvestLog.BlockNumber, header.Err)
+			}
+			reinvest, err := DecodeReinvest(&lenderAbi, reinvestLog, query.Chain, query.Pool)
+			if err != nil {
+				return nil, err
+			}
+
+			receipt := receipts[i].Value
+			reinvest.Time = time.Unix(int64(header.Value.Time), 0).UTC()
+			reinvest.GasUsed = uint64(receipt.GasUsed)
+			reinvest.Tip = effectiveTip(receipt, header.Value)
+			reinvest.L2Fee = receipt.L2Fee()
+			reinvest.L1Fee = receipt.L1DataFee()
+			reinvests = append(reinvests, reinvest)
+		}
+	}
+
+	return reinvests, nil
+}
+
+// DecodeReinvest decodes a Reinvest log of a lender contract. The fields read from the receipt and the block of
+// the transaction are left zero, the time being the time of the decoding.
+//
+// Parameters:
+//   - lenderAbi: The ABI of the lender, holding the Reinvest event.
+//   - reinvestLog: The log to decode.
+//   - chain: The chain of the lender.
+//   - pool: The pool of the lender.
+//
+// Returns:
+//   - Reinvest: The reinvest.
+//   - error: An error if the log is not a Reinvest event.
+func DecodeReinvest(lenderAbi *abi.ABI, reinvestLog types.Log, chain models.Chain, pool models.Pool) (Reinvest, error) {
+	event, ok := lenderAbi.Events["Reinvest"]
+	if !ok || len(reinvestLog.Topics) < 2 || reinvestLog.Topics[0] != event.ID {
+		return Reinvest{}, fmt.Errorf("log %s is not a reinvest", reinvestLog.TxHash.Hex())
+	}
+
+	var decoded reinvestEvent
+	if err := lenderAbi.UnpackIntoInterface(&decoded, "Reinvest", reinvestLog.Data); err != nil {
+		return Reinvest{}, fmt.Errorf("failed to decode reinvest of %s: %w", reinvestLog.TxHash.Hex(), err)
+	}
+
+	return Reinvest{
+		Time:        time.Now().UTC(),
+		Chain:       chain,
+		Pool:        pool,
+		Lender:      reinvestLog.Address,
+		Sender:      common.BytesToAddress(reinvestLog.Topics[1].Bytes()),
+		BlockNumber: reinvestLog.BlockNumber,
+		TxHash:      reinvestLog.TxHash,
+		Reward:      decoded.Reward,
+		Bounty:      decoded.Bounty,
+		Tip:         big.NewInt(0),
+		L2Fee:       big.NewInt(0),
+		L1Fee:       big.NewInt(0),
+	}, nil
+}
+
+// effectiveTip returns the priority fee per gas paid by the transaction, zero when unknown.
+func effectiveTip(receipt *web3.OpReceipt, header *types.Header) *big.Int {
+	if receipt.EffectiveGasPrice == nil || header.BaseFee == nil {
+		return big.NewInt(0)
+	}
+	tip := new(big.Int).Sub(receipt.EffectiveGasPrice.ToInt(), header.BaseFee)
+	if tip.Sign() < 0 {
+		return big.NewInt(0)
+	}
+	return tip
+}
@@ -0,0 +1,134 @@
+package competition
+
+import (
+	"defibotgo/internal/models"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"sort"
+)
+
+// LabelOurs labels the reinvests of the wallets of the bot.
+const LabelOurs = "OURS"
+
+// SenderStats are the reinvests of one sender on a pool.
+type SenderStats struct {
+	Sender        common.Address
+	Label         string   // The known bot name, LabelOurs, or empty for an unknown sender
+	Wins          int      // The number of reinvests, each one took the bounty
+	WinRate       float64  // The share of the reinvests of the pool, in percent
+	MedianTip     *big.Int // The median priority fee per gas paid
+	TipPremium    float64  // MedianTip above the median tip of the pool, in percent
+	MedianGasUsed uint64
+	Bounty        *big.Int // The bounties taken, in reward token
+	LastBlock     uint64
+}
+
+// Summary is the competition on a pool over a block range.
+type Summary struct {
+	Chain     models.Chain
+	Pool      models.Pool
+	Reinvests int
+	FromBlock uint64
+	ToBlock   uint64
+	MedianTip *big.Int      // The median priority fee per gas of every reinvest
+	Senders   []SenderStats // Ordered by wins, the most active first
+}
+
+// Ours returns the stats of the wallets of the bot summed together, zero when they never won.
+func (s *Summary) Ours() SenderStats {
+	ours := SenderStats{Label: LabelOurs, MedianTip: big.NewInt(0), Bounty: big.NewInt(0)}
+	for _, sender := range s.Senders {
+		if sender.Label != LabelOurs {
+			continue
+		}
+		ours.Wins += sender.Wins
+		ours.WinRate += sender.WinRate
+		ours.Bounty.Add(ours.Bounty, sender.Bounty)
+		ours.LastBlock = max(ours.LastBlock, sender.LastBlock)
+	}
+	return ours
+}
+
+// Summarize computes the win rates and tip premiums of the senders of the reinvests.
+//
+// Parameters:
+//   - chain: The chain of the pool.
+//   - pool: The pool.
+//   - reinvests: The reinvests mined on the lender of the pool.
+//   - labels: The labels of the known senders, the wallets of the bot being labeled LabelOurs.
+//
+// Returns:
+//   - Summary: The summary of the pool.
+func Summarize(chain models.Chain, pool models.Pool, reinvests []Reinvest, labels map[common.Address]string) Summary {
+	summary := Summary{Chain: chain, Pool: pool, Reinvests: len(reinvests), MedianTip: big.NewInt(0)}
+	if len(reinvests) == 0 {
+		return summary
+	}
+
+	bySender := map[common.Address][]Reinvest{}
+	allTips := make([]*big.Int, 0, len(reinvests))
+	summary.FromBlock = reinvests[0].BlockNumber
+	for _, reinvest := range reinvests {
+		bySender[reinvest.Sender] = append(bySender[reinvest.Sender], reinvest)
+		allTips = append(allTips, reinvest.Tip)
+		summary.FromBlock = min(summary.FromBlock, reinvest.BlockNumber)
+		summary.ToBlock = max(summary.ToBlock, reinvest.BlockNumber)
+	}
+	summary.MedianTip = medianBig(allTips)
+
+	for sender, senderReinvests := range bySender {
+		stats := SenderStats{
+			Sender:  sender,
+			Label:   labels[sender],
+			Wins:    len(senderReinvests),
+			WinRate: float64(len(senderReinvests)) * 100 / float64(len(reinvests)),
+			Bounty:  big.NewInt(0),
+		}
+
+		tips := make([]*big.Int, 0, len(senderReinvests))
+		gasUsed := make([]uint64, 0, len(senderReinvests))
+		for _, reinvest := range senderReinvests {
+			tips = append(tips, reinvest.Tip)
+			gasUsed = append(gasUsed, reinvest.GasUsed)
+			stats.Bounty.Add(stats.Bounty, reinvest.Bounty)
+			stats.LastBlock = max(stats.LastBlock, reinvest.BlockNumber)
+		}
+		stats.MedianTip = medianBig(tips)
+		stats.TipPremium = premium(stats.MedianTip, summary.MedianTip)
+		sort.Slice(gasUsed, func(i, j int) bool { return gasUsed[i] < gasUsed[j] })
+		stats.MedianGasUsed = gasUsed[len(gasUsed)/2]
+
+		summary.Senders = append(summary.Senders, stats)
+	}
+
+	sort.Slice(summary.Senders, func(i, j int) bool {
+		a, b := &summary.Senders[i], &summary.Senders[j]
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		return a.Sender.Cmp(b.Sender) < 0
+	})
+
+	return summary
+}
+
+// medianBig returns the median of the values, the upper one for an even count.
+func medianBig(values []*big.Int) *big.Int {
+	if len(values) == 0 {
+		return big.NewInt(0)
+	}
+	sorted := make([]*big.Int, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+	return new(big.Int).Set(sorted[len(sorted)/2])
+}
+
+// premium returns how much value is above reference, in percent.
+func premium(value *big.Int, reference *big.Int) float64 {
+	if reference.Sign() == 0 {
+		return 0
+	}
+	diff := new(big.Float).SetInt(new(big.Int).Sub(value, reference))
+	percent, _ := new(big.Float).Quo(diff.Mul(diff, big.NewFloat(100)), new(big.Float).SetInt(reference)).Float64()
+	return percent
+}
@@ -0,0 +1,196 @@
+package competition
+
+import (
+	"context"
+	"defibotgo/internal/models"
+	"defibotgo/internal/web3"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+	"sync"
+	"time"
+)
+
+// DefaultWindow is the number of blocks the summary of a tracker covers, about a day of 2 seconds blocks.
+const DefaultWindow = uint64(43200)
+
+// DefaultInterval is the interval between two scans of a tracker.
+const DefaultInterval = 30 * time.Second
+
+// Recorder persists the reinvests seen by a tracker.
+type Recorder interface {
+	RecordReinvest(reinvest Reinvest)
+}
+
+// TrackerOpts configures the tracker of a pool.
+type TrackerOpts struct {
+	Chain    models.Chain
+	Pool     models.Pool
+	Lender   common.Address
+	Labels   map[common.Address]string // The labels of the known senders, see Labels
+	Window   uint64                    // The number of blocks kept in the summary, DefaultWindow when zero
+	Interval time.Duration             // The interval between two scans, DefaultInterval when zero
+}
+
+// Tracker records every reinvest mined on the lender of a pool and summarizes who wins it and how they bid.
+type Tracker struct {
+	opts     TrackerOpts
+	recorder Recorder
+
+	mu        sync.Mutex
+	reinvests []Reinvest // The reinvests of the window, ordered by block
+	lastBlock uint64     // The last block scanned, zero before the first scan
+}
+
+// NewTracker returns the tracker of a pool.
+//
+// Parameters:
+//   - opts: The pool tracked.
+//   - recorder: The store of the reinvests seen, may be nil.
+//
+// Returns:
+//   - *Tracker: The tracker, to be started with Run or polled with Poll.
+func NewTracker(opts TrackerOpts, recorder Recorder) *Tracker {
+	if opts.Window == 0 {
+		opts.Window = DefaultWindow
+	}
+	if opts.Interval == 0 {
+		opts.Interval = DefaultInterval
+	}
+	return &Tracker{opts: opts, recorder: recorder}
+}
+
+// Labels merges the labels of the known bots with the wallets of the bot, labeled LabelOurs.
+func Labels(knownBots map[common.Address]string, ours ...common.Address) map[common.Address]string {
+	labels := make(map[common.Address]string, len(knownBots)+len(ours))
+	for address, label := range knownBots {
+		labels[address] = label
+	}
+	for _, address := range ours {
+		labels[address] = LabelOurs
+	}
+	return labels
+}
+
+// Run scans the new blocks every interval until the context is canceled, logging the summary when reinvests were found.
+func (t *Tracker) Run(ctx context.Context, client web3.Client) {
+	ticker := time.NewTicker(t.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		found, err := t.Poll(ctx, client)
+		if err != nil {
+			log.Warn().Err(err).Str("chain", string(t.opts.Chain)).Str("pool", string(t.opts.Pool)).Msg("Failed to scan competitor reinvests")
+		}
+		if found > 0 {
+			LogSummary(t.Summary())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll scans the blocks mined since the last scan, the whole window on the first one.
+//
+// Parameters:
+//   - ctx: The context of the requests.
+//   - client: The client of the chain.
+//
+// Returns:
+//   - int: The number of reinvests found.
+//   - error: An error if the head or the reinvests cannot be fetched, the next poll scans the same blocks again.
+func (t *Tracker) Poll(ctx context.Context, client web3.Client) (int, error) {
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get block number: %w", err)
+	}
+
+	t.mu.Lock()
+	fromBlock := t.lastBlock + 1
+	if t.lastBlock == 0 && head > t.opts.Window {
+		fromBlock = head - t.opts.Window + 1
+	}
+	t.mu.Unlock()
+	if fromBlock > head {
+		return 0, nil
+	}
+
+	reinvests, err := ScanReinvests(ctx, client, ScanQuery{Chain: t.opts.Chain, Pool: t.opts.Pool, Lender: t.opts.Lender, FromBlock: fromBlock, ToBlock: head})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, reinvest := range reinvests {
+		label := t.opts.Labels[reinvest.Sender]
+		log.Info().
+			Str("chain", string(t.opts.Chain)).
+			Str("pool", string(t.opts.Pool)).
+			Str("sender", reinvest.Sender.Hex()).
+			Str("label", label).
+			Uint64("block", reinvest.BlockNumber).
+			Str("tip", reinvest.Tip.String()).
+			Uint64("gas used", reinvest.GasUsed).
+			Str("bounty", reinvest.Bounty.String()).
+			Msg("Reinvest on the lender")
+		if t.recorder != nil {
+			t.recorder.RecordReinvest(reinvest)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.reinvests = append(t.reinvests, reinvests...)
+	t.lastBlock = head
+
+	// Only the reinvests of the window are summarized
+	firstKept := 0
+	for firstKept < len(t.reinvests) && t.reinvests[firstKept].BlockNumber+t.opts.Window <= head {
+		firstKept++
+	}
+	t.reinvests = t.reinvests[firstKept:]
+
+	return len(reinvests), nil
+}
+
+// Summary summarizes the reinvests of the window.
+func (t *Tracker) Summary() Summary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Summarize(t.opts.Chain, t.opts.Pool, t.reinvests, t.opts.Labels)
+}
+
+// LogSummary logs the summary, one line per sender.
+func LogSummary(summary Summary) {
+	ours := summary.Ours()
+	log.Info().
+		Str("chain", string(summary.Chain)).
+		Str("pool", string(summary.Pool)).
+		Int("reinvests", summary.Reinvests).
+		Uint64("from", summary.FromBlock).
+		Uint64("to", summary.ToBlock).
+		Str("median tip", summary.MedianTip.String()).
+		Int("our wins", ours.Wins).
+		Float64("our win rate", ours.WinRate).
+		Int("senders", len(summary.Senders)).
+		Msg("Competition summary")
+
+	for _, sender := range summary.Senders {
+		log.Info().
+			Str("chain", string(summary.Chain)).
+			Str("pool", string(summary.Pool)).
+			Str("sender", sender.Sender.Hex()).
+			Str("label", sender.Label).
+			Int("wins", sender.Wins).
+			Float64("win rate", sender.WinRate).
+			Str("median tip", sender.MedianTip.String()).
+			Float64("tip premium", sender.TipPremium).
+			Uint64("median gas used", sender.MedianGasUsed).
+			Str("bounty", sender.Bounty.String()).
+			Msg("Competitor")
+	}
+}
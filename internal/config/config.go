@@ -1,15 +1,91 @@
 package config
 
-import "defibotgo/internal/models"
+import (
+	"defibotgo/internal/models"
+	"fmt"
+	"strings"
+)
 
-// ChainToRpcUrlRead maps a Chain to its RPC URL for view functions
-var ChainToRpcUrlRead = map[models.Chain]string{
-	models.Optimism: GetSecret(RpcNodeOptimismReadKey),
-	models.Base:     GetSecret(RpcNodeBaseReadKey),
+// RpcEndpoint is an RPC node URL with the optional headers sent on every request (e.g. authentication)
+type RpcEndpoint struct {
+	Url     string
+	Headers map[string]string
 }
 
-// ChainToRpcUrlWrite maps a Chain to its RPC URL for write functions
-var ChainToRpcUrlWrite = map[models.Chain]string{
-	models.Optimism: GetSecret(RpcNodeOptimismWriteKey),
-	models.Base:     GetSecret(RpcNodeBaseWriteKey),
+// ChainToRpcEndpointsRead maps a Chain to its RPC endpoints for view functions, set from the configuration file
+var ChainToRpcEndpointsRead = map[models.Chain][]RpcEndpoint{}
+
+// ChainToRpcEndpointsWrite maps a Chain to its RPC endpoints for write functions, set from the configuration file
+var ChainToRpcEndpointsWrite = map[models.Chain][]RpcEndpoint{}
+
+// chainToRpcSecrets maps a Chain to the secrets of its read and write endpoints, used when the configuration file
+// doesn't set them
+var chainToRpcSecrets = map[models.Chain][2]SecretKey{
+	models.Optimism: {RpcNodeOptimismReadKey, RpcNodeOptimismWriteKey},
+	models.Base:     {RpcNodeBaseReadKey, RpcNodeBaseWriteKey},
+}
+
+// RpcEndpoints returns the RPC endpoints of a chain, read from its RPC_NODE_* environment variable when the
+// configuration file doesn't set them.
+//
+// Parameters:
+//   - chain: The blockchain network.
+//   - asReader: Whether to return the endpoints dedicated to view functions instead of the writing ones.
+//
+// Returns:
+//   - []RpcEndpoint: The endpoints of the chain.
+//   - error: An error if the chain has no endpoint.
+func RpcEndpoints(chain models.Chain, asReader bool) ([]RpcEndpoint, error) {
+	endpoints, secretIndex := ChainToRpcEndpointsWrite[chain], 1
+	if asReader {
+		endpoints, secretIndex = ChainToRpcEndpointsRead[chain], 0
+	}
+	if len(endpoints) > 0 {
+		return endpoints, nil
+	}
+
+	secrets, ok := chainToRpcSecrets[chain]
+	if !ok {
+		return nil, fmt.Errorf("no rpc endpoint configured for chain %s", chain)
+	}
+	raw, err := LookupSecret(secrets[secretIndex])
+	if err != nil {
+		return nil, fmt.Errorf("no rpc endpoint configured for chain %s: %w", chain, err)
+	}
+	if endpoints = ParseRpcEndpoints(raw); len(endpoints) == 0 {
+		return nil, fmt.Errorf("no rpc endpoint configured for chain %s", chain)
+	}
+	return endpoints, nil
+}
+
+// ParseRpcEndpoints parses a comma separated list of RPC endpoints.
+//
+// Each endpoint is a URL optionally followed by headers separated by "|", e.g.
+// "https://a.example,https://b.example|Authorization=Bearer abc|X-Api-Key=xyz".
+// Empty entries are ignored.
+func ParseRpcEndpoints(raw string) []RpcEndpoint {
+	var endpoints []RpcEndpoint
+
+	for _, entry := range strings.Split(raw, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), "|")
+		if parts[0] == "" {
+			continue
+		}
+
+		endpoint := RpcEndpoint{Url: strings.TrimSpace(parts[0])}
+		for _, header := range parts[1:] {
+			key, value, found := strings.Cut(header, "=")
+			if !found || strings.TrimSpace(key) == "" {
+				continue
+			}
+			if endpoint.Headers == nil {
+				endpoint.Headers = map[string]string{}
+			}
+			endpoint.Headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return endpoints
 }
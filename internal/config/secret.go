@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog/log"
 	"os"
@@ -15,55 +16,20 @@ const (
 	RpcNodeBaseWriteKey
 	RpcNodeOptimismReadKey
 	RpcNodeOptimismWriteKey
-	WalletTarotKeyOne
-	WalletTarotAddressOne
-	WalletTarotAddressTwo
-	WalletTarotKeyTwo
-	WalletTarotAddressThree
-	WalletTarotKeyThree
 	WalletTestPrivateKey
-	WalletImpermaxKeyOne
-	WalletImpermaxAddressOne
 )
 
-var (
-	secrets        map[SecretKey]string
-	secretsStorage sync.Once // Ensures secrets are loaded only once
-)
-
-// loadSecrets initializes the secrets map with environment variables based on APP_ENV.
-func loadSecrets() {
-	env := os.Getenv("APP_ENV")
-	loadEnvFile(env)
-
-	// Configure secrets based on the environment
-	switch env {
-	case "test":
-		secrets = map[SecretKey]string{
-			RpcNodeBaseReadKey:      getEnvOrFatal("RPC_NODE_BASE_READ"),
-			RpcNodeBaseWriteKey:     getEnvOrFatal("RPC_NODE_BASE_WRITE"),
-			RpcNodeOptimismReadKey:  getEnvOrFatal("RPC_NODE_OPTIMISM_READ"),
-			RpcNodeOptimismWriteKey: getEnvOrFatal("RPC_NODE_OPTIMISM_WRITE"),
-			WalletTestPrivateKey:    getEnvOrFatal("WALLET_TEST_PRIVATE_KEY"),
-		}
-	default:
-		secrets = map[SecretKey]string{
-			RpcNodeBaseReadKey:       getEnvOrFatal("RPC_NODE_BASE_READ"),
-			RpcNodeBaseWriteKey:      getEnvOrFatal("RPC_NODE_BASE_WRITE"),
-			RpcNodeOptimismReadKey:   getEnvOrFatal("RPC_NODE_OPTIMISM_READ"),
-			RpcNodeOptimismWriteKey:  getEnvOrFatal("RPC_NODE_OPTIMISM_WRITE"),
-			WalletTarotKeyOne:        getEnvOrFatal("ACCOUNT_PRIVATE_KEY_TAROT_ONE"),
-			WalletTarotAddressOne:    getEnvOrFatal("ACCOUNT_SENDER_ADDRESS_TAROT_ONE"),
-			WalletTarotKeyTwo:        getEnvOrFatal("ACCOUNT_PRIVATE_KEY_TAROT_TWO"),
-			WalletTarotAddressTwo:    getEnvOrFatal("ACCOUNT_SENDER_ADDRESS_TAROT_TWO"),
-			WalletTarotAddressThree:  getEnvOrFatal("ACCOUNT_SENDER_ADDRESS_TAROT_THREE"),
-			WalletTarotKeyThree:      getEnvOrFatal("ACCOUNT_PRIVATE_KEY_TAROT_THREE"),
-			WalletImpermaxKeyOne:     getEnvOrFatal("ACCOUNT_PRIVATE_KEY_IMPERMAX_ONE"),
-			WalletImpermaxAddressOne: getEnvOrFatal("ACCOUNT_SENDER_ADDRESS_IMPERMAX_ONE"),
-		}
-	}
+// secretEnv maps each secret to its environment variable
+var secretEnv = map[SecretKey]string{
+	RpcNodeBaseReadKey:      "RPC_NODE_BASE_READ",
+	RpcNodeBaseWriteKey:     "RPC_NODE_BASE_WRITE",
+	RpcNodeOptimismReadKey:  "RPC_NODE_OPTIMISM_READ",
+	RpcNodeOptimismWriteKey: "RPC_NODE_OPTIMISM_WRITE",
+	WalletTestPrivateKey:    "WALLET_TEST_PRIVATE_KEY",
 }
 
+var envStorage sync.Once // Ensures the .env file is loaded only once
+
 // loadEnvFile loads the appropriate .env file based on the APP_ENV variable.
 func loadEnvFile(env string) {
 	var envFile string
@@ -87,23 +53,38 @@ func loadEnvFile(env string) {
 	}
 }
 
-// getEnvOrFatal retrieves an environment variable or exits if it’s not set.
-func getEnvOrFatal(key string) string {
-	value, exists := os.LookupEnv(key)
-	if !exists {
-		log.Fatal().Str("key", key).Msg("Environment variable is required but not set")
+// GetSecret retrieves a secret by key, exiting if its environment variable is not set.
+//
+// Only the variable of the secret is read, the secrets which are not used are never required.
+func GetSecret(key SecretKey) string {
+	secret, err := LookupSecret(key)
+	if err != nil {
+		log.Fatal().Err(err).Msg("secret not found for key")
 	}
-	return value
+	return secret
 }
 
-// GetSecret retrieves a secret by key
-func GetSecret(key SecretKey) string {
-	// Ensure secrets are loaded only once
-	secretsStorage.Do(loadSecrets)
-
-	secret, exists := secrets[key]
-	if !exists {
-		log.Fatal().Int("key", int(key)).Msg("secret not found for key")
+// LookupSecret retrieves a secret by key.
+//
+// Returns:
+//   - string: The secret.
+//   - error: An error naming the environment variable of the secret when it is not set.
+func LookupSecret(key SecretKey) (string, error) {
+	name, ok := secretEnv[key]
+	if !ok {
+		return "", fmt.Errorf("unknown secret %d", key)
 	}
-	return secret
+	secret, ok := LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return secret, nil
+}
+
+// LookupEnv retrieves an environment variable, after loading the .env file of the APP_ENV variable.
+//
+// The wallets and the endpoints of the configuration file reference their secrets as environment variables.
+func LookupEnv(key string) (string, bool) {
+	envStorage.Do(func() { loadEnvFile(os.Getenv("APP_ENV")) })
+	return os.LookupEnv(key)
 }
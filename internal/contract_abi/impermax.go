@@ -0,0 +1,31 @@
+package contract_abi
+
+// CONTRACT_ABI_STAKED_LP_TOKEN is the ABI definition for the Impermax staked LP token contract
+const CONTRACT_ABI_STAKED_LP_TOKEN = `[
+    {
+        "inputs": [],
+        "name": "reinvest",
+        "outputs": [],
+        "stateMutability": "nonpayable",
+        "type": "function"
+    },
+    {
+        "inputs": [],
+        "name": "REINVEST_BOUNTY",
+        "outputs": [
+            {"internalType": "uint256", "name": "", "type": "uint256"}
+        ],
+        "stateMutability": "view",
+        "type": "function"
+    },
+    {
+        "anonymous": false,
+        "inputs": [
+            {"indexed": true, "internalType": "address", "name": "caller", "type": "address"},
+            {"indexed": false, "internalType": "uint256", "name": "reward", "type": "uint256"},
+            {"indexed": false, "internalType": "uint256", "name": "bounty", "type": "uint256"}
+        ],
+        "name": "Reinvest",
+        "type": "event"
+    }
+]`
@@ -10,6 +10,16 @@ const CONTRACT_ABI_LENDER = `[
         "payable": false,
         "stateMutability": "nonpayable",
         "type": "function"
+    },
+    {
+        "anonymous": false,
+        "inputs": [
+            {"indexed": true, "name": "caller", "type": "address"},
+            {"indexed": false, "name": "reward", "type": "uint256"},
+            {"indexed": false, "name": "bounty", "type": "uint256"}
+        ],
+        "name": "Reinvest",
+        "type": "event"
     }
 ]`
 
@@ -74,6 +84,24 @@ const CONTRACT_ABI_GAUGE = `[
     ],
     "stateMutability": "view",
     "type": "function"
+  },
+  {
+    "anonymous": false,
+    "inputs": [
+      { "indexed": true, "internalType": "address", "name": "user", "type": "address" },
+      { "indexed": false, "internalType": "uint256", "name": "reward", "type": "uint256" }
+    ],
+    "name": "RewardPaid",
+    "type": "event"
+  },
+  {
+    "anonymous": false,
+    "inputs": [
+      { "indexed": true, "internalType": "address", "name": "from", "type": "address" },
+      { "indexed": false, "internalType": "uint256", "name": "amount", "type": "uint256" }
+    ],
+    "name": "ClaimRewards",
+    "type": "event"
   }
 ]`
 
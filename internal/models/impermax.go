@@ -0,0 +1,13 @@
+package models
+
+import (
+	"math/big"
+)
+
+// ImpermaxOpts are the options of the Impermax staked LP tokens, whose earned is always extrapolated
+type ImpermaxOpts struct {
+	PoolOpts
+
+	ReinvestBounty *big.Int
+	RewardRate     *big.Int
+}
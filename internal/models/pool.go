@@ -0,0 +1,130 @@
+package models
+
+import (
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"sync/atomic"
+)
+
+// TipEstimatorKind selects how the priority fee paid by the competitors is estimated
+type TipEstimatorKind string
+
+const (
+	// TipEstimatorLogs scans the transactions which emitted logs on the lender contract
+	TipEstimatorLogs TipEstimatorKind = "LOGS"
+	// TipEstimatorFeeHistory uses the eth_feeHistory reward percentile of the recent blocks
+	TipEstimatorFeeHistory TipEstimatorKind = "FEE_HISTORY"
+	// TipEstimatorBlockReceipts scans the receipts of the recent blocks for transactions sent to the lender contract
+	TipEstimatorBlockReceipts TipEstimatorKind = "BLOCK_RECEIPTS"
+)
+
+// SubmissionKind selects how the signed reinvest transactions are broadcast
+type SubmissionKind string
+
+const (
+	// SubmissionPublic sends the transactions to the public mempool with eth_sendRawTransaction
+	SubmissionPublic SubmissionKind = "PUBLIC"
+	// SubmissionBundle sends the transactions to a Flashbots-style relay with eth_sendBundle, targeting the next block
+	SubmissionBundle SubmissionKind = "BUNDLE"
+	// SubmissionConditional sends the transactions with the OP stack eth_sendRawTransactionConditional
+	SubmissionConditional SubmissionKind = "CONDITIONAL"
+)
+
+// PoolOpts are the options shared by the pools of every protocol
+type PoolOpts struct {
+	PriorityFee             *big.Int
+	BlockRange              *big.Int
+	ProfitableThreshold     float64
+	GasUsedDefault          uint64
+	ExtraPriorityFeePercent [2]int
+	TipEstimator            TipEstimatorKind // Defaults to TipEstimatorLogs
+	TipPercentile           float64          // Reward percentile used by TipEstimatorFeeHistory
+	Submission              SubmissionKind   // Defaults to SubmissionPublic
+	RelayUrl                string           // Relay used by SubmissionBundle, the write endpoints when empty
+	Chain                   Chain
+	Protocol                Protocol // Selects the harvester of the pool
+	Pool                    Pool     // Names the pool in the ledger
+
+	Sender                 common.Address
+	ContractLender         common.Address // The contract harvested, its reinvest pays the bounty
+	ContractGauge          common.Address // The gauge the lender stakes in, paying its rewards
+	ContractGasPriceOracle common.Address
+	RewardToken            common.Address // The token of the reinvest bounty, every token received is counted when unset
+}
+
+// Opts returns the options shared by the pools of every protocol, promoted to the options embedding them.
+func (o *PoolOpts) Opts() *PoolOpts {
+	return o
+}
+
+// ProtocolOpts is implemented by the options of the pools of every protocol
+type ProtocolOpts interface {
+	Opts() *PoolOpts
+}
+
+// Tunables are the options of a pool which can change while it runs, applied from its next iteration
+type Tunables struct {
+	PriorityFee             *big.Int
+	ProfitableThreshold     float64
+	GasUsedDefault          uint64
+	ExtraPriorityFeePercent [2]int
+}
+
+// Tunables returns the tunables of the pool.
+func (o *PoolOpts) Tunables() Tunables {
+	return Tunables{
+		PriorityFee:             o.PriorityFee,
+		ProfitableThreshold:     o.ProfitableThreshold,
+		GasUsedDefault:          o.GasUsedDefault,
+		ExtraPriorityFeePercent: o.ExtraPriorityFeePercent,
+	}
+}
+
+// SetTunables replaces the tunables of the pool.
+func (o *PoolOpts) SetTunables(tunables Tunables) {
+	o.PriorityFee = tunables.PriorityFee
+	o.ProfitableThreshold = tunables.ProfitableThreshold
+	o.GasUsedDefault = tunables.GasUsedDefault
+	o.ExtraPriorityFeePercent = tunables.ExtraPriorityFeePercent
+}
+
+// Diff lists the tunables changed by the newer ones, as "name: old -> new".
+func (t Tunables) Diff(newer Tunables) []string {
+	var changes []string
+	if t.PriorityFee.Cmp(newer.PriorityFee) != 0 {
+		changes = append(changes, fmt.Sprintf("priority_fee: %v -> %v", t.PriorityFee, newer.PriorityFee))
+	}
+	if t.ProfitableThreshold != newer.ProfitableThreshold {
+		changes = append(changes, fmt.Sprintf("profitable_threshold: %v -> %v", t.ProfitableThreshold, newer.ProfitableThreshold))
+	}
+	if t.GasUsedDefault != newer.GasUsedDefault {
+		changes = append(changes, fmt.Sprintf("gas_used_default: %v -> %v", t.GasUsedDefault, newer.GasUsedDefault))
+	}
+	if t.ExtraPriorityFeePercent != newer.ExtraPriorityFeePercent {
+		changes = append(changes, fmt.Sprintf("extra_priority_fee_percent: %v -> %v", t.ExtraPriorityFeePercent, newer.ExtraPriorityFeePercent))
+	}
+	return changes
+}
+
+// LiveOpts holds the options of a running pool, replaced as a whole when its tunables are reloaded
+type LiveOpts struct {
+	current atomic.Pointer[ProtocolOpts]
+}
+
+// NewLiveOpts returns the holder of the options of a pool.
+func NewLiveOpts(opts ProtocolOpts) *LiveOpts {
+	live := &LiveOpts{}
+	live.Store(opts)
+	return live
+}
+
+// Load returns the current options of the pool, which must not be modified.
+func (l *LiveOpts) Load() ProtocolOpts {
+	return *l.current.Load()
+}
+
+// Store replaces the options of the pool.
+func (l *LiveOpts) Store(opts ProtocolOpts) {
+	l.current.Store(&opts)
+}
@@ -1,22 +1,29 @@
 package models
 
 import (
-	"github.com/ethereum/go-ethereum/common"
 	"math/big"
 )
 
+// RewardEstimatorKind selects how the reward earned at the next block is predicted
+type RewardEstimatorKind string
+
+const (
+	// RewardEstimatorExtrapolate extrapolates earned with the reward rate over the block time
+	RewardEstimatorExtrapolate RewardEstimatorKind = "EXTRAPOLATE"
+	// RewardEstimatorSimulate eth_calls earned with the block overridden by the next one
+	RewardEstimatorSimulate RewardEstimatorKind = "SIMULATE"
+)
+
 type TarotOpts struct {
-	ReinvestBounty          *big.Int
-	PriorityFee             *big.Int
-	RewardRate              *big.Int
-	BlockRange              *big.Int
-	ProfitableThreshold     float64
-	GasUsedDefault          uint64
-	ExtraPriorityFeePercent [2]int
-	Chain                   Chain
+	PoolOpts
+
+	ReinvestBounty  *big.Int
+	RewardRate      *big.Int
+	RewardEstimator RewardEstimatorKind // Defaults to RewardEstimatorExtrapolate
+	ValidateReward  bool                // Simulates earned with the extrapolation too, to compare them in the debug logs
+}
 
-	Sender                 common.Address
-	ContractLender         common.Address
-	ContractGauge          common.Address
-	ContractGasPriceOracle common.Address
+// SimulatesReward reports whether earned is simulated at the next block on every head.
+func (o *TarotOpts) SimulatesReward() bool {
+	return o.RewardEstimator == RewardEstimatorSimulate || o.ValidateReward
 }
@@ -3,5 +3,9 @@ package config
 import "github.com/ethereum/go-ethereum/common"
 
 var ZeroAddress = common.Address{}
-var ReinvestBounty = int64(20000000000000000) // 2% of fee
+
+// BaseGasPriceOracleAddress is the OP stack predeploy, the gas price oracle of the chains which don't configure one
 var BaseGasPriceOracleAddress = common.HexToAddress("0x420000000000000000000000000000000000000F")
+
+// KnownBots labels the known senders competing for the reinvests in the competitor summaries
+var KnownBots = map[common.Address]string{}
@@ -0,0 +1,554 @@
+package config
+
+import (
+	"bytes"
+	"defibotgo/internal/config"
+	"defibotgo/internal/models"
+	"defibotgo/internal/web3"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gopkg.in/yaml.v3"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DefaultPath is the configuration file read when none is given
+const DefaultPath = "config.yaml"
+
+// Config is the validated content of the configuration file: the selected pools, with their chains and wallets
+type Config struct {
+	Chains  map[models.Chain]Chain
+	Wallets map[common.Address]string // The private key of each wallet, by address, empty when not required
+	Pools   []models.ProtocolOpts     // In the order of the file
+}
+
+// Chain holds the RPC endpoints of a chain
+type Chain struct {
+	RpcRead  []config.RpcEndpoint
+	RpcWrite []config.RpcEndpoint
+}
+
+// Selection chooses the pools of the configuration file whose secrets are resolved
+type Selection struct {
+	Match func(opts *models.PoolOpts) bool // Selects every pool when nil
+	Keys  bool                             // Whether the private keys of the wallets are required, to send transactions
+}
+
+// selects reports whether the pool is selected.
+func (s Selection) selects(opts *models.PoolOpts) bool {
+	return s.Match == nil || s.Match(opts)
+}
+
+// ChainSelection selects the pools of a chain.
+//
+// Parameters:
+//   - chain: The chain of the pools, every chain when empty.
+//   - keys: Whether the private keys of the wallets are required, to send transactions.
+//
+// Returns:
+//   - Selection: The selection of the pools of the chain.
+func ChainSelection(chain models.Chain, keys bool) Selection {
+	if chain == "" {
+		return Selection{Keys: keys}
+	}
+	return Selection{Match: func(opts *models.PoolOpts) bool { return opts.Chain == chain }, Keys: keys}
+}
+
+// fileConfig is the layout of the configuration file
+type fileConfig struct {
+	Chains  map[string]fileChain  `yaml:"chains"`
+	Wallets map[string]fileWallet `yaml:"wallets"`
+	Pools   []filePool            `yaml:"pools"`
+}
+
+// The secrets of the file (endpoints, wallets and relays) are written as ${VARIABLE}, read from the environment
+type fileChain struct {
+	RpcRead        string `yaml:"rpc_read"`  // Comma separated endpoints, see config.ParseRpcEndpoints
+	RpcWrite       string `yaml:"rpc_write"` // Comma separated endpoints, see config.ParseRpcEndpoints
+	GasPriceOracle string `yaml:"gas_price_oracle"`
+}
+
+type fileWallet struct {
+	Address    string `yaml:"address"` // Derived from the private key when empty
+	PrivateKey string `yaml:"private_key"`
+}
+
+type filePool struct {
+	Chain    string `yaml:"chain"`
+	Protocol string `yaml:"protocol"`
+	Pool     string `yaml:"pool"`
+	Wallet   string `yaml:"wallet"` // Name of the wallet sending the reinvests
+
+	Lender         string `yaml:"lender"`
+	Gauge          string `yaml:"gauge"`
+	GasPriceOracle string `yaml:"gas_price_oracle"` // Defaults to the one of the chain
+	RewardToken    string `yaml:"reward_token"`
+
+	ReinvestBounty  amount `yaml:"reinvest_bounty"`
+	RewardRate      amount `yaml:"reward_rate"`
+	RewardEstimator string `yaml:"reward_estimator"`
+	ValidateReward  bool   `yaml:"validate_reward"`
+
+	PriorityFee             amount  `yaml:"priority_fee"`
+	BlockRange              amount  `yaml:"block_range"`
+	ProfitableThreshold     float64 `yaml:"profitable_threshold"`
+	GasUsedDefault          uint64  `yaml:"gas_used_default"`
+	ExtraPriorityFeePercent [2]int  `yaml:"extra_priority_fee_percent"`
+	TipEstimator            string  `yaml:"tip_estimator"`
+	TipPercentile           float64 `yaml:"tip_percentile"`
+	Submission              string  `yaml:"submission"`
+	RelayUrl                string  `yaml:"relay_url"`
+}
+
+// amount is an integer of the file which may not fit in 64 bits, written as a number or a string
+type amount struct {
+	*big.Int
+}
+
+func (a *amount) UnmarshalYAML(value *yaml.Node) error {
+	v, ok := new(big.Int).SetString(value.Value, 10)
+	if !ok {
+		return fmt.Errorf("line %d: invalid integer %q", value.Line, value.Value)
+	}
+	a.Int = v
+	return nil
+}
+
+var protocols = map[models.Protocol]bool{
+	models.Tarot:    true,
+	models.Impermax: true,
+}
+
+var tipEstimators = map[models.TipEstimatorKind]bool{
+	"":                               true,
+	models.TipEstimatorLogs:          true,
+	models.TipEstimatorFeeHistory:    true,
+	models.TipEstimatorBlockReceipts: true,
+}
+
+var submissions = map[models.SubmissionKind]bool{
+	"":                           true,
+	models.SubmissionPublic:      true,
+	models.SubmissionBundle:      true,
+	models.SubmissionConditional: true,
+}
+
+var rewardEstimators = map[models.RewardEstimatorKind]bool{
+	"":                                true,
+	models.RewardEstimatorExtrapolate: true,
+	models.RewardEstimatorSimulate:    true,
+}
+
+// Load reads and validates the configuration file, and resolves the secrets of the selected pools.
+//
+// Parameters:
+//   - path: The path of the YAML configuration file.
+//   - selection: The pools to resolve.
+//
+// Returns:
+//   - *Config: The selected pools, with their chains and wallets.
+//   - error: An error if the file can't be read, or listing every invalid value and missing secret.
+func Load(path string, selection Selection) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	cfg, err := Parse(data, selection)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse decodes and validates the content of a configuration file, and resolves the secrets of the selected pools.
+//
+// Every chain, wallet and pool of the file is validated, but only the environment variables of the selected pools,
+// of their chains and of their wallets are read.
+//
+// Parameters:
+//   - data: The YAML content.
+//   - selection: The pools to resolve.
+//
+// Returns:
+//   - *Config: The selected pools, with their chains and wallets.
+//   - error: An error listing every unknown field and invalid value, and the unset environment variables of the
+//     selected pools.
+func Parse(data []byte, selection Selection) (*Config, error) {
+	var file fileConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to decode: %w", err)
+	}
+
+	var errs []error
+	chains := map[models.Chain]fileChain{}
+	oracles := map[models.Chain]common.Address{}
+	for _, name := range sortedNames(file.Chains) {
+		chain := models.Chain(strings.ToUpper(name))
+		chains[chain] = file.Chains[name]
+		if _, ok := web3.ChainIDs[chain]; !ok {
+			errs = append(errs, fmt.Errorf("chain %s is not supported", name))
+		}
+
+		oracle, err := parseAddress(fmt.Sprintf("chain %s gas_price_oracle", name), file.Chains[name].GasPriceOracle, false)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if oracle == ZeroAddress {
+			oracle = BaseGasPriceOracleAddress
+		}
+		oracles[chain] = oracle
+	}
+
+	var selected []selectedPool
+	seen := map[string]bool{}
+	for i, pool := range file.Pools {
+		id := fmt.Sprintf("pool %d (%s/%s/%s)", i+1, pool.Chain, pool.Protocol, pool.Pool)
+		key := strings.ToUpper(pool.Chain + "/" + pool.Protocol + "/" + pool.Pool)
+		if seen[key] {
+			errs = append(errs, fmt.Errorf("%s: declared twice", id))
+		}
+		seen[key] = true
+
+		protocolOpts, poolErrs := parsePool(pool, file.Wallets, oracles)
+		for _, err := range poolErrs {
+			errs = append(errs, fmt.Errorf("%s: %w", id, err))
+		}
+		if len(poolErrs) == 0 && selection.selects(protocolOpts.Opts()) {
+			selected = append(selected, selectedPool{opts: protocolOpts, wallet: pool.Wallet, relayUrl: pool.RelayUrl})
+		}
+	}
+	if len(file.Pools) == 0 {
+		errs = append(errs, errors.New("no pool configured"))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	// The secrets are only read for the selected pools, the missing ones are listed together
+	cfg := &Config{Chains: map[models.Chain]Chain{}, Wallets: map[common.Address]string{}}
+	resolver := &secretResolver{}
+	senders := map[string]common.Address{}
+	for _, pool := range selected {
+		opts := pool.opts.Opts()
+		if _, ok := cfg.Chains[opts.Chain]; !ok {
+			chain, err := resolver.chain(opts.Chain, chains[opts.Chain])
+			if err != nil {
+				errs = append(errs, err)
+			}
+			cfg.Chains[opts.Chain] = chain
+		}
+
+		sender, ok := senders[pool.wallet]
+		if !ok {
+			address, privateKey, err := resolver.wallet(pool.wallet, file.Wallets[pool.wallet], selection.Keys)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			sender, senders[pool.wallet] = address, address
+			cfg.Wallets[address] = privateKey
+		}
+		opts.Sender = sender
+		opts.RelayUrl = resolver.expand(pool.relayUrl, fmt.Sprintf("pool %s/%s/%s relay_url", opts.Chain, opts.Protocol, opts.Pool))
+		cfg.Pools = append(cfg.Pools, pool.opts)
+	}
+	if len(resolver.missing) > 0 {
+		errs = append(errs, fmt.Errorf("environment variables not set: %s", strings.Join(resolver.missing, ", ")))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Pool returns the options of a pool.
+//
+// Parameters:
+//   - chain: The chain of the pool.
+//   - protocol: The protocol of the pool.
+//   - pool: The name of the pool.
+//
+// Returns:
+//   - models.ProtocolOpts: The options of the pool.
+//   - error: An error if the file declares no such pool.
+func (c *Config) Pool(chain models.Chain, protocol models.Protocol, pool models.Pool) (models.ProtocolOpts, error) {
+	for _, protocolOpts := range c.Pools {
+		opts := protocolOpts.Opts()
+		if opts.Chain == chain && opts.Protocol == protocol && opts.Pool == pool {
+			return protocolOpts, nil
+		}
+	}
+	return nil, fmt.Errorf("pool not found for chain=%s protocol=%s poolID=%s", chain, protocol, pool)
+}
+
+// ChainPools returns the options of the pools of a chain.
+func (c *Config) ChainPools(chain models.Chain) []models.ProtocolOpts {
+	var pools []models.ProtocolOpts
+	for _, protocolOpts := range c.Pools {
+		if protocolOpts.Opts().Chain == chain {
+			pools = append(pools, protocolOpts)
+		}
+	}
+	return pools
+}
+
+// Senders returns the wallets sending the reinvests of the pools of a chain, without duplicates.
+func (c *Config) Senders(chain models.Chain) []common.Address {
+	seen := map[common.Address]bool{}
+	var senders []common.Address
+	for _, protocolOpts := range c.ChainPools(chain) {
+		sender := protocolOpts.Opts().Sender
+		if !seen[sender] {
+			seen[sender] = true
+			senders = append(senders, sender)
+		}
+	}
+	return senders
+}
+
+// PrivateKey returns the private key of a wallet of the selected pools.
+func (c *Config) PrivateKey(address common.Address) (string, error) {
+	privateKey, ok := c.Wallets[address]
+	if !ok {
+		return "", fmt.Errorf("wallet %s is not configured", address.Hex())
+	}
+	if privateKey == "" {
+		return "", fmt.Errorf("private key of wallet %s is not loaded", address.Hex())
+	}
+	return privateKey, nil
+}
+
+// selectedPool is a pool of the file whose secrets are to be resolved
+type selectedPool struct {
+	opts     models.ProtocolOpts
+	wallet   string
+	relayUrl string
+}
+
+// secretResolver expands the environment variables of the file, collecting the unset ones
+type secretResolver struct {
+	missing []string // The unset variables, followed by the value referencing them
+}
+
+// expand replaces the ${VARIABLE} of a value by the environment variables, recording the unset ones.
+//
+// Parameters:
+//   - value: The value of the file.
+//   - field: The field of the value, listed with the unset variables.
+//
+// Returns:
+//   - string: The expanded value, empty when a variable is not set.
+func (r *secretResolver) expand(value string, field string) string {
+	unset := false
+	expanded := os.Expand(value, func(key string) string {
+		v, ok := config.LookupEnv(key)
+		if !ok {
+			r.missing = append(r.missing, fmt.Sprintf("%s (%s)", key, field))
+			unset = true
+		}
+		return v
+	})
+	if unset {
+		return ""
+	}
+	return strings.TrimSpace(expanded)
+}
+
+// chain reads the endpoints of a chain.
+func (r *secretResolver) chain(name models.Chain, chain fileChain) (Chain, error) {
+	missing := len(r.missing)
+	rpcRead := config.ParseRpcEndpoints(r.expand(chain.RpcRead, fmt.Sprintf("chain %s rpc_read", name)))
+	rpcWrite := config.ParseRpcEndpoints(r.expand(chain.RpcWrite, fmt.Sprintf("chain %s rpc_write", name)))
+	if len(r.missing) > missing {
+		return Chain{}, nil
+	}
+
+	var errs []error
+	if len(rpcRead) == 0 {
+		errs = append(errs, fmt.Errorf("chain %s rpc_read: no endpoint", name))
+	}
+	if len(rpcWrite) == 0 {
+		errs = append(errs, fmt.Errorf("chain %s rpc_write: no endpoint", name))
+	}
+	return Chain{RpcRead: rpcRead, RpcWrite: rpcWrite}, errors.Join(errs...)
+}
+
+// wallet reads the address of a wallet, derived from its private key when it is set.
+//
+// Parameters:
+//   - name: The name of the wallet.
+//   - wallet: The wallet of the file.
+//   - keys: Whether the private key is required, to send transactions.
+//
+// Returns:
+//   - common.Address: The address of the wallet.
+//   - string: The private key of the wallet, hex encoded without prefix, empty when not required and not set.
+//   - error: An error if the address or the private key is invalid, or if they don't match.
+func (r *secretResolver) wallet(name string, wallet fileWallet, keys bool) (common.Address, string, error) {
+	missing := len(r.missing)
+	rawAddress := r.expand(wallet.Address, fmt.Sprintf("wallet %s address", name))
+	address, err := parseAddress(fmt.Sprintf("wallet %s address", name), rawAddress, false)
+	if err != nil {
+		return ZeroAddress, "", err
+	}
+
+	// Without transactions to send, the private key only matters when the address is not set
+	var privateKey string
+	if keys || address == ZeroAddress {
+		privateKey = strings.TrimPrefix(r.expand(wallet.PrivateKey, fmt.Sprintf("wallet %s private_key", name)), "0x")
+	}
+	if len(r.missing) > missing {
+		return ZeroAddress, "", nil
+	}
+
+	if privateKey == "" {
+		if keys || address == ZeroAddress {
+			return ZeroAddress, "", fmt.Errorf("wallet %s private_key: missing", name)
+		}
+		return address, "", nil
+	}
+	key, err := crypto.HexToECDSA(privateKey)
+	if err != nil {
+		return ZeroAddress, "", fmt.Errorf("wallet %s private_key: invalid key", name)
+	}
+	derived := crypto.PubkeyToAddress(key.PublicKey)
+	if address != ZeroAddress && address != derived {
+		return ZeroAddress, "", fmt.Errorf("wallet %s address: %s is not the address %s of the private key", name, address.Hex(), derived.Hex())
+	}
+	return derived, privateKey, nil
+}
+
+// parsePool validates a pool and builds the options of its protocol.
+// The sender and the relay, which depend on the secrets, are resolved by Parse for the selected pools.
+func parsePool(pool filePool, wallets map[string]fileWallet, oracles map[models.Chain]common.Address) (models.ProtocolOpts, []error) {
+	var errs []error
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	opts := models.PoolOpts{
+		ProfitableThreshold:     pool.ProfitableThreshold,
+		GasUsedDefault:          pool.GasUsedDefault,
+		ExtraPriorityFeePercent: pool.ExtraPriorityFeePercent,
+		TipEstimator:            models.TipEstimatorKind(strings.ToUpper(pool.TipEstimator)),
+		TipPercentile:           pool.TipPercentile,
+		Submission:              models.SubmissionKind(strings.ToUpper(pool.Submission)),
+		Chain:                   models.Chain(strings.ToUpper(pool.Chain)),
+		Protocol:                models.Protocol(strings.ToUpper(pool.Protocol)),
+		Pool:                    models.Pool(strings.ToUpper(pool.Pool)),
+		PriorityFee:             pool.PriorityFee.Int,
+		BlockRange:              pool.BlockRange.Int,
+	}
+
+	oracle, chainOk := oracles[opts.Chain]
+	if !chainOk {
+		errs = append(errs, fmt.Errorf("chain %q is not configured", pool.Chain))
+	}
+	if !protocols[opts.Protocol] {
+		errs = append(errs, fmt.Errorf("unknown protocol %q", pool.Protocol))
+	}
+	if opts.Pool == "" {
+		errs = append(errs, errors.New("pool: missing"))
+	}
+	if _, ok := wallets[pool.Wallet]; !ok {
+		errs = append(errs, fmt.Errorf("wallet %q is not configured", pool.Wallet))
+	}
+
+	var err error
+	opts.ContractLender, err = parseAddress("lender", pool.Lender, true)
+	check(err)
+	opts.ContractGauge, err = parseAddress("gauge", pool.Gauge, true)
+	check(err)
+	opts.RewardToken, err = parseAddress("reward_token", pool.RewardToken, false)
+	check(err)
+	opts.ContractGasPriceOracle, err = parseAddress("gas_price_oracle", pool.GasPriceOracle, false)
+	check(err)
+	if opts.ContractGasPriceOracle == ZeroAddress {
+		opts.ContractGasPriceOracle = oracle
+	}
+
+	check(positive("priority_fee", opts.PriorityFee, true))
+	check(positive("block_range", opts.BlockRange, false))
+	check(positive("reinvest_bounty", pool.ReinvestBounty.Int, false))
+	check(positive("reward_rate", pool.RewardRate.Int, false))
+	if opts.GasUsedDefault == 0 {
+		errs = append(errs, errors.New("gas_used_default: missing"))
+	}
+	if low, high := opts.ExtraPriorityFeePercent[0], opts.ExtraPriorityFeePercent[1]; low < 0 || low > high {
+		errs = append(errs, fmt.Errorf("extra_priority_fee_percent: invalid range [%d, %d]", low, high))
+	}
+	if !tipEstimators[opts.TipEstimator] {
+		errs = append(errs, fmt.Errorf("unknown tip_estimator %q", pool.TipEstimator))
+	}
+	if opts.TipPercentile < 0 || opts.TipPercentile > 100 {
+		errs = append(errs, fmt.Errorf("tip_percentile: %v is not between 0 and 100", opts.TipPercentile))
+	}
+	if !submissions[opts.Submission] {
+		errs = append(errs, fmt.Errorf("unknown submission %q", pool.Submission))
+	}
+
+	rewardEstimator := models.RewardEstimatorKind(strings.ToUpper(pool.RewardEstimator))
+	if !rewardEstimators[rewardEstimator] {
+		errs = append(errs, fmt.Errorf("unknown reward_estimator %q", pool.RewardEstimator))
+	}
+	if rewardEstimator != "" && opts.Protocol != models.Tarot {
+		errs = append(errs, fmt.Errorf("reward_estimator: not supported by %s", opts.Protocol))
+	}
+	if pool.ValidateReward && opts.Protocol != models.Tarot {
+		errs = append(errs, fmt.Errorf("validate_reward: not supported by %s", opts.Protocol))
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	switch opts.Protocol {
+	case models.Impermax:
+		return &models.ImpermaxOpts{PoolOpts: opts, ReinvestBounty: pool.ReinvestBounty.Int, RewardRate: pool.RewardRate.Int}, nil
+	default:
+		return &models.TarotOpts{PoolOpts: opts, ReinvestBounty: pool.ReinvestBounty.Int, RewardRate: pool.RewardRate.Int, RewardEstimator: rewardEstimator, ValidateReward: pool.ValidateReward}, nil
+	}
+}
+
+// parseAddress validates an address, the zero address when it is optional and empty.
+func parseAddress(field string, value string, required bool) (common.Address, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		if required {
+			return ZeroAddress, fmt.Errorf("%s: missing", field)
+		}
+		return ZeroAddress, nil
+	}
+	if !common.IsHexAddress(value) {
+		return ZeroAddress, fmt.Errorf("%s: invalid address %q", field, value)
+	}
+	return common.HexToAddress(value), nil
+}
+
+// positive checks an integer of the file is set and positive, or zero when allowed.
+func positive(field string, value *big.Int, allowZero bool) error {
+	switch {
+	case value == nil:
+		return fmt.Errorf("%s: missing", field)
+	case value.Sign() < 0, value.Sign() == 0 && !allowZero:
+		return fmt.Errorf("%s: %s is not positive", field, value)
+	}
+	return nil
+}
+
+// sortedNames returns the names of a section of the file in order, so the errors are always listed in the same order.
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
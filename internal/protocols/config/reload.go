@@ -0,0 +1,178 @@
+package config
+
+import (
+	"context"
+	"defibotgo/internal/models"
+	"github.com/rs/zerolog/log"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ReloadPollInterval is the interval the configuration file is checked for changes at
+const ReloadPollInterval = 5 * time.Second
+
+// Reloader re-reads the configuration file and applies the changed tunables to the running pools.
+//
+// The other options of a pool (contracts, wallet, estimators...) are fixed for its run: their changes, like the
+// added and removed pools, are logged and applied by a restart.
+type Reloader struct {
+	path      string
+	selection Selection
+
+	mu      sync.Mutex
+	current *Config
+	modTime time.Time
+	size    int64
+	live    map[poolKey]*models.LiveOpts
+}
+
+// poolKey identifies a pool in the configuration file
+type poolKey struct {
+	chain    models.Chain
+	protocol models.Protocol
+	pool     models.Pool
+}
+
+func keyOf(opts *models.PoolOpts) poolKey {
+	return poolKey{chain: opts.Chain, protocol: opts.Protocol, pool: opts.Pool}
+}
+
+// NewReloader returns the reloader of a configuration file.
+//
+// Parameters:
+//   - path: The path of the configuration file.
+//   - selection: The pools loaded from the file, whose secrets are resolved.
+//   - cfg: The configuration loaded from the file at startup.
+//
+// Returns:
+//   - *Reloader: The reloader, without running pools.
+func NewReloader(path string, selection Selection, cfg *Config) *Reloader {
+	r := &Reloader{path: path, selection: selection, current: cfg, live: map[poolKey]*models.LiveOpts{}}
+	if info, err := os.Stat(path); err == nil {
+		r.modTime, r.size = info.ModTime(), info.Size()
+	}
+	return r
+}
+
+// Opts returns the live options of a running pool, whose tunables follow the reloads.
+//
+// Parameters:
+//   - protocolOpts: The options of the pool at startup.
+//
+// Returns:
+//   - *models.LiveOpts: The options of the pool, the same for every call on the pool.
+func (r *Reloader) Opts(protocolOpts models.ProtocolOpts) *models.LiveOpts {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := keyOf(protocolOpts.Opts())
+	live, ok := r.live[key]
+	if !ok {
+		live = models.NewLiveOpts(protocolOpts)
+		r.live[key] = live
+	}
+	return live
+}
+
+// Reload reads and validates the configuration file, then replaces the options of the running pools whose tunables
+// changed. Nothing is applied when the file is invalid.
+//
+// Returns:
+//   - error: An error if the file can't be read or is invalid.
+func (r *Reloader) Reload() error {
+	cfg, err := Load(r.path, r.selection)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, live := range r.live {
+		current := live.Load()
+		next, err := cfg.Pool(key.chain, key.protocol, key.pool)
+		if err != nil {
+			log.Warn().Str("chain", string(key.chain)).Str("pool", string(key.pool)).Msg("Pool removed from the config file, restart to stop it")
+			continue
+		}
+
+		tunables := next.Opts().Tunables()
+		if !reflect.DeepEqual(withTunables(current, tunables), next) {
+			log.Warn().Str("chain", string(key.chain)).Str("pool", string(key.pool)).Msg("Pool options other than the tunables changed, restart to apply them")
+		}
+		changes := current.Opts().Tunables().Diff(tunables)
+		if len(changes) == 0 {
+			continue
+		}
+		live.Store(withTunables(current, tunables))
+		log.Info().Str("chain", string(key.chain)).Str("pool", string(key.pool)).Strs("changes", changes).Msg("Reloaded pool tunables")
+	}
+
+	for _, protocolOpts := range cfg.Pools {
+		opts := protocolOpts.Opts()
+		if _, err := r.current.Pool(opts.Chain, opts.Protocol, opts.Pool); err != nil {
+			log.Warn().Str("chain", string(opts.Chain)).Str("pool", string(opts.Pool)).Msg("Pool added to the config file, restart to run it")
+		}
+	}
+	r.current = cfg
+	return nil
+}
+
+// Watch reloads the configuration file on every signal and whenever the file changes, until the context is canceled.
+//
+// Parameters:
+//   - ctx: The context stopping the watch.
+//   - hup: The signals asking for a reload, usually SIGHUP.
+//   - interval: The interval the file is checked for changes at.
+func (r *Reloader) Watch(ctx context.Context, hup <-chan os.Signal, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var trigger string
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			trigger = "signal"
+			r.changed()
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			trigger = "file change"
+		}
+
+		if err := r.Reload(); err != nil {
+			log.Error().Err(err).Str("trigger", trigger).Msg("Failed to reload the config file, keeping the current options")
+		}
+	}
+}
+
+// changed reports whether the configuration file was modified since the last check.
+func (r *Reloader) changed() bool {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if info.ModTime().Equal(r.modTime) && info.Size() == r.size {
+		return false
+	}
+	r.modTime, r.size = info.ModTime(), info.Size()
+	return true
+}
+
+// withTunables returns a copy of the options of a pool with other tunables.
+func withTunables(protocolOpts models.ProtocolOpts, tunables models.Tunables) models.ProtocolOpts {
+	clone := reflect.New(reflect.TypeOf(protocolOpts).Elem())
+	clone.Elem().Set(reflect.ValueOf(protocolOpts).Elem())
+
+	cloned := clone.Interface().(models.ProtocolOpts)
+	cloned.Opts().SetTunables(tunables)
+	return cloned
+}
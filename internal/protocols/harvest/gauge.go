@@ -0,0 +1,192 @@
+package harvest
+
+import (
+	"defibotgo/internal/accounting"
+	"defibotgo/internal/contract_abi"
+	"defibotgo/internal/models"
+	"defibotgo/internal/utils"
+	"defibotgo/internal/web3"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+	"math/big"
+)
+
+// BlockTime is the number of seconds between two blocks
+var BlockTime = int64(2)
+
+func ComputeReward(vaultPendingReward *big.Int, reinvestBounty *big.Int) *big.Int {
+	// Calculate bounty = reward * REINVEST_BOUNTY / 1e18
+	bounty := new(big.Int).Mul(vaultPendingReward, reinvestBounty) // reward * reinvestBounty
+	bounty.Div(bounty, utils.OneE18)                               // divide by 1e18
+
+	return bounty
+}
+
+// GetVaultPendingReward predicts the next earned reward based on last mined block values
+func GetVaultPendingReward(
+	lastEarned *big.Int,   // earned(account) from last mined block
+	rewardRate *big.Int,   // tokens emitted per second
+	expectedSeconds int64, // estimated seconds until your tx is mined
+	balanceOf *big.Int,    // contract LP token balance
+	totalSupply *big.Int,  // total LP supply in gauge
+) *big.Int {
+	log.Debug().Str("earned", lastEarned.String()).Str("rewardRate", rewardRate.String()).Str("totalSupply", totalSupply.String()).Str("balanceOf", balanceOf.String()).Int64("seconds", expectedSeconds).Msg("")
+	rewardRateTimesSeconds := new(big.Int).Mul(rewardRate, big.NewInt(expectedSeconds))
+	userRewardPortion := new(big.Int).Mul(rewardRateTimesSeconds, balanceOf)
+	additionalReward := new(big.Int).Div(userRewardPortion, totalSupply)
+	estimateReward := new(big.Int).Add(lastEarned, additionalReward)
+
+	return estimateReward
+}
+
+// UnpackCalls decodes the multicall output of the calls into one result per call, in the calls order.
+func UnpackCalls(multicall *web3.Multicall, calls []web3.ContractCall, result *web3.BatchResult[[]byte]) []models.WeiResult {
+	values := make([]models.WeiResult, len(calls))
+
+	err := result.Err
+	var callResults []web3.CallResult
+	if err == nil {
+		callResults, err = multicall.Unpack(calls, result.Value)
+	}
+
+	for i := range values {
+		if err != nil {
+			values[i] = models.WeiResult{Value: nil, Err: fmt.Errorf("failed to call multicall: %v", err)}
+			continue
+		}
+
+		value, callErr := callResults[i].BigInt()
+		values[i] = models.WeiResult{Value: value, Err: callErr}
+	}
+
+	return values
+}
+
+// GaugeHarvester harvests a lender staking in a gauge, its earned is extrapolated from the gauge read at the head.
+// The harvesters of the protocols whose lenders stake in a gauge embed it, and add the lender specifics.
+type GaugeHarvester struct {
+	opts           *models.PoolOpts
+	reinvestBounty *big.Int
+	rewardRate     *big.Int
+	lenderAbi      abi.ABI
+	gaugeAbi       abi.ABI
+	multicall      *web3.Multicall
+
+	// The gauge calls are earned, balanceOf, totalSupply and rewardRate, in this order
+	gaugeCalls   []web3.ContractCall
+	gaugeCallMsg ethereum.CallMsg
+}
+
+// NewGaugeHarvester builds the harvester of a lender staking in a gauge.
+//
+// Parameters:
+//   - ethClient: The client the multicall reads the gauge with.
+//   - opts: The options of the pool.
+//   - lenderAbi: The ABI of the lender, with its Reinvest event.
+//   - reinvestBounty: The share of the reward paid to the caller of reinvest, in 1e18.
+//   - rewardRate: The reward rate used when the gauge fails to return it.
+//
+// Returns:
+//   - *GaugeHarvester: The harvester of the lender.
+//   - error: An error if the gauge abi can't be loaded or the calls can't be built.
+func NewGaugeHarvester(ethClient web3.Client, opts *models.PoolOpts, lenderAbi abi.ABI, reinvestBounty *big.Int, rewardRate *big.Int) (*GaugeHarvester, error) {
+	gaugeAbi, err := web3.LoadAbi(contract_abi.CONTRACT_ABI_GAUGE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load gauge abi: %w", err)
+	}
+	multicall, err := web3.NewMulticall(ethClient, web3.Multicall3Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build multicall: %w", err)
+	}
+
+	h := &GaugeHarvester{opts: opts, reinvestBounty: reinvestBounty, rewardRate: rewardRate, lenderAbi: lenderAbi, gaugeAbi: gaugeAbi, multicall: multicall}
+	h.gaugeCalls = []web3.ContractCall{
+		{Address: opts.ContractGauge, Abi: &h.gaugeAbi, Method: "earned", Params: []interface{}{opts.ContractLender}},
+		{Address: opts.ContractGauge, Abi: &h.gaugeAbi, Method: "balanceOf", Params: []interface{}{opts.ContractLender}},
+		{Address: opts.ContractGauge, Abi: &h.gaugeAbi, Method: "totalSupply"},
+		{Address: opts.ContractGauge, Abi: &h.gaugeAbi, Method: "rewardRate", AllowFailure: true},
+	}
+
+	// The gauge calls never change, pack them once
+	multicallAddress := multicall.Address()
+	h.gaugeCallMsg = ethereum.CallMsg{From: opts.Sender, To: &multicallAddress}
+	if h.gaugeCallMsg.Data, err = multicall.Pack(h.gaugeCalls); err != nil {
+		return nil, fmt.Errorf("failed to pack gauge calls: %w", err)
+	}
+
+	return h, nil
+}
+
+func (h *GaugeHarvester) Abi() *abi.ABI {
+	return &h.lenderAbi
+}
+
+// Multicall returns the multicall the gauge is read with.
+func (h *GaugeHarvester) Multicall() *web3.Multicall {
+	return h.multicall
+}
+
+// GaugeCalls returns the calls of earned, balanceOf, totalSupply and rewardRate on the gauge, in this order.
+func (h *GaugeHarvester) GaugeCalls() []web3.ContractCall {
+	return h.gaugeCalls
+}
+
+// FetchState reads the gauge at the head, the earned of the next block is extrapolated with the reward rate.
+func (h *GaugeHarvester) FetchState(batch *web3.Batch, head *types.Header) func() (State, error) {
+	gaugeResult := batch.CallContract(h.gaugeCallMsg, head.Number)
+
+	return func() (State, error) {
+		// The gauge values follow the order of the gauge calls
+		gaugeValues := UnpackCalls(h.multicall, h.gaugeCalls, gaugeResult)
+		earned, balance, totalSupply, gaugeRewardRate := gaugeValues[0], gaugeValues[1], gaugeValues[2], gaugeValues[3]
+		if err := errors.Join(earned.Err, balance.Err, totalSupply.Err); err != nil {
+			return State{BlockNumber: head.Number}, err
+		}
+
+		// The reward rate is allowed to fail, fallback on the configured one
+		rewardRate := gaugeRewardRate.Value
+		if gaugeRewardRate.Err != nil {
+			log.Warn().Err(gaugeRewardRate.Err).Str("chain", string(h.opts.Chain)).Msg("Failed to get rewardRate, using the configured one")
+			rewardRate = h.rewardRate
+		}
+
+		return State{
+			BlockNumber: head.Number,
+			Earned:      GetVaultPendingReward(earned.Value, rewardRate, BlockTime, balance.Value, totalSupply.Value),
+		}, nil
+	}
+}
+
+func (h *GaugeHarvester) ComputeOpportunity(state State) *big.Int {
+	return ComputeReward(state.Earned, h.reinvestBounty)
+}
+
+// HarvestQuery returns the query of the Reinvest events of the lender and the RewardPaid or ClaimRewards events of
+// the gauge.
+func (h *GaugeHarvester) HarvestQuery() ethereum.FilterQuery {
+	return ethereum.FilterQuery{
+		Addresses: []common.Address{h.opts.ContractLender, h.opts.ContractGauge},
+		Topics:    [][]common.Hash{{h.lenderAbi.Events["Reinvest"].ID, h.gaugeAbi.Events["RewardPaid"].ID, h.gaugeAbi.Events["ClaimRewards"].ID}},
+	}
+}
+
+// IsHarvest reports whether the log is a reinvest, or a payment of the rewards of the lender by the gauge, which
+// pays the rewards of every depositor.
+func (h *GaugeHarvester) IsHarvest(harvestLog types.Log) bool {
+	if len(harvestLog.Topics) < 2 {
+		return false
+	}
+	if harvestLog.Address == h.opts.ContractGauge {
+		return common.BytesToAddress(harvestLog.Topics[1].Bytes()) == h.opts.ContractLender
+	}
+	return harvestLog.Address == h.opts.ContractLender
+}
+
+func (h *GaugeHarvester) PostProcessReceipt(receipt *web3.OpReceipt, rewardPair *big.Int) accounting.Harvest {
+	return accounting.Realize(receipt, h.opts.Chain, h.opts.Pool, h.opts.Sender, h.opts.RewardToken, rewardPair)
+}
@@ -0,0 +1,113 @@
+package harvest
+
+import (
+	"context"
+	"defibotgo/internal/accounting"
+	"defibotgo/internal/competition"
+	"defibotgo/internal/utils"
+	"defibotgo/internal/web3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+	"math/big"
+	"sync"
+)
+
+// Guard invalidates the iteration in flight when the reward of the vault is harvested by someone else:
+// the earned predicted on an older block is then near zero and the reinvest would be unprofitable.
+type Guard struct {
+	mu          sync.Mutex
+	lastHarvest uint64             // The block of the last harvest seen
+	block       uint64             // The block the iteration in flight read its values at
+	cancel      context.CancelFunc // Cancels the iteration in flight, nil when there is none
+}
+
+// Start derives the context of an iteration on the given block, canceled by a harvest mined after it.
+func (g *Guard) Start(parent context.Context, block *big.Int) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.block = block.Uint64()
+	g.cancel = cancel
+	if g.lastHarvest > g.block {
+		cancel()
+	}
+
+	return ctx, func() {
+		g.mu.Lock()
+		g.cancel = nil
+		g.mu.Unlock()
+		cancel()
+	}
+}
+
+// Harvested records a harvest mined in the given block and cancels the iteration in flight if it read an older one.
+//
+// Returns:
+//   - bool: Whether an iteration was canceled.
+func (g *Guard) Harvested(block uint64) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.lastHarvest = max(g.lastHarvest, block)
+	if g.cancel == nil || block <= g.block {
+		return false
+	}
+	g.cancel()
+	g.cancel = nil
+	return true
+}
+
+// Stale reports whether a harvest was mined after the given block, the values read at it being outdated.
+func (g *Guard) Stale(block *big.Int) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.lastHarvest > block.Uint64()
+}
+
+// WatchHarvests follows the logs revealing that the vault was harvested, and reports each harvest to the guard as
+// soon as it is mined. The reinvests of competitors are recorded in the ledger.
+//
+// Parameters:
+//   - ctx: The context stopping the watch.
+//   - ethClient: The client the logs are read from.
+//   - harvester: The harvester of the pool.
+//   - guard: The guard of the iterations of the pool.
+//   - ledger: The ledger the competitor reinvests are recorded in.
+func WatchHarvests(ctx context.Context, ethClient web3.Client, harvester Harvester, guard *Guard, ledger accounting.Ledger) {
+	opts := harvester.Opts().Opts()
+	lenderAbi := harvester.Abi()
+	reinvestId := lenderAbi.Events["Reinvest"].ID
+
+	for harvestLog := range web3.WatchLogs(ctx, ethClient, harvester.HarvestQuery(), utils.HeadPollSleep) {
+		if !harvester.IsHarvest(harvestLog) {
+			continue
+		}
+		isReinvest := harvestLog.Address == opts.ContractLender && len(harvestLog.Topics) > 1 && harvestLog.Topics[0] == reinvestId
+		// Our own reinvests are followed by their receipt
+		if isReinvest && common.BytesToAddress(harvestLog.Topics[1].Bytes()) == opts.Sender {
+			continue
+		}
+
+		if guard.Harvested(harvestLog.BlockNumber) {
+			log.Warn().Str("chain", string(opts.Chain)).Uint64("block", harvestLog.BlockNumber).Str("hash", harvestLog.TxHash.Hex()).Msg("Vault harvested, canceled the iteration in flight")
+		}
+
+		if !isReinvest {
+			continue
+		}
+		reinvest, err := competition.DecodeReinvest(lenderAbi, harvestLog, opts.Chain, opts.Pool)
+		if err != nil {
+			log.Error().Err(err).Str("chain", string(opts.Chain)).Str("hash", harvestLog.TxHash.Hex()).Msg("Failed to decode competitor reinvest")
+			continue
+		}
+		log.Info().
+			Str("chain", string(opts.Chain)).
+			Str("pool", string(opts.Pool)).
+			Str("sender", reinvest.Sender.Hex()).
+			Uint64("block", reinvest.BlockNumber).
+			Str("bounty", reinvest.Bounty.String()).
+			Msg("Competitor harvested")
+		ledger.RecordReinvest(reinvest)
+	}
+}
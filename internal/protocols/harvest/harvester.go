@@ -0,0 +1,70 @@
+package harvest
+
+import (
+	"defibotgo/internal/accounting"
+	"defibotgo/internal/models"
+	"defibotgo/internal/web3"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+)
+
+// State is the state of a vault read at a block
+type State struct {
+	BlockNumber *big.Int // The block the state was read at
+	Earned      *big.Int // The reward pending in the vault, predicted for the block following the one read
+}
+
+// Harvester is a protocol whose vaults are harvested by calling a contract paying a bounty to the caller.
+// Run drives the harvesters: it reads their state on every block, prices the opportunity against the gas fees,
+// and sends the harvest calls which are worth it.
+type Harvester interface {
+	// Opts returns the options of the pool harvested.
+	Opts() models.ProtocolOpts
+	// Abi returns the ABI of the harvested contract, decoding its reverts and its Reinvest events.
+	Abi() *abi.ABI
+	// FetchState adds the reads of the state of the vault at the head to the batch, and returns the function
+	// resolving them once the batch is sent.
+	FetchState(batch *web3.Batch, head *types.Header) func() (State, error)
+	// ComputeOpportunity returns the bounty paid for harvesting the vault in the given state, in reward token.
+	ComputeOpportunity(state State) *big.Int
+	// BuildCallData returns the data of the harvest call sent to the lender.
+	BuildCallData() ([]byte, error)
+	// HarvestQuery returns the query of the logs which may reveal that the vault was harvested.
+	HarvestQuery() ethereum.FilterQuery
+	// IsHarvest reports whether a log of the harvest query harvested the vault.
+	IsHarvest(harvestLog types.Log) bool
+	// PostProcessReceipt realizes the result of a mined harvest from its receipt.
+	PostProcessReceipt(receipt *web3.OpReceipt, rewardPair *big.Int) accounting.Harvest
+}
+
+// Factory builds the harvester of a pool from its options, which must be the options of the protocol.
+type Factory func(ethClient web3.Client, opts models.ProtocolOpts) (Harvester, error)
+
+// Registry maps every protocol to the factory of its harvesters
+type Registry map[models.Protocol]Factory
+
+// New builds the harvester of a pool with the factory of its protocol.
+//
+// Parameters:
+//   - ethClient: The client the harvester reads the chain with.
+//   - opts: The options of the pool.
+//
+// Returns:
+//   - Harvester: The harvester of the pool.
+//   - error: An error if the protocol has no factory or the harvester can't be built.
+func (r Registry) New(ethClient web3.Client, opts models.ProtocolOpts) (Harvester, error) {
+	protocol := opts.Opts().Protocol
+	factory, ok := r[protocol]
+	if !ok {
+		return nil, fmt.Errorf("no harvester registered for protocol=%s", protocol)
+	}
+
+	harvester, err := factory(ethClient, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build %s harvester: %w", protocol, err)
+	}
+	return harvester, nil
+}
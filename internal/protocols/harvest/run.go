@@ -0,0 +1,702 @@
+package harvest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"defibotgo/internal/accounting"
+	"defibotgo/internal/contract_abi"
+	"defibotgo/internal/models"
+	"defibotgo/internal/services/asyncservices"
+	"defibotgo/internal/utils"
+	"defibotgo/internal/web3"
+	"defibotgo/internal/web3/web3Async"
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/ristretto"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+	"math/big"
+	"sync"
+	"time"
+)
+
+type CalculationOpts struct {
+	// Block every value of the iteration was read at
+	BlockNumber *big.Int
+
+	// Hot/Cached fields
+	VaultPendingRewardValue *big.Int //  8 bytes
+	RewardValue             *big.Int //  8 bytes, the bounty of the harvest in reward token
+	BaseFeeValue            *big.Int //  8 bytes
+	RewardPairValue         *big.Int //  8 bytes
+	PriorityFeeValue        *big.Int //  8 bytes
+	EstimateGasLimitValue   uint64   //  8 bytes
+
+	// “Cold” result structs
+	VaultPendingReward models.WeiResult      // 24 bytes
+	BaseFeePerGas      models.WeiResult      // 24 bytes
+	RewardPair         models.WeiResult      // 24 bytes
+	PriorityFee        models.WeiResult      // 24 bytes
+	EstimateGasLimit   models.GasLimitResult // 24 bytes
+}
+
+var (
+	zeroValue               = big.NewInt(0)
+	gasLimitExtraPercent    = uint64(30)
+	gasLimitUsedExpectedMin = uint64(100000)
+	pendingTxTimeout        = time.Minute * 2
+)
+
+// Resources are the clients and caches a pool shares with the other pools of its chain and of its wallet
+type Resources struct {
+	EthClient       web3.Client        // Reads the chain
+	EthClientWriter web3.Client        // Sends the transactions
+	PriceCache      *ristretto.Cache   // Caches the reward pair value of the chain, see NewPriceCache
+	NonceManager    *web3.NonceManager // Hands out the nonces of the sender
+	Ledger          accounting.Ledger  // Records the decisions and the harvests
+	Opts            *models.LiveOpts   // The options of the pool, reloaded while it runs; those of the harvester when nil
+}
+
+// NewPriceCache returns the cache of the off-chain values of a chain, the on-chain reads being pinned to the block
+// of the iteration.
+func NewPriceCache() (*ristretto.Cache, error) {
+	return ristretto.NewCache(&ristretto.Config{
+		NumCounters: 100, // ~16× counters to minimize collisions and maximize hit rate
+		MaxCost:     768, // 6 keys × 128 bytes each (generous overhead to avoid evictions)
+		BufferItems: 64,  // Recommended default for smooth eviction buffering
+	})
+}
+
+// Run harvests the vault of the pool on every block where the bounty is worth the gas fees, until the context is
+// canceled.
+//
+// Parameters:
+//   - rootCtx: The context stopping the harvests.
+//   - harvester: The harvester of the pool.
+//   - walletPrivateKey: The key of the sender of the pool.
+//   - resources: The clients, caches, nonces and ledger shared with the other pools.
+//
+// Returns:
+//   - error: An error if the pool can't be run, nil once the context is canceled.
+func Run(rootCtx context.Context, harvester Harvester, walletPrivateKey *ecdsa.PrivateKey, resources Resources) error {
+	protocolOpts := harvester.Opts()
+	opts := protocolOpts.Opts()
+	ethClient, ethClientWriter, cache, nonceManager, ledger := resources.EthClient, resources.EthClientWriter, resources.PriceCache, resources.NonceManager, resources.Ledger
+
+	chainID, err := ethClientWriter.ChainID(rootCtx)
+	if err != nil {
+		return fmt.Errorf("failed to get chain id: %w", err)
+	}
+
+	// Init the channels needed for computing reward and gas fee
+	rewardPairValueChan := make(chan models.WeiResult, 1)
+
+	contractGasPriceOracle, callOpts, callMsg, lenderCallData, err := buildOpts(ethClient, harvester)
+	if err != nil {
+		return err
+	}
+	signer, err := web3.SignerForChain(opts.Chain)
+	if err != nil {
+		return fmt.Errorf("failed to build signer: %w", err)
+	}
+	if web3.ChainIDs[opts.Chain].Cmp(chainID) != 0 {
+		return fmt.Errorf("the rpc endpoints serve the chain id %v, not %s", chainID, opts.Chain)
+	}
+
+	// The lender ABI decodes the revert reasons of the simulated reinvests
+	lenderAbi := harvester.Abi()
+
+	// The reinvests, and their replacements, are broadcast the way configured for the pool
+	submitter, err := web3.NewSubmitter(opts.Submission, ethClientWriter, opts.RelayUrl, walletPrivateKey)
+	if err != nil {
+		return fmt.Errorf("failed to build transaction submitter: %w", err)
+	}
+
+	// Stuck reinvests are sped up while profitable, cancelled otherwise
+	pendingTxs := web3.NewPendingTxManager(ethClientWriter, submitter, nonceManager, signer, walletPrivateKey, web3.DefaultPendingTxOpts)
+
+	tipEstimator, err := web3.NewTipEstimator(opts.TipEstimator, signer, opts.Sender, opts.ContractLender, opts.BlockRange, opts.TipPercentile)
+	if err != nil {
+		return fmt.Errorf("failed to build tip estimator: %w", err)
+	}
+
+	// The options the pool runs with, to be compared with its iterations
+	ledger.RecordPool(protocolOpts)
+	liveOpts := resources.Opts
+	if liveOpts == nil {
+		liveOpts = models.NewLiveOpts(protocolOpts)
+	}
+
+	// The watchers stop with the run, which may be restarted
+	runCtx, runCancelCtx := context.WithCancel(rootCtx)
+	defer runCancelCtx()
+
+	// A harvest of the vault, by anyone, resets its earned: the iteration in flight on an older block is canceled
+	guard := &Guard{}
+	go WatchHarvests(runCtx, ethClient, harvester, guard, ledger)
+
+	// Run one evaluation per new block, as soon as it is known
+	heads := web3.WatchHeads(runCtx, ethClient, utils.HeadPollSleep)
+
+	for {
+		var head *types.Header
+		select {
+		case <-rootCtx.Done():
+			log.Info().Str("chain", string(opts.Chain)).Str("pool", string(opts.Pool)).Msg("ctx canceled, exiting harvest.Run")
+			return nil
+		case head = <-heads:
+			// new block, proceed
+		}
+
+		if head == nil {
+			// the watcher is closed when the context is canceled
+			continue
+		}
+
+		// The vault was harvested after this head, its values are outdated
+		if guard.Stale(head.Number) {
+			continue
+		}
+
+		// The reloaded tunables apply from the iteration boundary, never in the middle of an iteration
+		if current := liveOpts.Load(); current != protocolOpts {
+			protocolOpts, opts = current, current.Opts()
+			ledger.RecordPool(protocolOpts)
+		}
+
+		// Every read of the iteration is pinned to the block of the head, and canceled by a newer harvest
+		guardCtx, guardCancelCtx := guard.Start(rootCtx, head.Number)
+		iterCtx, iterCancelCtx := context.WithTimeout(guardCtx, time.Second*10)
+		callOpts.Context = iterCtx
+		callOpts.BlockNumber = head.Number
+
+		// The on-chain reads of the iteration are sent in a single JSON-RPC batch
+		batch := web3.NewBatch()
+		stateResolver := harvester.FetchState(batch, head)
+		estimateGasResult := batch.EstimateGas(callMsg, head.Number)
+		tipResolver := tipEstimator.Prepare(batch, head.Number)
+
+		// Keep the code in a block to avoid overhead from additional function calls (optimizing execution time)
+		calculationOpts := &CalculationOpts{BlockNumber: head.Number}
+		var wg sync.WaitGroup
+		wg.Add(2)
+
+		// Call web3 api asynchronously
+		go web3Async.SendBatchAsync(iterCtx, ethClient, batch, &wg)
+		go asyncservices.GetPoolPriceAsync(opts.Chain, cache, "4", rewardPairValueChan, &wg)
+
+		// Wait for goroutines
+		wg.Wait()
+
+		// Get the results, the base fee comes from the head itself
+		state, stateErr := stateResolver()
+		calculationOpts.VaultPendingReward = models.WeiResult{Value: state.Earned, Err: stateErr}
+		calculationOpts.BaseFeePerGas = models.WeiResult{Value: head.BaseFee}
+		if head.BaseFee == nil {
+			calculationOpts.BaseFeePerGas.Err = fmt.Errorf("block %v has no base fee", head.Number)
+		}
+		calculationOpts.EstimateGasLimit = models.GasLimitResult{Value: estimateGasResult.Value, Err: estimateGasResult.Err}
+		calculationOpts.RewardPair = <-rewardPairValueChan
+		calculationOpts.PriorityFee.Value, calculationOpts.PriorityFee.Err = tipResolver(iterCtx, ethClient)
+
+		batchMetrics := web3.GetBatchMetrics()
+		log.Debug().Uint64("requests", batchMetrics.Requests).Uint64("roundTrips", batchMetrics.RoundTrips).Msg("JSON-RPC batch metrics")
+
+		if calculationOpts.VaultPendingReward.Err != nil || calculationOpts.BaseFeePerGas.Err != nil || calculationOpts.EstimateGasLimit.Err != nil || calculationOpts.RewardPair.Err != nil || calculationOpts.PriorityFee.Err != nil {
+			if guardCtx.Err() != nil {
+				// The reads were canceled by a harvest, move on to the next head
+				iterCancelCtx()
+				guardCancelCtx()
+				log.Warn().Str("chain", string(opts.Chain)).Str("block", head.Number.String()).Msg("Iteration canceled by a harvest")
+				continue
+			}
+			log.Error().
+				Str("chain", string(opts.Chain)).
+				Str("block", head.Number.String()).
+				AnErr("pendingRewardError", calculationOpts.VaultPendingReward.Err).
+				AnErr("baseFeeError", calculationOpts.BaseFeePerGas.Err).
+				AnErr("gasLimitError", calculationOpts.EstimateGasLimit.Err).
+				AnErr("rewardPairError", calculationOpts.RewardPair.Err).
+				AnErr("priorityFeeError", calculationOpts.PriorityFee.Err).
+				Msg("Failed to calculate transaction parameters")
+			iterCancelCtx()
+			guardCancelCtx()
+			select {
+			case <-rootCtx.Done():
+				return nil
+			case <-time.After(utils.RetryErrorSleep):
+			}
+			continue
+		}
+
+		// Set values for direct access to avoid deeply nested references
+		calculationOpts.VaultPendingRewardValue = state.Earned
+		calculationOpts.RewardValue = harvester.ComputeOpportunity(state)
+		calculationOpts.BaseFeeValue = calculationOpts.BaseFeePerGas.Value
+		calculationOpts.EstimateGasLimitValue = calculationOpts.EstimateGasLimit.Value
+		calculationOpts.PriorityFeeValue = calculationOpts.PriorityFee.Value
+		calculationOpts.RewardPairValue = calculationOpts.RewardPair.Value
+
+		// Add extra priority fees to make it unpredictable
+		priorityFeeExtraPercent := utils.RandomNumberInRange(opts.ExtraPriorityFeePercent[0], opts.ExtraPriorityFeePercent[1])
+		isL2Worth, l2GasOpts, rewardEth, err := GetL2TransactionGasFees(opts, calculationOpts, priorityFeeExtraPercent, gasLimitExtraPercent)
+		if err != nil {
+			log.Error().Err(err).Str("chain", string(opts.Chain)).Str("block", head.Number.String()).Msgf("Error getting gas on %s", opts.Protocol)
+			iterCancelCtx()
+			if guardCtx.Err() == nil {
+				select {
+				case <-rootCtx.Done():
+					guardCancelCtx()
+					return nil
+				case <-time.After(utils.RetryErrorSleep):
+				}
+			}
+			guardCancelCtx()
+			continue
+		}
+
+		if !isL2Worth {
+			ledger.RecordIteration(newIteration(opts, calculationOpts, rewardEth, l2GasOpts, nil, accounting.DecisionUnworthy))
+			iterCancelCtx()
+			guardCancelCtx()
+			continue
+		}
+
+		// The transaction quoted for the L1 fee is the one sent, sign it with the next nonce of the wallet
+		nonce, err := nonceManager.Acquire(iterCtx)
+		if err != nil {
+			log.Error().Err(err).Str("chain", string(opts.Chain)).Str("block", head.Number.String()).Msg("Error getting nonce")
+			iterCancelCtx()
+			if guardCtx.Err() == nil {
+				select {
+				case <-rootCtx.Done():
+					guardCancelCtx()
+					return nil
+				case <-time.After(utils.RetryErrorSleep):
+				}
+			}
+			guardCancelCtx()
+			continue
+		}
+
+		// Estimate L1 gas fee
+		isWorth, signedTx, l1TransactionFee, err := getL1TransactionGasFees(nonce, chainID, callOpts, l2GasOpts, opts, contractGasPriceOracle, lenderCallData, rewardEth, walletPrivateKey)
+		iterCancelCtx()
+		if err != nil && guardCtx.Err() != nil {
+			// The signing was canceled by a harvest, move on to the next head
+			nonceManager.Release(nonce)
+			guardCancelCtx()
+			log.Warn().Str("chain", string(opts.Chain)).Str("block", head.Number.String()).Msg("Iteration canceled by a harvest")
+			continue
+		}
+		if err != nil {
+			nonceManager.Release(nonce)
+			guardCancelCtx()
+			log.Error().Err(err).Str("chain", string(opts.Chain)).Str("block", head.Number.String()).Msg("Error getting l1 gas fee")
+			select {
+			case <-rootCtx.Done():
+				return nil
+			case <-time.After(utils.RetryErrorSleep):
+			}
+			continue
+		}
+
+		// The reward is lower than the transaction fee estimated
+		if !isWorth {
+			ledger.RecordIteration(newIteration(opts, calculationOpts, rewardEth, l2GasOpts, l1TransactionFee, accounting.DecisionUnprofitable))
+			nonceManager.Release(nonce)
+			guardCancelCtx()
+			continue
+		}
+
+		txCtx, txCancelCtx := context.WithTimeout(guardCtx, time.Second*20)
+
+		// Never pay gas for a reinvest that would revert, e.g. when a competitor already harvested
+		simulationErr := web3.SimulateCall(txCtx, ethClient, callMsg, lenderAbi)
+		if errors.Is(simulationErr, web3.ErrReverted) {
+			ledger.RecordIteration(newIteration(opts, calculationOpts, rewardEth, l2GasOpts, l1TransactionFee, accounting.DecisionReverted))
+			nonceManager.Release(nonce)
+			log.Warn().Err(simulationErr).Str("chain", string(opts.Chain)).Str("block", head.Number.String()).Msg("Reinvest would revert, not sending it")
+			txCancelCtx()
+			if guardCtx.Err() == nil {
+				select {
+				case <-rootCtx.Done():
+					guardCancelCtx()
+					return nil
+				case <-time.After(web3.RecoveryFor(simulationErr).Backoff):
+				}
+			}
+			guardCancelCtx()
+			continue
+		}
+		if simulationErr != nil {
+			log.Warn().Err(simulationErr).Str("chain", string(opts.Chain)).Msg("Failed to simulate reinvest, sending it anyway")
+		}
+
+		// A harvest mined while quoting leaves nothing to reinvest
+		if guardCtx.Err() != nil {
+			nonceManager.Release(nonce)
+			txCancelCtx()
+			guardCancelCtx()
+			log.Warn().Str("chain", string(opts.Chain)).Str("block", head.Number.String()).Msg("Iteration canceled by a harvest")
+			continue
+		}
+
+		// Send transaction on chain, it is meant for the block following the head
+		targetBlock := new(big.Int).Add(head.Number, big.NewInt(1))
+		err = submitter.Submit(txCtx, signedTx, targetBlock)
+
+		if err != nil {
+			ledger.RecordIteration(newIteration(opts, calculationOpts, rewardEth, l2GasOpts, l1TransactionFee, accounting.DecisionSendFailed))
+			// The nonce manager resyncs on the nonce errors, and frees the nonce otherwise
+			err = web3.ClassifyTxError(err)
+			log.Error().Err(err).Str("chain", string(opts.Chain)).Msgf("Failed to send transaction on %s", opts.Protocol)
+			if syncErr := nonceManager.SendFailed(txCtx, nonce, err); syncErr != nil {
+				log.Error().Err(syncErr).Str("chain", string(opts.Chain)).Msg("Failed to resync nonce")
+			}
+			txCancelCtx()
+			if guardCtx.Err() == nil {
+				select {
+				case <-rootCtx.Done():
+					guardCancelCtx()
+					return nil
+				case <-time.After(web3.RecoveryFor(err).Backoff):
+				}
+			}
+			guardCancelCtx()
+			continue
+		}
+		nonceManager.Sent(nonce, signedTx.Hash())
+		txCancelCtx()
+		guardCancelCtx()
+		ledger.RecordIteration(newIteration(opts, calculationOpts, rewardEth, l2GasOpts, l1TransactionFee, accounting.DecisionSent))
+		ledger.RecordTransaction(accounting.SentTransaction{
+			Time:        time.Now().UTC(),
+			Chain:       opts.Chain,
+			Pool:        opts.Pool,
+			Wallet:      opts.Sender,
+			Hash:        signedTx.Hash(),
+			Nonce:       nonce,
+			TargetBlock: targetBlock.Uint64(),
+			GasTipCap:   signedTx.GasTipCap(),
+			GasFeeCap:   signedTx.GasFeeCap(),
+			GasLimit:    signedTx.Gas(),
+		})
+
+		// A speed-up must stay profitable with the L1 fee quoted for the original transaction
+		isProfitable := func(gasOpts *web3.GasOpts) bool {
+			transactionFee := new(big.Int).Add(gasOpts.TransactionFee, l1TransactionFee)
+			return utils.ComputeDifference(rewardEth, transactionFee) > opts.ProfitableThreshold
+		}
+
+		waitCtx, waitCancelCtx := context.WithTimeout(rootCtx, pendingTxTimeout)
+		result, waitErr := waitTransaction(waitCtx, ethClient, pendingTxs, signer, lenderAbi, signedTx, targetBlock, isProfitable, opts)
+		if result != nil && result.Receipt != nil && result.Cancelled {
+			// The cancellation harvested nothing, it only paid its fees
+			logCancellation(rootCtx, ethClientWriter, opts, result.Tx)
+		} else if result != nil && result.Receipt != nil {
+			// Mined, successful or not, the transaction paid its fees
+			predictedFee := new(big.Int).Add(l2GasOpts.TransactionFee, l1TransactionFee)
+			recordHarvest(rootCtx, ethClientWriter, ledger, harvester, result.Tx, calculationOpts.RewardPairValue, rewardEth, predictedFee)
+		}
+		// free resources
+		waitCancelCtx()
+
+		// The vault is empty after a harvest, give it time to refill
+		pause := utils.RetrySuccessSleep
+		if waitErr != nil {
+			recovery := web3.RecoveryFor(waitErr)
+			if recovery.ResyncNonce {
+				// A dropped transaction no longer holds its nonce, the sync hands it out again
+				if syncErr := nonceManager.Sync(rootCtx); syncErr != nil {
+					log.Error().Err(syncErr).Str("chain", string(opts.Chain)).Msg("Failed to resync nonce")
+				}
+			}
+			pause = recovery.Backoff
+			if pause > 0 {
+				log.Error().Msgf("Wait for %v", pause)
+			}
+		} else if result.Cancelled {
+			pause = utils.RetryErrorSleep
+		}
+		if pause > 0 {
+			select {
+			case <-rootCtx.Done():
+				return nil
+			case <-time.After(pause):
+			}
+		}
+	}
+}
+
+func GetL2TransactionGasFees(
+	opts *models.PoolOpts,
+	calculationOpts *CalculationOpts,
+	priorityFeeExtraPercent int,
+	gasLimitExtraPercent uint64,
+) (bool, *web3.GasOpts, *big.Int, error) {
+	// Gas limit is too low to be correct
+	if calculationOpts.EstimateGasLimitValue < gasLimitUsedExpectedMin {
+		log.Debug().Msgf("Update gas used from %v to %v", calculationOpts.EstimateGasLimitValue, opts.GasUsedDefault)
+		calculationOpts.EstimateGasLimitValue = opts.GasUsedDefault
+	}
+
+	//Set new priority fee depending on competitors
+	if calculationOpts.PriorityFeeValue == nil {
+		calculationOpts.PriorityFeeValue = zeroValue
+		return false, nil, nil, fmt.Errorf("priority fee is set to 0")
+	}
+
+	//newPriorityFee := opts.PriorityFee
+	newPriorityFee_ := calculationOpts.PriorityFeeValue
+	if opts.PriorityFee.Cmp(calculationOpts.PriorityFeeValue) == 1 {
+		// Use the highest priority fee for the transaction
+		newPriorityFee_ = opts.PriorityFee
+	}
+	newPriorityFee := utils.IncreaseAmount(newPriorityFee_, priorityFeeExtraPercent)
+
+	rewardToken := calculationOpts.RewardValue
+	rewardEth := utils.ConvertToEth(rewardToken, calculationOpts.RewardPairValue)
+
+	gasOpts := web3.BuildTransactionFeeArgs(calculationOpts.BaseFeeValue, newPriorityFee, calculationOpts.EstimateGasLimitValue)
+	diff := utils.ComputeDifference(rewardEth, gasOpts.TransactionFee)
+	isWorth := diff > -11
+
+	log.Info().Str("block", calculationOpts.BlockNumber.String()).
+		Str("vault pending reward", calculationOpts.VaultPendingRewardValue.String()).
+		Str("reward erc20", rewardToken.String()).
+		Str("reward weth", rewardEth.String()).
+		Str("l2 transaction fee", gasOpts.TransactionFee.String()).
+		Str("l2 base fee", calculationOpts.BaseFeeValue.String()).
+		Str("max fee", gasOpts.GasFeeCap.String()).
+		Str("priority fee", gasOpts.GasTipCap.String()).
+		Uint64("gas limit", gasOpts.GasLimit).
+		Str("reward pair", calculationOpts.RewardPairValue.String()).
+		Float64("l2 diff", diff).
+		Msg("")
+
+	// Increase gas limit to ensure the success of the transaction
+	gasOpts.GasLimit = calculationOpts.EstimateGasLimitValue + (calculationOpts.EstimateGasLimitValue*gasLimitExtraPercent)/100
+
+	return isWorth, gasOpts, rewardEth, nil
+}
+
+func getL1TransactionGasFees(
+	nonce uint64,
+	chainId *big.Int,
+	callOpts *bind.CallOpts,
+	gasOpts *web3.GasOpts,
+	opts *models.PoolOpts,
+	contractGasPriceOracle *bind.BoundContract,
+	toContractCallData []byte,
+	rewardEth *big.Int,
+	walletPrivateKey *ecdsa.PrivateKey,
+) (bool, *types.Transaction, *big.Int, error) {
+	l1GasFee, signedTx, err := web3.GetL1GasFee(nonce, chainId, callOpts, gasOpts, contractGasPriceOracle, &opts.ContractLender, toContractCallData, walletPrivateKey)
+	if err != nil {
+		return false, nil, nil, err
+	}
+
+	// The gas-price oracle may apply an extra buffer in the final block
+	// to account for last‑second basefee volatility.
+	scaledL1GasFee := utils.DecreaseAmount(l1GasFee, 12)
+	transactionFee := new(big.Int).Add(gasOpts.TransactionFee, scaledL1GasFee)
+	diff := utils.ComputeDifference(rewardEth, transactionFee)
+
+	isWorth := diff > opts.ProfitableThreshold
+	log.Info().Str("block", callOpts.BlockNumber.String()).Str("l1GasFee", l1GasFee.String()).Str("scaledL1GasFee", scaledL1GasFee.String()).Str("transaction fee", transactionFee.String()).Float64("l1 diff", diff).Msg("")
+
+	return isWorth, signedTx, scaledL1GasFee, nil
+}
+
+// buildOpts initializes and returns the Gas Price Oracle contract binding, the call options, the message simulating
+// the harvest call and its data, or an error if one of them can't be built.
+// Note: The returned CallOpts.Context must be set manually by the caller
+//
+//	(e.g., using context.WithTimeout or context.WithCancel) before use.
+func buildOpts(ethClient web3.Client, harvester Harvester) (*bind.BoundContract, *bind.CallOpts, ethereum.CallMsg, []byte, error) {
+	opts := harvester.Opts().Opts()
+
+	contractGasPriceOracle, err := web3.BuildContractInstance(ethClient, opts.ContractGasPriceOracle, contract_abi.CONTRACT_ABI_GAS_PRICE_ORACLE)
+	if err != nil {
+		return nil, nil, ethereum.CallMsg{}, nil, fmt.Errorf("failed to build gas price oracle %s instance: %w", opts.ContractGasPriceOracle, err)
+	}
+
+	lenderData, err := harvester.BuildCallData()
+	if err != nil {
+		return nil, nil, ethereum.CallMsg{}, nil, fmt.Errorf("failed to build %s call data: %w", opts.Protocol, err)
+	}
+
+	callOpts := &bind.CallOpts{
+		Pending:     false, // last block mined
+		BlockNumber: nil,
+		From:        opts.Sender,
+		// the context must set manually after calling this function
+	}
+
+	// Create a message to simulate the transaction
+	callMsg := ethereum.CallMsg{
+		From:  opts.Sender,
+		To:    &opts.ContractLender,
+		Data:  lenderData, // ABI-encoded function call lenderData
+		Value: zeroValue,
+	}
+
+	return contractGasPriceOracle, callOpts, callMsg, lenderData, nil
+}
+
+// waitTransaction waits for the transaction, or its replacement, to be mined. A bundle is only waited for until its
+// target block passed, it is never replaced.
+//
+// It returns the result of the wait, and nil when the reinvest, or its cancellation, was mined successfully,
+// otherwise the classified error: the error of the wait, or the revert of the failed reinvest found by replaying it.
+func waitTransaction(ctx context.Context, ethClient web3.Client, pendingTxs *web3.PendingTxManager, signer types.Signer, lenderAbi *abi.ABI, tx *types.Transaction, targetBlock *big.Int, isProfitable web3.ProfitCheck, opts *models.PoolOpts) (*web3.PendingTxResult, error) {
+	log.Info().Str("hash", tx.Hash().Hex()).Msgf("Sent transaction on %s", opts.Protocol)
+
+	// Wait for the transaction's validation, replacing it when stuck
+	var result *web3.PendingTxResult
+	var err error
+	if opts.Submission == models.SubmissionBundle {
+		result, err = pendingTxs.WaitBundle(ctx, tx, targetBlock)
+	} else {
+		result, err = pendingTxs.Wait(ctx, tx, isProfitable)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Str("chain", string(opts.Chain)).Int("replacements", result.Replacements).Msgf("Failed to wait for receipt on %s", opts.Protocol)
+		return result, err
+	}
+
+	if result.Cancelled {
+		log.Warn().Str("hash", result.Tx.Hash().Hex()).Str("chain", string(opts.Chain)).Msgf("Cancelled transaction on %s", opts.Protocol)
+		return result, nil
+	}
+
+	if result.Receipt.Status == types.ReceiptStatusSuccessful {
+		log.Info().Str("hash", result.Tx.Hash().Hex()).Int("replacements", result.Replacements).Msgf("Successfully sent transaction on %s", opts.Protocol)
+		return result, nil
+	}
+
+	replayCtx, replayCancelCtx := context.WithTimeout(context.Background(), time.Second*10)
+	defer replayCancelCtx()
+	revertErr := web3.ReplayFailedReceipt(replayCtx, ethClient, signer, result.Tx, result.Receipt, lenderAbi)
+	log.Error().Err(revertErr).Str("hash", result.Tx.Hash().Hex()).Str("chain", string(opts.Chain)).Msgf("Failed to send transaction on %s", opts.Protocol)
+
+	return result, revertErr
+}
+
+// recordHarvest reads the receipt of a mined transaction with its OP stack fields and records its realized result.
+//
+// Parameters:
+//   - ctx: The context of the request.
+//   - ethClient: The client the receipt is read from.
+//   - ledger: The ledger the harvest is recorded in.
+//   - harvester: The harvester of the pool, realizing the harvest from the receipt.
+//   - tx: The mined transaction.
+//   - rewardPair: The value of the reward token in ETH when the transaction was sent.
+//   - predictedRewardEth: The reward in ETH expected when the transaction was sent.
+//   - predictedFee: The L2 and L1 fees expected when the transaction was sent.
+func recordHarvest(ctx context.Context, ethClient web3.Client, ledger accounting.Ledger, harvester Harvester, tx *types.Transaction, rewardPair *big.Int, predictedRewardEth *big.Int, predictedFee *big.Int) {
+	opts := harvester.Opts().Opts()
+	receiptCtx, receiptCancelCtx := context.WithTimeout(ctx, time.Second*10)
+	defer receiptCancelCtx()
+
+	receipt, err := web3.GetOpReceipt(receiptCtx, ethClient, tx.Hash())
+	if err != nil {
+		log.Error().Err(err).Str("hash", tx.Hash().Hex()).Str("chain", string(opts.Chain)).Msg("Failed to get receipt for the ledger")
+		return
+	}
+
+	harvest := harvester.PostProcessReceipt(receipt, rewardPair)
+	harvest.PredictedRewardEth = predictedRewardEth
+	harvest.PredictedFee = predictedFee
+
+	log.Info().
+		Str("chain", string(opts.Chain)).
+		Str("hash", tx.Hash().Hex()).
+		Bool("success", harvest.Success).
+		Str("reward token", harvest.RewardToken.String()).
+		Str("reward eth", harvest.RewardEth.String()).
+		Str("predicted reward eth", predictedRewardEth.String()).
+		Str("l2 fee", harvest.L2Fee.String()).
+		Str("l1 fee", harvest.L1Fee.String()).
+		Str("predicted fee", predictedFee.String()).
+		Str("profit eth", harvest.ProfitEth.String()).
+		Msgf("Realized harvest on %s", opts.Protocol)
+
+	ledger.Record(harvest)
+
+	totals := ledger.Totals(accounting.Position{Chain: opts.Chain, Pool: opts.Pool, Wallet: opts.Sender})
+	log.Info().
+		Str("chain", string(opts.Chain)).
+		Str("pool", string(opts.Pool)).
+		Int("harvests", totals.Harvests).
+		Int("failed", totals.Failed).
+		Str("reward eth", totals.RewardEth.String()).
+		Str("fee", totals.Fee.String()).
+		Str("profit eth", totals.ProfitEth.String()).
+		Msgf("Realized totals on %s", opts.Protocol)
+}
+
+// logCancellation reads the receipt of a mined cancellation with its OP stack fields and logs its fees. A cancellation
+// is not a harvest, it is kept out of the ledger.
+//
+// Parameters:
+//   - ctx: The context of the request.
+//   - ethClient: The client the receipt is read from.
+//   - opts: The options of the pool.
+//   - tx: The mined cancellation.
+func logCancellation(ctx context.Context, ethClient web3.Client, opts *models.PoolOpts, tx *types.Transaction) {
+	receiptCtx, receiptCancelCtx := context.WithTimeout(ctx, time.Second*10)
+	defer receiptCancelCtx()
+
+	receipt, err := web3.GetOpReceipt(receiptCtx, ethClient, tx.Hash())
+	if err != nil {
+		log.Error().Err(err).Str("hash", tx.Hash().Hex()).Str("chain", string(opts.Chain)).Msg("Failed to get receipt of the cancellation")
+		return
+	}
+
+	log.Warn().
+		Str("chain", string(opts.Chain)).
+		Str("pool", string(opts.Pool)).
+		Str("hash", tx.Hash().Hex()).
+		Str("l2 fee", receipt.L2Fee().String()).
+		Str("l1 fee", receipt.L1DataFee().String()).
+		Msgf("Paid the cancellation on %s", opts.Protocol)
+}
+
+// newIteration builds the ledger record of the decision taken on the block of the iteration.
+//
+// Parameters:
+//   - opts: The options of the pool.
+//   - calculationOpts: The values read on the block.
+//   - rewardEth: The reinvest bounty in ETH.
+//   - l2GasOpts: The L2 gas options quoted.
+//   - l1Fee: The L1 fee quoted, nil when it was not.
+//   - decision: The decision taken.
+//
+// Returns:
+//   - accounting.Iteration: The record, with the difference between the reward and the fees quoted.
+func newIteration(opts *models.PoolOpts, calculationOpts *CalculationOpts, rewardEth *big.Int, l2GasOpts *web3.GasOpts, l1Fee *big.Int, decision accounting.Decision) accounting.Iteration {
+	transactionFee := new(big.Int).Set(l2GasOpts.TransactionFee)
+	if l1Fee != nil {
+		transactionFee.Add(transactionFee, l1Fee)
+	}
+
+	return accounting.Iteration{
+		Time:        time.Now().UTC(),
+		Chain:       opts.Chain,
+		Pool:        opts.Pool,
+		Wallet:      opts.Sender,
+		BlockNumber: calculationOpts.BlockNumber.Uint64(),
+		Earned:      calculationOpts.VaultPendingRewardValue,
+		RewardEth:   rewardEth,
+		L2Fee:       l2GasOpts.TransactionFee,
+		L1Fee:       l1Fee,
+		Diff:        utils.ComputeDifference(rewardEth, transactionFee),
+		Decision:    decision,
+	}
+}
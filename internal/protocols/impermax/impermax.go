@@ -0,0 +1,54 @@
+package impermax
+
+import (
+	"defibotgo/internal/contract_abi"
+	"defibotgo/internal/models"
+	"defibotgo/internal/protocols/harvest"
+	"defibotgo/internal/web3"
+	"fmt"
+)
+
+var reinvestFunctionName = "reinvest"
+
+// Harvester harvests the Impermax staked LP tokens, whose LP tokens stake in a gauge
+type Harvester struct {
+	*harvest.GaugeHarvester
+	opts *models.ImpermaxOpts
+}
+
+var _ harvest.Harvester = (*Harvester)(nil)
+
+// NewHarvester builds the harvester of an Impermax pool.
+//
+// Parameters:
+//   - ethClient: The client the multicall reads the gauge with.
+//   - protocolOpts: The options of the pool, which must be *models.ImpermaxOpts.
+//
+// Returns:
+//   - harvest.Harvester: The harvester of the pool.
+//   - error: An error if the options are not Impermax options or the calls can't be built.
+func NewHarvester(ethClient web3.Client, protocolOpts models.ProtocolOpts) (harvest.Harvester, error) {
+	opts, ok := protocolOpts.(*models.ImpermaxOpts)
+	if !ok {
+		return nil, fmt.Errorf("options %T are not Impermax options", protocolOpts)
+	}
+
+	stakedTokenAbi, err := web3.LoadAbi(contract_abi.CONTRACT_ABI_STAKED_LP_TOKEN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load staked LP token abi: %w", err)
+	}
+	gaugeHarvester, err := harvest.NewGaugeHarvester(ethClient, &opts.PoolOpts, stakedTokenAbi, opts.ReinvestBounty, opts.RewardRate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Harvester{GaugeHarvester: gaugeHarvester, opts: opts}, nil
+}
+
+func (h *Harvester) Opts() models.ProtocolOpts {
+	return h.opts
+}
+
+func (h *Harvester) BuildCallData() ([]byte, error) {
+	return h.Abi().Pack(reinvestFunctionName)
+}
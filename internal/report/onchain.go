@@ -0,0 +1,75 @@
+package report
+
+import (
+	"context"
+	"defibotgo/internal/accounting"
+	"defibotgo/internal/competition"
+	"defibotgo/internal/models"
+	"defibotgo/internal/utils"
+	"defibotgo/internal/web3"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+)
+
+// ReinvestScan selects the Reinvest events of a wallet on a lender contract.
+type ReinvestScan struct {
+	Chain     models.Chain
+	Pool      models.Pool
+	Lender    common.Address
+	Wallet    common.Address
+	FromBlock uint64
+	ToBlock   uint64
+}
+
+// ScanReinvests rebuilds the harvests of a wallet from the Reinvest events it triggered on the lender contract,
+// when the ledger does not hold them.
+//
+// Only the successful reinvests emit the event, the reverted ones are missing. The bounties are converted to ETH
+// with the rewardPair given, which is usually the current one, not the one of the time of the harvest.
+//
+// Parameters:
+//   - ctx: The context of the requests.
+//   - client: The client of the chain.
+//   - scan: The lender, wallet and block range to scan.
+//   - rewardPair: The value of the reward token in ETH, scaled by 1e18; the ETH amounts are zero when nil.
+//
+// Returns:
+//   - []accounting.Harvest: The harvests, ordered by block.
+//   - error: An error if the logs, receipts or headers cannot be fetched.
+func ScanReinvests(ctx context.Context, client web3.Client, scan ReinvestScan, rewardPair *big.Int) ([]accounting.Harvest, error) {
+	reinvests, err := competition.ScanReinvests(ctx, client, competition.ScanQuery{
+		Chain:     scan.Chain,
+		Pool:      scan.Pool,
+		Lender:    scan.Lender,
+		Caller:    &scan.Wallet,
+		FromBlock: scan.FromBlock,
+		ToBlock:   scan.ToBlock,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	harvests := make([]accounting.Harvest, 0, len(reinvests))
+	for _, reinvest := range reinvests {
+		harvest := accounting.Harvest{
+			Time:        reinvest.Time,
+			Chain:       scan.Chain,
+			Pool:        scan.Pool,
+			Wallet:      scan.Wallet,
+			TxHash:      reinvest.TxHash,
+			BlockNumber: reinvest.BlockNumber,
+			Success:     true,
+			RewardToken: reinvest.Bounty,
+			RewardEth:   big.NewInt(0),
+			L2Fee:       reinvest.L2Fee,
+			L1Fee:       reinvest.L1Fee,
+		}
+		if rewardPair != nil {
+			harvest.RewardEth = utils.ConvertToEth(reinvest.Bounty, rewardPair)
+		}
+		harvest.ProfitEth = new(big.Int).Sub(harvest.RewardEth, harvest.Fee())
+		harvests = append(harvests, harvest)
+	}
+
+	return harvests, nil
+}
@@ -0,0 +1,190 @@
+package report
+
+import (
+	"defibotgo/internal/accounting"
+	"defibotgo/internal/models"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Format is the export format of a report
+type Format string
+
+const (
+	FormatCsv  Format = "CSV"
+	FormatJson Format = "JSON"
+)
+
+// dayLayout formats the days of the report, in UTC
+const dayLayout = "2006-01-02"
+
+// Row sums the harvests of a wallet on a pool during a day.
+type Row struct {
+	Day            string
+	Chain          models.Chain
+	Pool           models.Pool
+	Wallet         common.Address
+	Count          int
+	Failed         int
+	GrossBounty    *big.Int // The bounties received, in reward token
+	GrossBountyEth *big.Int // The bounties received, in ETH
+	GasSpent       *big.Int // The L2 and L1 fees paid, in ETH
+	NetProfitEth   *big.Int // GrossBountyEth - GasSpent
+}
+
+// csvHeader is the header of the CSV export, in the order of Row.csvRecord
+var csvHeader = []string{"day", "chain", "pool", "wallet", "count", "failed", "gross_bounty", "gross_bounty_eth", "gas_spent", "net_profit_eth"}
+
+func (r *Row) csvRecord() []string {
+	return []string{
+		r.Day, string(r.Chain), string(r.Pool), r.Wallet.Hex(), strconv.Itoa(r.Count), strconv.Itoa(r.Failed),
+		r.GrossBounty.String(), r.GrossBountyEth.String(), r.GasSpent.String(), r.NetProfitEth.String(),
+	}
+}
+
+// jsonRow is the JSON export of a Row, the amounts are decimal strings to keep their precision.
+type jsonRow struct {
+	Day            string         `json:"day"`
+	Chain          models.Chain   `json:"chain"`
+	Pool           models.Pool    `json:"pool"`
+	Wallet         common.Address `json:"wallet"`
+	Count          int            `json:"count"`
+	Failed         int            `json:"failed"`
+	GrossBounty    string         `json:"grossBounty"`
+	GrossBountyEth string         `json:"grossBountyEth"`
+	GasSpent       string         `json:"gasSpent"`
+	NetProfitEth   string         `json:"netProfitEth"`
+}
+
+// Aggregate sums the harvests by pool, day and wallet.
+//
+// Parameters:
+//   - harvests: The harvests to sum.
+//
+// Returns:
+//   - []Row: One row per day, chain, pool and wallet, ordered by day, chain, pool and wallet.
+func Aggregate(harvests []accounting.Harvest) []Row {
+	type rowKey struct {
+		day    string
+		chain  models.Chain
+		pool   models.Pool
+		wallet common.Address
+	}
+
+	rows := map[rowKey]*Row{}
+	for i := range harvests {
+		harvest := &harvests[i]
+		key := rowKey{day: harvest.Time.UTC().Format(dayLayout), chain: harvest.Chain, pool: harvest.Pool, wallet: harvest.Wallet}
+		row, ok := rows[key]
+		if !ok {
+			row = &Row{
+				Day: key.day, Chain: key.chain, Pool: key.pool, Wallet: key.wallet,
+				GrossBounty: big.NewInt(0), GrossBountyEth: big.NewInt(0), GasSpent: big.NewInt(0), NetProfitEth: big.NewInt(0),
+			}
+			rows[key] = row
+		}
+
+		row.Count++
+		if !harvest.Success {
+			row.Failed++
+		}
+		row.GrossBounty.Add(row.GrossBounty, harvest.RewardToken)
+		row.GrossBountyEth.Add(row.GrossBountyEth, harvest.RewardEth)
+		row.GasSpent.Add(row.GasSpent, harvest.Fee())
+		row.NetProfitEth.Add(row.NetProfitEth, harvest.ProfitEth)
+	}
+
+	sorted := make([]Row, 0, len(rows))
+	for _, row := range rows {
+		sorted = append(sorted, *row)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := &sorted[i], &sorted[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.Chain != b.Chain {
+			return a.Chain < b.Chain
+		}
+		if a.Pool != b.Pool {
+			return a.Pool < b.Pool
+		}
+		return a.Wallet.Cmp(b.Wallet) < 0
+	})
+
+	return sorted
+}
+
+// Filter keeps the harvests of the chain, every harvest when chain is empty, since the given time.
+func Filter(harvests []accounting.Harvest, chain models.Chain, since time.Time) []accounting.Harvest {
+	var filtered []accounting.Harvest
+	for _, harvest := range harvests {
+		if chain != "" && harvest.Chain != chain {
+			continue
+		}
+		if harvest.Time.Before(since) {
+			continue
+		}
+		filtered = append(filtered, harvest)
+	}
+	return filtered
+}
+
+// Write exports the rows in the given format.
+//
+// Parameters:
+//   - w: The writer of the export.
+//   - format: The export format.
+//   - rows: The rows to export.
+//
+// Returns:
+//   - error: An error if the format is unknown or the export cannot be written.
+func Write(w io.Writer, format Format, rows []Row) error {
+	switch format {
+	case FormatCsv:
+		return writeCsv(w, rows)
+	case FormatJson:
+		return writeJson(w, rows)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+func writeCsv(w io.Writer, rows []Row) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return fmt.Errorf("failed to write csv header: %w", err)
+	}
+	for i := range rows {
+		if err := writer.Write(rows[i].csvRecord()); err != nil {
+			return fmt.Errorf("failed to write csv row: %w", err)
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeJson(w io.Writer, rows []Row) error {
+	jsonRows := make([]jsonRow, 0, len(rows))
+	for _, row := range rows {
+		jsonRows = append(jsonRows, jsonRow{
+			Day: row.Day, Chain: row.Chain, Pool: row.Pool, Wallet: row.Wallet, Count: row.Count, Failed: row.Failed,
+			GrossBounty: row.GrossBounty.String(), GrossBountyEth: row.GrossBountyEth.String(),
+			GasSpent: row.GasSpent.String(), NetProfitEth: row.NetProfitEth.String(),
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(jsonRows); err != nil {
+		return fmt.Errorf("failed to write json: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Worker is a long-running task of the supervisor
+type Worker struct {
+	Name string
+	// Run returns nil once its context is canceled, an error when it failed
+	Run func(ctx context.Context) error
+}
+
+// Opts configures the restarts and the shutdown of the workers
+type Opts struct {
+	MinBackoff      time.Duration // Wait before the first restart of a failed worker, doubled on every failure
+	MaxBackoff      time.Duration // Upper bound of the wait before a restart
+	StableAfter     time.Duration // A worker running for longer is restarted after MinBackoff again
+	ShutdownTimeout time.Duration // Wait for the workers to return once canceled, forever when zero
+}
+
+// DefaultOpts are the supervisor options used in production
+var DefaultOpts = Opts{
+	MinBackoff:      time.Second,
+	MaxBackoff:      time.Minute,
+	StableAfter:     5 * time.Minute,
+	ShutdownTimeout: 30 * time.Second,
+}
+
+// Supervisor runs workers concurrently, recovering their panics and restarting them with backoff when they fail
+type Supervisor struct {
+	opts    Opts
+	workers []Worker
+}
+
+// New returns a supervisor without workers.
+func New(opts Opts) *Supervisor {
+	return &Supervisor{opts: opts}
+}
+
+// Add registers a worker, started by Run.
+func (s *Supervisor) Add(worker Worker) {
+	s.workers = append(s.workers, worker)
+}
+
+// Run starts every worker and restarts the failed ones until the context is canceled, then waits for all of them
+// to return.
+//
+// Parameters:
+//   - ctx: The context stopping the workers.
+//
+// Returns:
+//   - error: An error if some workers did not return within the shutdown timeout.
+func (s *Supervisor) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	running := make(map[string]bool, len(s.workers))
+	var mu sync.Mutex
+
+	for _, worker := range s.workers {
+		wg.Add(1)
+		running[worker.Name] = true
+		go func() {
+			defer wg.Done()
+			s.supervise(ctx, worker)
+
+			mu.Lock()
+			delete(running, worker.Name)
+			mu.Unlock()
+		}()
+	}
+	log.Info().Int("workers", len(s.workers)).Msg("Supervisor started")
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	<-ctx.Done()
+	log.Info().Msg("Supervisor stopping the workers")
+
+	var timeout <-chan time.Time
+	if s.opts.ShutdownTimeout > 0 {
+		timeout = time.After(s.opts.ShutdownTimeout)
+	}
+	select {
+	case <-done:
+		log.Info().Msg("Supervisor stopped every worker")
+		return nil
+	case <-timeout:
+		mu.Lock()
+		defer mu.Unlock()
+		names := make([]string, 0, len(running))
+		for name := range running {
+			names = append(names, name)
+		}
+		return fmt.Errorf("workers %v did not stop within %v", names, s.opts.ShutdownTimeout)
+	}
+}
+
+// supervise runs the worker until the context is canceled, restarting it after each failure.
+func (s *Supervisor) supervise(ctx context.Context, worker Worker) {
+	backoff := s.opts.MinBackoff
+
+	for ctx.Err() == nil {
+		startedAt := time.Now()
+		err := runRecovered(ctx, worker)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = fmt.Errorf("worker returned before being canceled")
+		}
+
+		// A worker failing after a long run is not failing in a loop
+		if time.Since(startedAt) >= s.opts.StableAfter {
+			backoff = s.opts.MinBackoff
+		}
+		log.Error().Err(err).Str("worker", worker.Name).Dur("backoff", backoff).Msg("Worker failed, restarting it")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, s.opts.MaxBackoff)
+	}
+}
+
+// runRecovered runs the worker once, returning its panic as an error.
+func runRecovered(ctx context.Context, worker Worker) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	return worker.Run(ctx)
+}
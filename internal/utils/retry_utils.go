@@ -2,7 +2,7 @@ package utils
 
 import "time"
 
-const RetryMainSleep = 2 * time.Second
+const HeadPollSleep = 100 * time.Millisecond
 const RetrySuccessSleep = 2 * time.Second
 const RetryErrorSleep = 5 * time.Second
 const RetryExpiredContextSleep = 60 * time.Second
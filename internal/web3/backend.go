@@ -0,0 +1,58 @@
+package web3
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"math/big"
+)
+
+// ChainReader is the read-only view of a chain used by the harvest loop.
+type ChainReader interface {
+	bind.ContractCaller
+
+	ChainID(ctx context.Context) (*big.Int, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// FeeOracle groups the methods used to price and size a transaction.
+type FeeOracle interface {
+	ethereum.GasEstimator
+	ethereum.GasPricer
+	ethereum.GasPricer1559
+
+	EstimateGasAtBlock(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) (uint64, error)
+}
+
+// TxSender groups the methods used to build and broadcast a transaction.
+type TxSender interface {
+	ethereum.TransactionSender
+
+	PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+}
+
+// LogFilterer gives access to contract logs, either as one-off queries or as subscriptions.
+type LogFilterer interface {
+	ethereum.LogFilterer
+}
+
+// Client is the full set of chain methods needed by the bot.
+//
+// It is satisfied by *ethclient.Client and is a superset of bind.ContractBackend and bind.DeployBackend,
+// so it can back contract bindings and bind.WaitMined. Fakes, instrumented wrappers and multi-endpoint
+// pools only need to implement it to be plugged in the harvest loop.
+type Client interface {
+	ChainReader
+	FeeOracle
+	TxSender
+	LogFilterer
+}
+
+var _ Client = (*ethclient.Client)(nil)
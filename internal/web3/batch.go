@@ -0,0 +1,403 @@
+package web3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+	"math/big"
+	"sync/atomic"
+)
+
+// BatchCaller is implemented by the clients able to send several JSON-RPC requests in one round trip.
+type BatchCaller interface {
+	BatchCallContext(ctx context.Context, b []rpc.BatchElem) error
+}
+
+// RawCaller is implemented by the clients able to send any JSON-RPC request, including the ones
+// not covered by Client (e.g. eth_getBlockReceipts).
+type RawCaller interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// rpcClientProvider is implemented by *ethclient.Client to expose its underlying rpc client.
+type rpcClientProvider interface {
+	Client() *rpc.Client
+}
+
+// BatchResult holds the outcome of a request once its batch has been sent.
+type BatchResult[T any] struct {
+	Value T
+	Err   error
+}
+
+// BatchMetrics counts the requests sent through batches and the round trips they actually cost.
+type BatchMetrics struct {
+	Requests   uint64 // Number of JSON-RPC requests
+	RoundTrips uint64 // Number of network round trips used to send them
+}
+
+var (
+	batchRequests   atomic.Uint64
+	batchRoundTrips atomic.Uint64
+)
+
+// GetBatchMetrics returns the metrics accumulated by every batch since the start.
+func GetBatchMetrics() BatchMetrics {
+	return BatchMetrics{Requests: batchRequests.Load(), RoundTrips: batchRoundTrips.Load()}
+}
+
+type batchEntry struct {
+	elem     rpc.BatchElem
+	decode   func(err error)                          // fills the result from the batch answer
+	fallback func(ctx context.Context, client Client) // fills the result with a standalone request
+	fail     func(err error)                          // fills the result with an error
+}
+
+// Batch groups JSON-RPC requests to send them in a single round trip.
+//
+// Every request method returns a result holder which is filled when Send returns. When the client
+// does not support batches, or the provider rejects it, the requests are sent one by one instead.
+type Batch struct {
+	entries []*batchEntry
+}
+
+func NewBatch() *Batch {
+	return &Batch{}
+}
+
+// Len returns the number of requests in the batch.
+func (b *Batch) Len() int {
+	return len(b.entries)
+}
+
+func addEntry[T any](b *Batch, elem rpc.BatchElem, decode func() (T, error), fallback func(ctx context.Context, client Client) (T, error)) *BatchResult[T] {
+	result := &BatchResult[T]{}
+	b.entries = append(b.entries, &batchEntry{
+		elem: elem,
+		decode: func(err error) {
+			if err != nil {
+				result.Err = err
+				return
+			}
+			result.Value, result.Err = decode()
+		},
+		fallback: func(ctx context.Context, client Client) {
+			result.Value, result.Err = fallback(ctx, client)
+		},
+		fail: func(err error) {
+			result.Err = err
+		},
+	})
+	return result
+}
+
+// CallContract adds an eth_call executed at the given block.
+func (b *Batch) CallContract(msg ethereum.CallMsg, blockNumber *big.Int) *BatchResult[[]byte] {
+	var raw hexutil.Bytes
+	return addEntry(b,
+		rpc.BatchElem{Method: "eth_call", Args: []interface{}{toCallArg(msg), toBlockNumArg(blockNumber)}, Result: &raw},
+		func() ([]byte, error) { return raw, nil },
+		func(ctx context.Context, client Client) ([]byte, error) {
+			return client.CallContract(ctx, msg, blockNumber)
+		},
+	)
+}
+
+// CallContractWithOverrides adds an eth_call executed on the state of the given block, with the accounts of
+// overrides and the fields of the executing block replaced, e.g. to run it at the timestamp of the next block.
+func (b *Batch) CallContractWithOverrides(msg ethereum.CallMsg, blockNumber *big.Int, overrides *map[common.Address]gethclient.OverrideAccount, blockOverrides gethclient.BlockOverrides) *BatchResult[[]byte] {
+	var raw hexutil.Bytes
+	elem := rpc.BatchElem{Method: "eth_call", Args: []interface{}{toCallArg(msg), toBlockNumArg(blockNumber), overrides, blockOverrides}, Result: &raw}
+	return addEntry(b, elem,
+		func() ([]byte, error) { return raw, nil },
+		func(ctx context.Context, client Client) ([]byte, error) {
+			if err := rawCall(ctx, client, elem); err != nil {
+				return nil, err
+			}
+			return raw, nil
+		},
+	)
+}
+
+// EstimateGas adds an eth_estimateGas executed at the given block.
+func (b *Batch) EstimateGas(msg ethereum.CallMsg, blockNumber *big.Int) *BatchResult[uint64] {
+	var raw hexutil.Uint64
+	return addEntry(b,
+		rpc.BatchElem{Method: "eth_estimateGas", Args: []interface{}{toCallArg(msg), toBlockNumArg(blockNumber)}, Result: &raw},
+		func() (uint64, error) { return uint64(raw), nil },
+		func(ctx context.Context, client Client) (uint64, error) {
+			return client.EstimateGasAtBlock(ctx, msg, blockNumber)
+		},
+	)
+}
+
+// HeaderByNumber adds an eth_getBlockByNumber without transactions, nil meaning the latest block.
+func (b *Batch) HeaderByNumber(number *big.Int) *BatchResult[*types.Header] {
+	var raw *types.Header
+	return addEntry(b,
+		rpc.BatchElem{Method: "eth_getBlockByNumber", Args: []interface{}{toBlockNumArg(number), false}, Result: &raw},
+		func() (*types.Header, error) {
+			if raw == nil {
+				return nil, ethereum.NotFound
+			}
+			return raw, nil
+		},
+		func(ctx context.Context, client Client) (*types.Header, error) {
+			return client.HeaderByNumber(ctx, number)
+		},
+	)
+}
+
+// FilterLogs adds an eth_getLogs.
+func (b *Batch) FilterLogs(q ethereum.FilterQuery) *BatchResult[[]types.Log] {
+	arg, err := toFilterArg(q)
+	if err != nil {
+		return &BatchResult[[]types.Log]{Err: err}
+	}
+
+	var raw []types.Log
+	return addEntry(b,
+		rpc.BatchElem{Method: "eth_getLogs", Args: []interface{}{arg}, Result: &raw},
+		func() ([]types.Log, error) { return raw, nil },
+		func(ctx context.Context, client Client) ([]types.Log, error) { return client.FilterLogs(ctx, q) },
+	)
+}
+
+// TransactionByHash adds an eth_getTransactionByHash. Deposit transactions, which go-ethereum cannot
+// decode, are reported with ErrDepositTx and the other unknown types with ErrUnsupportedTxType.
+func (b *Batch) TransactionByHash(hash common.Hash) *BatchResult[*types.Transaction] {
+	var raw json.RawMessage
+	return addEntry(b,
+		rpc.BatchElem{Method: "eth_getTransactionByHash", Args: []interface{}{hash}, Result: &raw},
+		func() (*types.Transaction, error) {
+			return decodeTransaction(raw)
+		},
+		func(ctx context.Context, client Client) (*types.Transaction, error) {
+			tx, _, err := client.TransactionByHash(ctx, hash)
+			if errors.Is(err, types.ErrTxTypeNotSupported) {
+				return nil, classifySenderError(err)
+			}
+			return tx, err
+		},
+	)
+}
+
+// PendingNonceAt adds an eth_getTransactionCount at the pending block.
+func (b *Batch) PendingNonceAt(account common.Address) *BatchResult[uint64] {
+	var raw hexutil.Uint64
+	return addEntry(b,
+		rpc.BatchElem{Method: "eth_getTransactionCount", Args: []interface{}{account, "pending"}, Result: &raw},
+		func() (uint64, error) { return uint64(raw), nil },
+		func(ctx context.Context, client Client) (uint64, error) { return client.PendingNonceAt(ctx, account) },
+	)
+}
+
+// FeeHistory adds an eth_feeHistory over the blockCount blocks up to lastBlock, nil meaning the latest block.
+func (b *Batch) FeeHistory(blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) *BatchResult[*ethereum.FeeHistory] {
+	var raw feeHistoryResult
+	elem := rpc.BatchElem{Method: "eth_feeHistory", Args: []interface{}{hexutil.Uint(blockCount), toBlockNumArg(lastBlock), rewardPercentiles}, Result: &raw}
+	return addEntry(b, elem,
+		func() (*ethereum.FeeHistory, error) { return raw.toFeeHistory(), nil },
+		func(ctx context.Context, client Client) (*ethereum.FeeHistory, error) {
+			if err := rawCall(ctx, client, elem); err != nil {
+				return nil, err
+			}
+			return raw.toFeeHistory(), nil
+		},
+	)
+}
+
+// BlockReceipts adds an eth_getBlockReceipts of the given block.
+func (b *Batch) BlockReceipts(number *big.Int) *BatchResult[[]*ReceiptSummary] {
+	var raw []*ReceiptSummary
+	elem := rpc.BatchElem{Method: "eth_getBlockReceipts", Args: []interface{}{toBlockNumArg(number)}, Result: &raw}
+	return addEntry(b, elem,
+		func() ([]*ReceiptSummary, error) { return raw, nil },
+		func(ctx context.Context, client Client) ([]*ReceiptSummary, error) {
+			if err := rawCall(ctx, client, elem); err != nil {
+				return nil, err
+			}
+			return raw, nil
+		},
+	)
+}
+
+// OpReceipt adds an eth_getTransactionReceipt decoded with its OP stack fields.
+func (b *Batch) OpReceipt(hash common.Hash) *BatchResult[*OpReceipt] {
+	var raw *OpReceipt
+	return addEntry(b,
+		rpc.BatchElem{Method: "eth_getTransactionReceipt", Args: []interface{}{hash}, Result: &raw},
+		func() (*OpReceipt, error) {
+			if raw == nil {
+				return nil, ethereum.NotFound
+			}
+			return raw, nil
+		},
+		func(ctx context.Context, client Client) (*OpReceipt, error) {
+			return GetOpReceipt(ctx, client, hash)
+		},
+	)
+}
+
+// Send sends every request of the batch and fills their results.
+//
+// Parameters:
+//   - ctx: The context of the requests.
+//   - client: The client used to send the batch, or the requests one by one when it cannot batch them.
+//
+// Returns:
+//   - error: The context error if it expired, every result then holds it. Errors of the requests
+//     themselves are reported in their results.
+func (b *Batch) Send(ctx context.Context, client Client) error {
+	if len(b.entries) == 0 {
+		return nil
+	}
+	batchRequests.Add(uint64(len(b.entries)))
+
+	if caller := asBatchCaller(client); caller != nil {
+		elems := make([]rpc.BatchElem, len(b.entries))
+		for i, entry := range b.entries {
+			elems[i] = entry.elem
+		}
+
+		err := caller.BatchCallContext(ctx, elems)
+		if err == nil {
+			batchRoundTrips.Add(1)
+			for i, entry := range b.entries {
+				entry.decode(elems[i].Error)
+			}
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			b.fail(ctx.Err())
+			return ctx.Err()
+		}
+		log.Debug().Err(err).Int("requests", len(b.entries)).Msg("JSON-RPC batch failed, sending the requests one by one")
+	}
+
+	for _, entry := range b.entries {
+		if ctx.Err() != nil {
+			b.fail(ctx.Err())
+			return ctx.Err()
+		}
+		batchRoundTrips.Add(1)
+		entry.fallback(ctx, client)
+	}
+
+	return nil
+}
+
+func (b *Batch) fail(err error) {
+	for _, entry := range b.entries {
+		entry.fail(err)
+	}
+}
+
+// decodeTransaction decodes a transaction object, null meaning the transaction is unknown.
+func decodeTransaction(raw json.RawMessage) (*types.Transaction, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, ethereum.NotFound
+	}
+
+	var envelope struct {
+		Type hexutil.Uint64 `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return nil, err
+	}
+	if envelope.Type == DepositTxType {
+		return nil, ErrDepositTx
+	}
+
+	tx := new(types.Transaction)
+	if err := json.Unmarshal(raw, tx); err != nil {
+		if errors.Is(err, types.ErrTxTypeNotSupported) {
+			return nil, classifySenderError(err)
+		}
+		return nil, err
+	}
+	return tx, nil
+}
+
+// rawCall sends a single request which has no typed method on Client.
+func rawCall(ctx context.Context, client Client, elem rpc.BatchElem) error {
+	caller := asRawCaller(client)
+	if caller == nil {
+		return fmt.Errorf("client cannot send %s requests", elem.Method)
+	}
+
+	return caller.CallContext(ctx, elem.Result, elem.Method, elem.Args...)
+}
+
+// asRawCaller returns the side of the client able to send any request, or nil if it has none.
+func asRawCaller(client Client) RawCaller {
+	switch c := client.(type) {
+	case RawCaller:
+		return c
+	case rpcClientProvider:
+		return c.Client()
+	default:
+		return nil
+	}
+}
+
+// asBatchCaller returns the batch capable side of the client, or nil if it has none.
+func asBatchCaller(client Client) BatchCaller {
+	switch c := client.(type) {
+	case BatchCaller:
+		return c
+	case rpcClientProvider:
+		return c.Client()
+	default:
+		return nil
+	}
+}
+
+// ReceiptSummary holds the fields of a transaction receipt needed to price competing transactions.
+// Unlike types.Receipt, it keeps the sender and recipient returned by the node.
+type ReceiptSummary struct {
+	TxHash            common.Hash     `json:"transactionHash"`
+	Type              hexutil.Uint64  `json:"type"`
+	BlockNumber       *hexutil.Big    `json:"blockNumber"`
+	From              common.Address  `json:"from"`
+	To                *common.Address `json:"to"`
+	EffectiveGasPrice *hexutil.Big    `json:"effectiveGasPrice"`
+	Status            hexutil.Uint64  `json:"status"`
+}
+
+type feeHistoryResult struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas,omitempty"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+func (r *feeHistoryResult) toFeeHistory() *ethereum.FeeHistory {
+	feeHistory := &ethereum.FeeHistory{
+		OldestBlock:  (*big.Int)(r.OldestBlock),
+		Reward:       make([][]*big.Int, len(r.Reward)),
+		BaseFee:      make([]*big.Int, len(r.BaseFee)),
+		GasUsedRatio: r.GasUsedRatio,
+	}
+
+	for i, rewards := range r.Reward {
+		feeHistory.Reward[i] = make([]*big.Int, len(rewards))
+		for j, reward := range rewards {
+			feeHistory.Reward[i][j] = (*big.Int)(reward)
+		}
+	}
+	for i, baseFee := range r.BaseFee {
+		feeHistory.BaseFee[i] = (*big.Int)(baseFee)
+	}
+
+	return feeHistory
+}
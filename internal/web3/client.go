@@ -10,42 +10,38 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/rs/zerolog/log"
 	"math/big"
 )
 
-// BuildWeb3Client initializes a new Web3 client for the specified blockchain network.
+// BuildWeb3Client initializes a pool over the RPC endpoints configured for the specified blockchain network.
 //
 // Parameters:
 //   - chain: The blockchain network for which to build the Web3 client (e.g., models.Optimism).
+//   - asReader: Whether to use the endpoints dedicated to view functions instead of the writing ones.
 //
 // Returns:
-//   - *ethclient.Client: The initialized Ethereum client.
+//   - *RpcPool: The pool of Ethereum clients, its health monitoring must be started with Start.
 //   - error: An error that occurred during the connection attempt, or nil if successful.
-func BuildWeb3Client(chain models.Chain, asReader bool) (*ethclient.Client, error) {
-	rpcUrl := config.ChainToRpcUrlWrite[chain]
-	if rpcUrl == "" {
-		return nil, fmt.Errorf("rpc url is empty")
-	}
-
-	if asReader {
-		rpcUrl = config.ChainToRpcUrlRead[chain]
+func BuildWeb3Client(chain models.Chain, asReader bool) (*RpcPool, error) {
+	endpoints, err := config.RpcEndpoints(chain, asReader)
+	if err != nil {
+		return nil, err
 	}
 
-	ethClient, err := ethclient.Dial(rpcUrl)
+	pool, err := NewRpcPool(context.Background(), endpoints, DefaultPoolOpts)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to build test Web3 client: %v", err)
+		return nil, fmt.Errorf("failed to build Web3 client: %v", err)
 	}
 
-	return ethClient, err
+	return pool, nil
 }
 
 // SendTransaction sends a transaction to a smart contract using the specified write function.
 //
 // Parameters:
-//   - ethClient: The Ethereum client used to interact with the Ethereum network.
+//   - ethClient: The chain reader used to fetch the chain ID.
 //   - contract: The bound smart contract to send the transaction to.
 //   - functionName: The name of the smart contract write function to invoke.
 //   - gasOpts: Options for specifying gas limits and fees (GasLimit, GasFeeCap, GasTipCap).
@@ -54,7 +50,7 @@ func BuildWeb3Client(chain models.Chain, asReader bool) (*ethclient.Client, erro
 // Returns:
 //   - *types.Transaction: The transaction object representing the sent transaction.
 //   - error: An error that occurred while sending the transaction, or nil if successful.
-func SendTransaction(ethClient *ethclient.Client, contract *bind.BoundContract, functionName string, gasOpts *GasOpts, walletPrivateKey *ecdsa.PrivateKey, params ...interface{}) (*types.Transaction, error) {
+func SendTransaction(ethClient ChainReader, contract *bind.BoundContract, functionName string, gasOpts *GasOpts, walletPrivateKey *ecdsa.PrivateKey, params ...interface{}) (*types.Transaction, error) {
 	chainID, err := ethClient.ChainID(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get ChainID: %v", err)
@@ -111,12 +107,12 @@ func EthCall(contract *bind.BoundContract, functionName string, callOpts *bind.C
 // GetBaseFeePerGas retrieves the base fee per gas for a specific block.
 //
 // Parameters:
-//   - ethClient: The Ethereum client instance used for blockchain interaction.
+//   - ethClient: The chain reader used for blockchain interaction.
 //   - blockNumber: The block number for which the base fee per gas is retrieved.
 //
 // Returns:
 //   - *big.Int: The base fee per gas for the specified block.
-func GetBaseFeePerGas(ethClient *ethclient.Client, blockNumber *big.Int) (*big.Int, error) {
+func GetBaseFeePerGas(ethClient ChainReader, blockNumber *big.Int) (*big.Int, error) {
 	header, err := ethClient.HeaderByNumber(context.Background(), blockNumber)
 
 	if err != nil {
@@ -129,14 +125,15 @@ func GetBaseFeePerGas(ethClient *ethclient.Client, blockNumber *big.Int) (*big.I
 // EstimateGas estimates the gas required to execute a specific Ethereum transaction.
 //
 // Parameters:
-//   - ethClient: The Ethereum client instance used to connect to the network.
+//   - ethClient: The fee oracle used to estimate the gas.
 //   - msg: The CallMsg struct defining the transaction details, such as 'From', 'To', 'Gas', 'GasPrice', 'Value', and 'Data'
+//   - blockNumber: The block number whose state is used for the estimation. If nil, the latest block will be used.
 //
 // Returns:
 //   - uint64: The estimated gas needed for the transaction execution.
 //   - error: An error if the gas estimation fails, or nil if successful.
-func EstimateGas(ethClient *ethclient.Client, msg ethereum.CallMsg) (uint64, error) {
-	estimateGas, err := ethClient.EstimateGas(context.Background(), msg)
+func EstimateGas(ethClient FeeOracle, msg ethereum.CallMsg, blockNumber *big.Int) (uint64, error) {
+	estimateGas, err := ethClient.EstimateGasAtBlock(context.Background(), msg, blockNumber)
 	if err != nil {
 		return 0, fmt.Errorf("failed to estimate gas estimation: %v", err)
 	}
@@ -148,118 +145,112 @@ func EstimateGas(ethClient *ethclient.Client, msg ethereum.CallMsg) (uint64, err
 // for a specified contract, excluding transactions from a given sender address.
 //
 // Parameters:
-//   - ethClient: The Ethereum client instance for blockchain interaction.
+//   - ethClient: The client used for blockchain interaction.
 //   - senderAddress: The Ethereum address of the sender whose transactions are to be excluded.
 //   - contractAddress: The contract's Ethereum address for which recent transactions are analyzed.
-//   - txCount: The number of past transactions to retrieve and analyze.
 //   - lastBlockN: Number of blocks before toBlock to start fetching transactions (e.g., 50 means start from 50 blocks before toBlock).
-//   - toBlock: The block number up to which transactions are considered.
+//   - toBlock: The block number up to which transactions are considered. If nil, the latest block will be used.
 //
 // Returns:
 //   - *big.Int: The maximum priority fee found among the transactions, or 0 if none are found.
 //   - error: An error if there was an issue fetching transactions or processing them.
-func GetPriorityFee(ethClient *ethclient.Client, senderAddress common.Address, contractAddress common.Address, lastBlockN *big.Int, toBlock *big.Int) (*big.Int, error) {
-	transactions, err := getPastTransactions(ethClient, contractAddress, lastBlockN, toBlock)
-	senderAddressStr := senderAddress.Hex()
+func GetPriorityFee(ethClient Client, senderAddress common.Address, contractAddress common.Address, lastBlockN *big.Int, toBlock *big.Int) (*big.Int, error) {
+	chainID, err := ethClient.ChainID(context.Background())
 	if err != nil {
-		return nil, fmt.Errorf("failed to get last n events: %v", err)
+		return nil, fmt.Errorf("failed to get ChainID: %v", err)
 	}
 
-	maxPriorityFee := big.NewInt(0)
-
-	for _, transaction := range transactions {
-		txSender, errSender := getSender(transaction)
-		if errSender != nil {
-			log.Warn().Err(errSender).Msg("Failed to get sender")
-			continue
-		}
-		if txSender.Hex() != senderAddressStr && transaction.GasTipCap().Cmp(maxPriorityFee) == 1 {
-			maxPriorityFee = transaction.GasTipCap()
+	if toBlock == nil {
+		latestBlock, err := ethClient.BlockNumber(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get latest block: %v", err)
 		}
+		toBlock = new(big.Int).SetUint64(latestBlock)
 	}
 
-	return maxPriorityFee, nil
+	logs, err := ethClient.FilterLogs(context.Background(), PastTransactionsQuery(contractAddress, lastBlockN, toBlock))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last n events: failed to filter logs: %v", err)
+	}
+
+	maxPriorityFee, ignoredTxs, err := GetPriorityFeeFromLogs(context.Background(), ethClient, types.LatestSignerForChainID(chainID), senderAddress, logs)
+	if ignoredTxs.Total() > 0 {
+		log.Debug().Interface("ignored", ignoredTxs).Msg("Transactions ignored for the priority fee")
+	}
+
+	return maxPriorityFee, err
 }
 
-// getPastTransactions retrieves past transactions for a given contract address within a specified block range.
+// PastTransactionsQuery builds the log query matching the transactions sent to a contract within a block range.
 //
 // Parameters:
-//   - ethClient: The Ethereum client used to interact with the blockchain.
 //   - contractAddress: The address of the contract for which past transactions are retrieved.
-//   - txCount: The desired number of transactions to retrieve (e.g., the last 5 or 3 transactions).
 //   - lastBlockN: The number of blocks to go back from the toBlock (e.g., 50 means start 50 blocks before toBlock).
-//   - toBlock: The block number up to which transactions should be fetched. If nil, the latest block will be used.
+//   - toBlock: The block number up to which transactions should be fetched.
 //
 // Returns:
-//   - []*types.Transaction: A slice of transactions matching the criteria, up to the specified txCount.
-//   - error: An error if there was an issue retrieving the transactions.
-func getPastTransactions(ethClient *ethclient.Client, contractAddress common.Address, lastBlockN *big.Int, toBlock *big.Int) ([]*types.Transaction, error) {
-	toBlockResult := toBlock
-
-	if toBlockResult == nil {
-		latestBlock, err := ethClient.BlockNumber(context.Background())
-
-		if err != nil {
-			return nil, fmt.Errorf("failed to get latest block: %v", err)
-		}
-
-		toBlockResult = big.NewInt(int64(latestBlock))
-	}
-
+//   - ethereum.FilterQuery: The query to give to FilterLogs.
+func PastTransactionsQuery(contractAddress common.Address, lastBlockN *big.Int, toBlock *big.Int) ethereum.FilterQuery {
 	if lastBlockN == nil {
 		lastBlockN = big.NewInt(0) // Default to 0 blocks if lastBlockN is not provided
 	}
 
-	filterQuery := ethereum.FilterQuery{
-		FromBlock: new(big.Int).Sub(toBlockResult, lastBlockN),
-		ToBlock:   toBlockResult,
+	return ethereum.FilterQuery{
+		FromBlock: new(big.Int).Sub(toBlock, lastBlockN),
+		ToBlock:   toBlock,
 		Addresses: []common.Address{contractAddress},
 	}
+}
 
-	logs, err := ethClient.FilterLogs(context.Background(), filterQuery)
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to filter logs: %v", err)
-	}
-
-	var transactions []*types.Transaction
+// GetPriorityFeeFromLogs calculates the maximum priority fee among the transactions which emitted the logs,
+// excluding transactions from a given sender address. The transactions are fetched in a single batch.
+//
+// Parameters:
+//   - ctx: The context of the requests.
+//   - ethClient: The client used to fetch the transactions.
+//   - signer: The signer of the chain used to recover the transactions sender, as returned by SignerForChain.
+//   - senderAddress: The Ethereum address of the sender whose transactions are to be excluded.
+//   - logs: The logs of the past transactions, as returned by a PastTransactionsQuery.
+//
+// Returns:
+//   - *big.Int: The maximum priority fee found among the transactions, or 0 if none are found.
+//   - IgnoredTxs: The number of transactions skipped, by reason.
+//   - error: An error if the context expired while fetching the transactions.
+func GetPriorityFeeFromLogs(ctx context.Context, ethClient Client, signer types.Signer, senderAddress common.Address, logs []types.Log) (*big.Int, IgnoredTxs, error) {
+	batch := NewBatch()
+	var transactions []*BatchResult[*types.Transaction]
+	var ignoredTxs IgnoredTxs
 
 	for i, _log := range logs {
 		if i-1 >= 0 && _log.TxHash == logs[i-1].TxHash {
 			// skip redundant transactions
 			continue
 		}
-		tx, _, err := ethClient.TransactionByHash(context.Background(), _log.TxHash)
-		if err != nil {
-			log.Printf("failed to get transaction by hash: %v", err)
-			continue
-		}
+		transactions = append(transactions, batch.TransactionByHash(_log.TxHash))
+	}
 
-		transactions = append(transactions, tx)
+	if err := batch.Send(ctx, ethClient); err != nil {
+		return nil, ignoredTxs, fmt.Errorf("failed to get past transactions: %v", err)
 	}
 
-	return transactions, nil
-}
+	maxPriorityFee := big.NewInt(0)
 
-// getSender retrieves the sender address of a given transaction.
-//
-// Parameters:
-//   - tx: A pointer to the transaction from which to extract the sender address.
-//
-// Returns:
-//   - common.Address: The sender address of the transaction.
-//   - error: An error if there was an issue retrieving the sender address.
-func getSender(tx *types.Transaction) (common.Address, error) {
-	var signer types.Signer
-	switch {
-	case tx.Type() == types.AccessListTxType:
-		signer = types.NewEIP2930Signer(tx.ChainId())
-	case tx.Type() == types.DynamicFeeTxType:
-		signer = types.NewLondonSigner(tx.ChainId())
-	default:
-		signer = types.NewEIP155Signer(tx.ChainId())
+	for _, result := range transactions {
+		if result.Err != nil {
+			ignoredTxs.Add(result.Err)
+			continue
+		}
+		transaction := result.Value
+
+		txSender, errSender := GetSender(signer, transaction)
+		if errSender != nil {
+			ignoredTxs.Add(errSender)
+			continue
+		}
+		if txSender != senderAddress && transaction.GasTipCap().Cmp(maxPriorityFee) == 1 {
+			maxPriorityFee = transaction.GasTipCap()
+		}
 	}
-	sender, err := types.Sender(signer, tx)
 
-	return sender, err
+	return maxPriorityFee, ignoredTxs, nil
 }
@@ -5,7 +5,6 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"strings"
 )
 
@@ -30,14 +29,14 @@ func LoadAbi(abiStr string) (abi.ABI, error) {
 // BuildContractInstance creates and returns a new bound contract instance for a given contract address and ABI string.
 //
 // Parameters:
-//   - client: The client used to interact with the blockchain.
+//   - client: The backend used to call, transact and filter logs on the contract.
 //   - contractAddress: The address of the smart contract on the blockchain.
 //   - abiStr: The ABI string representing the contract's interface.
 //
 // Returns:
 //   - *bind.BoundContract: The bound contract instance that allows interaction with the smart contract.
 //   - error: An error that occurred during ABI parsing, or nil if successful.
-func BuildContractInstance(client *ethclient.Client, contractAddress common.Address, abiStr string) (*bind.BoundContract, error) {
+func BuildContractInstance(client bind.ContractBackend, contractAddress common.Address, abiStr string) (*bind.BoundContract, error) {
 	parsedAbi, err := LoadAbi(abiStr)
 	if err != nil {
 		return nil, err
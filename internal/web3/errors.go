@@ -0,0 +1,196 @@
+package web3
+
+import (
+	"context"
+	"defibotgo/internal/utils"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// The classes of the errors met while sending a transaction and waiting for it. ErrReverted is one of them too.
+var (
+	// ErrNonceTooLow is returned when the nonce of the transaction was already used on chain.
+	ErrNonceTooLow = errors.New("nonce too low")
+	// ErrNonceTooHigh is returned when the nonce of the transaction leaves a gap the node does not accept.
+	ErrNonceTooHigh = errors.New("nonce too high")
+	// ErrUnderpriced is returned when the fees do not cover the base fee or do not outbid the transaction replaced.
+	ErrUnderpriced = errors.New("transaction underpriced")
+	// ErrInsufficientFunds is returned when the wallet cannot pay the transaction fee.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrDropped is returned when the transaction left the mempool without being mined.
+	ErrDropped = errors.New("transaction dropped")
+	// ErrNotIncluded is returned when the bundle of the transaction was not included in its target block.
+	ErrNotIncluded = errors.New("bundle not included")
+	// ErrTimeout is returned when the node did not answer, or the transaction was not mined, in time.
+	ErrTimeout = errors.New("timeout")
+)
+
+// Messages returned by the nodes, by error class.
+var errorMessages = []struct {
+	class    error
+	messages []string
+}{
+	{ErrNonceTooLow, []string{"nonce too low", "nonce has already been used"}},
+	{ErrNonceTooHigh, []string{"nonce too high", "invalid nonce"}},
+	{ErrUnderpriced, []string{"underpriced", "fee cap less than block base fee", "max fee per gas less than block base fee"}},
+	{ErrInsufficientFunds, []string{"insufficient funds"}},
+	{ErrTimeout, []string{"deadline exceeded", "timeout", "timed out"}},
+}
+
+// Messages returned by the nodes for a transaction already in their pool.
+var alreadyKnownMessages = []string{"already known", "known transaction", "already imported"}
+
+// TxError is an error classified in one of the error classes.
+type TxError struct {
+	Class error // The error class, e.g. ErrUnderpriced
+	Err   error // The original error
+}
+
+func (e *TxError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Class, e.Err)
+}
+
+// Unwrap makes errors.Is match both the class and the original error.
+func (e *TxError) Unwrap() []error {
+	return []error{e.Class, e.Err}
+}
+
+// ClassifyTxError wraps an error returned by a node, while sending or simulating a transaction, into its class.
+//
+// Parameters:
+//   - err: The error to classify.
+//
+// Returns:
+//   - error: a *TxError of the class of err, a *RevertError for the reverts, or err itself when it matches no class.
+func ClassifyTxError(err error) error {
+	if err == nil || ErrorClass(err) != nil {
+		return err
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &TxError{Class: ErrTimeout, Err: err}
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, errorMessage := range errorMessages {
+		for _, classMessage := range errorMessage.messages {
+			if strings.Contains(message, classMessage) {
+				return &TxError{Class: errorMessage.class, Err: err}
+			}
+		}
+	}
+
+	if revertErr := DecodeRevert(err, nil); revertErr != nil {
+		return revertErr
+	}
+	return err
+}
+
+// ErrorClass returns the class of an error classified by ClassifyTxError, nil if it has none.
+func ErrorClass(err error) error {
+	for _, class := range []error{ErrNonceTooLow, ErrNonceTooHigh, ErrUnderpriced, ErrInsufficientFunds, ErrReverted, ErrDropped, ErrNotIncluded, ErrTimeout} {
+		if errors.Is(err, class) {
+			return class
+		}
+	}
+	return nil
+}
+
+// IsNonceError reports whether a send error is caused by a nonce out of sync with the chain.
+func IsNonceError(err error) bool {
+	err = ClassifyTxError(err)
+	return errors.Is(err, ErrNonceTooLow) || errors.Is(err, ErrNonceTooHigh)
+}
+
+// IsAlreadyKnown reports whether a send error means the node already has the transaction.
+func IsAlreadyKnown(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, alreadyKnownMessage := range alreadyKnownMessages {
+		if strings.Contains(message, alreadyKnownMessage) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReplayFailedReceipt finds out why a mined transaction failed by replaying it on the state of the parent block.
+//
+// Parameters:
+//   - ctx: The context of the request.
+//   - caller: The client executing the replay.
+//   - signer: The signer of the chain, to recover the sender.
+//   - tx: The failed transaction.
+//   - receipt: The receipt of tx.
+//   - contractAbi: The ABI of the called contract used to decode its custom errors, may be nil.
+//
+// Returns:
+//   - *RevertError: The revert with its reason; when the replay succeeds, the transaction was outrun by another
+//     one of the same block, or ran out of gas.
+func ReplayFailedReceipt(ctx context.Context, caller bind.ContractCaller, signer types.Signer, tx *types.Transaction, receipt *types.Receipt, contractAbi *abi.ABI) *RevertError {
+	if receipt.GasUsed >= tx.Gas() {
+		return &RevertError{Reason: "out of gas"}
+	}
+
+	sender, err := GetSender(signer, tx)
+	if err != nil {
+		return &RevertError{Reason: fmt.Sprintf("replay failed: %v", err)}
+	}
+
+	parentBlock := new(big.Int).Sub(receipt.BlockNumber, big.NewInt(1))
+	msg := ethereum.CallMsg{From: sender, To: tx.To(), Gas: tx.Gas(), Value: tx.Value(), Data: tx.Data()}
+	_, err = caller.CallContract(ctx, msg, parentBlock)
+	if err == nil {
+		return &RevertError{Reason: "outrun in the block"}
+	}
+
+	if revertErr := DecodeRevert(err, contractAbi); revertErr != nil {
+		return revertErr
+	}
+	return &RevertError{Reason: fmt.Sprintf("replay failed: %v", err)}
+}
+
+// Recovery tells the harvest loop how to recover from a failed transaction.
+type Recovery struct {
+	ResyncNonce bool          // Read the nonce of the wallet from chain again
+	Backoff     time.Duration // Time to wait before the next evaluation
+}
+
+// recoveryPolicies maps the error classes to their recovery, the unclassified errors use defaultRecovery.
+var recoveryPolicies = map[error]Recovery{
+	// The local nonce is behind or ahead of the chain
+	ErrNonceTooLow:  {ResyncNonce: true},
+	ErrNonceTooHigh: {ResyncNonce: true},
+	// The next block comes with a fresh base fee and tip
+	ErrUnderpriced: {},
+	// Nothing can be sent until the wallet is funded
+	ErrInsufficientFunds: {Backoff: utils.RetryExpiredContextSleep},
+	// The reward was likely taken by a competitor
+	ErrReverted: {Backoff: utils.RetryErrorSleep},
+	// The nonce of a dropped transaction is free again
+	ErrDropped: {ResyncNonce: true},
+	// The nonce of the bundle is given back, the next head gets a new one
+	ErrNotIncluded: {},
+	// The node is slow or the transaction may still be mined later
+	ErrTimeout: {ResyncNonce: true, Backoff: utils.RetryExpiredContextSleep},
+}
+
+var defaultRecovery = Recovery{Backoff: utils.RetryErrorSleep}
+
+// RecoveryFor returns the recovery of the class of the error.
+func RecoveryFor(err error) Recovery {
+	if recovery, ok := recoveryPolicies[ErrorClass(ClassifyTxError(err))]; ok {
+		return recovery
+	}
+	return defaultRecovery
+}
@@ -1,6 +1,7 @@
 package web3
 
 import (
+	"github.com/ethereum/go-ethereum/core/types"
 	"math/big"
 )
 
@@ -57,3 +58,43 @@ func computeTransactionFee(gasLimit uint64, maxFee *big.Int) *big.Int {
 	gasLimitBigInt := new(big.Int).SetUint64(gasLimit)
 	return new(big.Int).Mul(gasLimitBigInt, maxFee)
 }
+
+// ReplacementBumpPercent is the minimum increase of both fee caps the nodes require to replace a pending transaction.
+const ReplacementBumpPercent = 10
+
+// BumpGasOpts computes the fees of a transaction replacing a pending one at the same nonce.
+//
+// Parameters:
+//   - tx: The pending transaction to replace.
+//   - baseFee: The current base fee per gas, the fee cap is raised to cover it if needed.
+//   - gasLimit: The gas limit of the replacing transaction.
+//
+// Returns:
+//   - *GasOpts: The tip and fee cap of tx raised by at least ReplacementBumpPercent, and the resulting transaction fee.
+func BumpGasOpts(tx *types.Transaction, baseFee *big.Int, gasLimit uint64) *GasOpts {
+	gasTipCap := bumpFee(tx.GasTipCap())
+	gasFeeCap := bumpFee(tx.GasFeeCap())
+
+	if minFeeCap := ComputeMaxFee(baseFee, gasTipCap); gasFeeCap.Cmp(minFeeCap) < 0 {
+		gasFeeCap = minFeeCap
+	}
+
+	return &GasOpts{
+		TransactionFee: computeTransactionFee(gasLimit, gasFeeCap),
+		GasFeeCap:      gasFeeCap,
+		GasTipCap:      gasTipCap,
+		GasLimit:       gasLimit,
+	}
+}
+
+// bumpFee raises the fee by ReplacementBumpPercent, rounded up so the node threshold is always met.
+func bumpFee(fee *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(100+ReplacementBumpPercent))
+	bumped.Add(bumped, big.NewInt(99))
+	bumped.Div(bumped, big.NewInt(100))
+
+	if bumped.Cmp(fee) <= 0 {
+		bumped.Add(fee, big.NewInt(1))
+	}
+	return bumped
+}
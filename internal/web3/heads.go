@@ -0,0 +1,124 @@
+package web3
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+// HeadSubscriber is implemented by the clients able to push new heads (WebSocket or IPC connections).
+type HeadSubscriber interface {
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+}
+
+// WatchHeads streams the new heads of the chain until the context is canceled.
+//
+// It subscribes to newHeads when the reader implements HeadSubscriber and falls back to polling
+// the latest header every pollInterval otherwise, or when the subscription fails. Every block is
+// delivered at most once and in increasing order; when the consumer is slower than the chain, the
+// pending head is replaced by the newest one so the consumer always works on the latest block.
+//
+// Parameters:
+//   - ctx: The context stopping the watcher; the returned channel is closed once it is done.
+//   - reader: The chain reader used to subscribe or poll.
+//   - pollInterval: The interval between two polls when no subscription is available.
+//
+// Returns:
+//   - <-chan *types.Header: The channel receiving the new heads.
+func WatchHeads(ctx context.Context, reader ChainReader, pollInterval time.Duration) <-chan *types.Header {
+	out := make(chan *types.Header, 1)
+
+	go func() {
+		defer close(out)
+		watcher := &headWatcher{out: out}
+
+		for ctx.Err() == nil {
+			if subscriber, ok := reader.(HeadSubscriber); ok {
+				if err := watcher.subscribe(ctx, subscriber); err != nil {
+					log.Debug().Err(err).Msg("newHeads subscription unavailable, polling heads")
+				}
+			}
+
+			// Poll until the next subscription attempt
+			watcher.poll(ctx, reader, pollInterval, resubscribeInterval)
+		}
+	}()
+
+	return out
+}
+
+// resubscribeInterval is the time spent polling before trying to subscribe again
+const resubscribeInterval = time.Minute
+
+type headWatcher struct {
+	out  chan *types.Header
+	last uint64
+}
+
+// subscribe forwards the subscribed heads until the subscription or the context ends.
+func (w *headWatcher) subscribe(ctx context.Context, subscriber HeadSubscriber) error {
+	headers := make(chan *types.Header, 16)
+	subscription, err := subscriber.SubscribeNewHead(ctx, headers)
+	if err != nil {
+		return err
+	}
+	defer subscription.Unsubscribe()
+
+	log.Debug().Msg("Subscribed to newHeads")
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-subscription.Err():
+			return err
+		case header := <-headers:
+			w.offer(header)
+		}
+	}
+}
+
+// poll fetches the latest header every interval during the given duration.
+func (w *headWatcher) poll(ctx context.Context, reader ChainReader, interval time.Duration, duration time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	deadline := time.After(duration)
+
+	for {
+		header, err := reader.HeaderByNumber(ctx, nil)
+		if err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("Error polling the latest header")
+		}
+		if err == nil {
+			w.offer(header)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// offer delivers the header if it is newer than the last one, replacing a head not consumed yet.
+func (w *headWatcher) offer(header *types.Header) {
+	if header == nil || header.Number == nil || header.Number.Uint64() <= w.last {
+		return
+	}
+	w.last = header.Number.Uint64()
+
+	select {
+	case w.out <- header:
+	default:
+		// The consumer is late: drop the pending head for the newest one
+		select {
+		case <-w.out:
+		default:
+		}
+		w.out <- header
+	}
+}
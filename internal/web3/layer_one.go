@@ -1,19 +1,16 @@
 package web3
 
 import (
-	"context"
 	"crypto/ecdsa"
 	"fmt"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"math/big"
 )
 
 func GetL1GasFee(
-	ctx context.Context,
-	ethClient *ethclient.Client,
+	nonce uint64,
 	chainId *big.Int,
 	callOpts *bind.CallOpts,
 	gasOpts *GasOpts,
@@ -22,17 +19,6 @@ func GetL1GasFee(
 	toContractCallData []byte,
 	walletPrivateKey *ecdsa.PrivateKey,
 ) (*big.Int, *types.Transaction, error) {
-	transactionOpts, err := bind.NewKeyedTransactorWithChainID(walletPrivateKey, chainId)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create transaction options: %v", err)
-	}
-
-	// Fetch nonce
-	nonce, err := ethClient.PendingNonceAt(ctx, transactionOpts.From)
-	if err != nil {
-		return nil, nil, err
-	}
-
 	// Build an EIP‑1559 tx (not yet signed)
 	tx := types.NewTx(&types.DynamicFeeTx{
 		ChainID:   chainId,
@@ -0,0 +1,143 @@
+package web3
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+	"math/big"
+	"time"
+)
+
+// LogWatcherClient is the client of WatchLogs.
+type LogWatcherClient interface {
+	ethereum.LogFilterer
+	BlockNumber(ctx context.Context) (uint64, error)
+}
+
+// WatchLogs streams the new logs matching the query until the context is canceled.
+//
+// It subscribes to the logs when the client supports it and falls back to querying the logs of the new blocks
+// every pollInterval otherwise, or when the subscription fails. The block range of the query is ignored, only
+// the logs mined after the call are delivered. The logs removed by a reorg are skipped.
+//
+// Parameters:
+//   - ctx: The context stopping the watcher; the returned channel is closed once it is done.
+//   - client: The client used to subscribe or poll.
+//   - query: The addresses and topics of the logs.
+//   - pollInterval: The interval between two polls when no subscription is available.
+//
+// Returns:
+//   - <-chan types.Log: The channel receiving the logs, in the order they were mined.
+func WatchLogs(ctx context.Context, client LogWatcherClient, query ethereum.FilterQuery, pollInterval time.Duration) <-chan types.Log {
+	out := make(chan types.Log, 16)
+	query.FromBlock, query.ToBlock = nil, nil
+
+	go func() {
+		defer close(out)
+		watcher := &logWatcher{out: out, query: query}
+
+		for ctx.Err() == nil {
+			if err := watcher.subscribe(ctx, client); err != nil {
+				log.Debug().Err(err).Msg("Logs subscription unavailable, polling logs")
+			}
+
+			// Poll until the next subscription attempt
+			watcher.poll(ctx, client, pollInterval, resubscribeInterval)
+		}
+	}()
+
+	return out
+}
+
+type logWatcher struct {
+	out   chan types.Log
+	query ethereum.FilterQuery
+	last  uint64 // The last block whose logs were delivered
+}
+
+// subscribe forwards the subscribed logs until the subscription or the context ends.
+func (w *logWatcher) subscribe(ctx context.Context, client LogWatcherClient) error {
+	logs := make(chan types.Log, 16)
+	subscription, err := client.SubscribeFilterLogs(ctx, w.query, logs)
+	if err != nil {
+		return err
+	}
+	defer subscription.Unsubscribe()
+
+	log.Debug().Msg("Subscribed to logs")
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-subscription.Err():
+			return err
+		case subscribedLog := <-logs:
+			w.deliver(ctx, subscribedLog)
+		}
+	}
+}
+
+// poll queries the logs of the new blocks every interval during the given duration.
+func (w *logWatcher) poll(ctx context.Context, client LogWatcherClient, interval time.Duration, duration time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	deadline := time.After(duration)
+
+	for {
+		if err := w.pollOnce(ctx, client); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("Error polling logs")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *logWatcher) pollOnce(ctx context.Context, client LogWatcherClient) error {
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+	if w.last == 0 {
+		// Only the logs mined from now on
+		w.last = head
+		return nil
+	}
+	if head <= w.last {
+		return nil
+	}
+
+	query := w.query
+	query.FromBlock = new(big.Int).SetUint64(w.last + 1)
+	query.ToBlock = new(big.Int).SetUint64(head)
+	logs, err := client.FilterLogs(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	for _, polledLog := range logs {
+		w.deliver(ctx, polledLog)
+	}
+	w.last = head
+
+	return nil
+}
+
+// deliver sends the log to the consumer, unless it was removed by a reorg.
+func (w *logWatcher) deliver(ctx context.Context, newLog types.Log) {
+	if newLog.Removed {
+		return
+	}
+	w.last = max(w.last, newLog.BlockNumber)
+
+	select {
+	case w.out <- newLog:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,163 @@
+package web3
+
+import (
+	"context"
+	"defibotgo/internal/contract_abi"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+)
+
+// Multicall3Address is the address Multicall3 is deployed at on every supported chain
+var Multicall3Address = common.HexToAddress("0xcA11bde05977b3631167028862bE2a173976CA11")
+
+var aggregate3FunctionName = "aggregate3"
+
+// ContractCall describes a view call the same way it would be done on a bind.BoundContract.
+type ContractCall struct {
+	Address      common.Address
+	Abi          *abi.ABI
+	Method       string
+	Params       []interface{}
+	AllowFailure bool // When false, a failure of this call reverts the whole multicall
+}
+
+// CallResult is the outcome of a single call of a multicall.
+type CallResult struct {
+	Success bool
+	Values  []interface{}
+	Err     error
+}
+
+// BigInt returns the first *big.Int of the call outputs, as EthCall does.
+func (r CallResult) BigInt() (*big.Int, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	for _, value := range r.Values {
+		if output, ok := value.(*big.Int); ok {
+			return output, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected result type; expected *big.Int")
+}
+
+type multicallCall3 struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type multicallResult struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// Multicall batches view calls into a single eth_call to the Multicall3 aggregate3 function.
+// Every call is executed against the same state, in one round trip.
+type Multicall struct {
+	caller  bind.ContractCaller
+	address common.Address
+	abi     abi.ABI
+}
+
+// NewMulticall returns a Multicall calling the Multicall3 contract deployed at address.
+//
+// Parameters:
+//   - caller: The backend used to execute the eth_call.
+//   - address: The address of the Multicall3 contract, usually Multicall3Address.
+//
+// Returns:
+//   - *Multicall: The multicall instance.
+//   - error: An error that occurred during ABI parsing, or nil if successful.
+func NewMulticall(caller bind.ContractCaller, address common.Address) (*Multicall, error) {
+	parsedAbi, err := LoadAbi(contract_abi.CONTRACT_ABI_MULTICALL3)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Multicall{caller: caller, address: address, abi: parsedAbi}, nil
+}
+
+// Address returns the address of the Multicall3 contract.
+func (m *Multicall) Address() common.Address {
+	return m.address
+}
+
+// Pack encodes the calls into the aggregate3 call data.
+func (m *Multicall) Pack(calls []ContractCall) ([]byte, error) {
+	call3s := make([]multicallCall3, len(calls))
+
+	for i, call := range calls {
+		callData, err := call.Abi.Pack(call.Method, call.Params...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pack %s: %v", call.Method, err)
+		}
+		call3s[i] = multicallCall3{Target: call.Address, AllowFailure: call.AllowFailure, CallData: callData}
+	}
+
+	return m.abi.Pack(aggregate3FunctionName, call3s)
+}
+
+// Unpack decodes the aggregate3 output into one result per call, in the calls order.
+func (m *Multicall) Unpack(calls []ContractCall, output []byte) ([]CallResult, error) {
+	outputs, err := m.abi.Unpack(aggregate3FunctionName, output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack %s: %v", aggregate3FunctionName, err)
+	}
+
+	rawResults := *abi.ConvertType(outputs[0], new([]multicallResult)).(*[]multicallResult)
+	if len(rawResults) != len(calls) {
+		return nil, fmt.Errorf("multicall returned %d results for %d calls", len(rawResults), len(calls))
+	}
+
+	results := make([]CallResult, len(calls))
+	for i, rawResult := range rawResults {
+		if !rawResult.Success {
+			results[i] = CallResult{Err: fmt.Errorf("call %s reverted", calls[i].Method)}
+			continue
+		}
+
+		values, err := calls[i].Abi.Unpack(calls[i].Method, rawResult.ReturnData)
+		if err != nil {
+			results[i] = CallResult{Err: fmt.Errorf("failed to unpack %s: %v", calls[i].Method, err)}
+			continue
+		}
+		results[i] = CallResult{Success: true, Values: values}
+	}
+
+	return results, nil
+}
+
+// Aggregate executes the calls in a single eth_call.
+//
+// Parameters:
+//   - callOpts: Options specifying the block number, sender and context of the call.
+//   - calls: The view calls to execute.
+//
+// Returns:
+//   - []CallResult: The result of every call, in the calls order.
+//   - error: An error if the multicall itself failed, individual failures are reported in the results.
+func (m *Multicall) Aggregate(callOpts *bind.CallOpts, calls []ContractCall) ([]CallResult, error) {
+	data, err := m.Pack(calls)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := callOpts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	output, err := m.caller.CallContract(ctx, ethereum.CallMsg{From: callOpts.From, To: &m.address, Data: data}, callOpts.BlockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call multicall: %v", err)
+	}
+
+	return m.Unpack(calls, output)
+}
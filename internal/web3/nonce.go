@@ -0,0 +1,247 @@
+package web3
+
+import (
+	"context"
+	"defibotgo/internal/models"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+	"slices"
+	"sync"
+)
+
+// NonceManager hands out the nonces of a wallet on a chain without querying the node for every transaction.
+//
+// A nonce is acquired before signing, then either released when the transaction is not sent, or marked as sent.
+// Released nonces are handed out again first so no gap is left behind. The manager resyncs from the pending
+// nonce of the chain when it is told the node rejected a nonce.
+type NonceManager struct {
+	mu sync.Mutex
+
+	client  TxSender
+	address common.Address
+
+	synced   bool
+	next     uint64                 // Next nonce never handed out
+	released []uint64               // Nonces handed out then released, below next, sorted
+	acquired map[uint64]bool        // Nonces handed out, neither released nor sent yet
+	inFlight map[uint64]common.Hash // Nonces of the sent transactions not yet mined
+}
+
+// NewNonceManager returns a manager of the nonces of the address. It syncs from chain on first use.
+//
+// Parameters:
+//   - client: The client used to read the pending nonce of the address.
+//   - address: The wallet address.
+//
+// Returns:
+//   - *NonceManager: The nonce manager.
+func NewNonceManager(client TxSender, address common.Address) *NonceManager {
+	return &NonceManager{client: client, address: address, acquired: map[uint64]bool{}, inFlight: map[uint64]common.Hash{}}
+}
+
+// Address returns the wallet address.
+func (m *NonceManager) Address() common.Address {
+	return m.address
+}
+
+// Sync reads the pending nonce of the wallet and realigns the local state on it.
+//
+// Parameters:
+//   - ctx: The context of the request.
+//
+// Returns:
+//   - error: An error if the pending nonce could not be read.
+func (m *NonceManager) Sync(ctx context.Context) error {
+	pending, err := m.client.PendingNonceAt(ctx, m.address)
+	if err != nil {
+		return fmt.Errorf("failed to get pending nonce of %s: %v", m.address.Hex(), err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.align(pending)
+	return nil
+}
+
+// align realigns the local state on the pending nonce of the chain. Must be called with the mutex held.
+func (m *NonceManager) align(pending uint64) {
+	// The transactions below the pending nonce are mined
+	for nonce := range m.inFlight {
+		if nonce < pending {
+			delete(m.inFlight, nonce)
+		}
+	}
+
+	switch {
+	case !m.synced:
+		m.next = pending
+	case pending > m.next:
+		// The wallet was used outside of the manager
+		log.Warn().Str("address", m.address.Hex()).Uint64("local", m.next).Uint64("chain", pending).Msg("Nonce behind the chain, resyncing")
+		m.next = pending
+	case pending < m.next && !m.hasInFlightFrom(pending):
+		// The transactions between the pending nonce and the local one were dropped, reuse their nonces. A nonce
+		// still being signed, by another pool of the wallet, holds the gap until it is sent or released
+		log.Warn().Str("address", m.address.Hex()).Uint64("local", m.next).Uint64("chain", pending).Msg("Nonce gap detected, resyncing")
+		m.next = pending
+	}
+
+	m.released = slices.DeleteFunc(m.released, func(nonce uint64) bool { return nonce < pending || nonce >= m.next })
+	m.synced = true
+}
+
+// hasInFlightFrom reports whether a nonce from the given one is sent or being signed. Must be called with the mutex
+// held.
+func (m *NonceManager) hasInFlightFrom(nonce uint64) bool {
+	for inFlightNonce := range m.inFlight {
+		if inFlightNonce >= nonce {
+			return true
+		}
+	}
+	for acquiredNonce := range m.acquired {
+		if acquiredNonce >= nonce {
+			return true
+		}
+	}
+	return false
+}
+
+// Acquire hands out the lowest nonce available. It must be either released or marked as sent.
+//
+// Parameters:
+//   - ctx: The context of the sync request, only used on first use.
+//
+// Returns:
+//   - uint64: The nonce to sign the transaction with.
+//   - error: An error if the manager could not sync from chain.
+func (m *NonceManager) Acquire(ctx context.Context) (uint64, error) {
+	m.mu.Lock()
+	synced := m.synced
+	m.mu.Unlock()
+
+	if !synced {
+		if err := m.Sync(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nonce := m.next
+	if len(m.released) > 0 {
+		nonce = m.released[0]
+		m.released = m.released[1:]
+	} else {
+		m.next++
+	}
+	m.acquired[nonce] = true
+	return nonce, nil
+}
+
+// Release gives back a nonce whose transaction was not sent.
+func (m *NonceManager) Release(nonce uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.acquired, nonce)
+	if nonce >= m.next || slices.Contains(m.released, nonce) {
+		return
+	}
+
+	m.released = append(m.released, nonce)
+	slices.Sort(m.released)
+
+	// Shrink the next nonce while its predecessor is free, to keep the released list short
+	for len(m.released) > 0 && m.released[len(m.released)-1] == m.next-1 {
+		m.released = m.released[:len(m.released)-1]
+		m.next--
+	}
+}
+
+// Sent marks the nonce as used by the sent transaction.
+func (m *NonceManager) Sent(nonce uint64, hash common.Hash) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.acquired, nonce)
+	m.inFlight[nonce] = hash
+}
+
+// Done forgets the transaction of the nonce once it is mined or replaced.
+func (m *NonceManager) Done(nonce uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.inFlight, nonce)
+}
+
+// InFlight returns the hash of the sent transactions not yet mined, by nonce.
+func (m *NonceManager) InFlight() map[uint64]common.Hash {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inFlight := make(map[uint64]common.Hash, len(m.inFlight))
+	for nonce, hash := range m.inFlight {
+		inFlight[nonce] = hash
+	}
+	return inFlight
+}
+
+// SendFailed handles a transaction rejected by the node. The nonce is released, and the manager resyncs
+// from chain when the node rejected the nonce itself.
+//
+// Parameters:
+//   - ctx: The context of the sync request.
+//   - nonce: The nonce of the rejected transaction.
+//   - sendErr: The error returned by the node.
+//
+// Returns:
+//   - error: An error if the resync failed.
+func (m *NonceManager) SendFailed(ctx context.Context, nonce uint64, sendErr error) error {
+	if !IsNonceError(sendErr) {
+		m.Release(nonce)
+		return nil
+	}
+
+	log.Warn().Err(sendErr).Str("address", m.address.Hex()).Uint64("nonce", nonce).Msg("Nonce rejected, resyncing")
+	m.mu.Lock()
+	// The nonce was not accepted, it must not hold back a gap detection
+	delete(m.inFlight, nonce)
+	delete(m.acquired, nonce)
+	m.released = slices.DeleteFunc(m.released, func(n uint64) bool { return n == nonce })
+	if nonce == m.next-1 {
+		m.next--
+	}
+	m.mu.Unlock()
+
+	return m.Sync(ctx)
+}
+
+// NonceRegistry shares one NonceManager per wallet and chain between the pools of the process.
+type NonceRegistry struct {
+	mu       sync.Mutex
+	managers map[nonceKey]*NonceManager
+}
+
+type nonceKey struct {
+	chain   models.Chain
+	address common.Address
+}
+
+func NewNonceRegistry() *NonceRegistry {
+	return &NonceRegistry{managers: map[nonceKey]*NonceManager{}}
+}
+
+// Get returns the nonce manager of the wallet on the chain, creating it with the client on first use.
+func (r *NonceRegistry) Get(chain models.Chain, client TxSender, address common.Address) *NonceManager {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := nonceKey{chain: chain, address: address}
+	manager, ok := r.managers[key]
+	if !ok {
+		manager = NewNonceManager(client, address)
+		r.managers[key] = manager
+	}
+	return manager
+}
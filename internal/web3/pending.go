@@ -0,0 +1,261 @@
+package web3
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+	"math/big"
+	"time"
+)
+
+// cancelGasLimit is the gas used by the zero-value self-transfer cancelling a transaction.
+const cancelGasLimit = uint64(21000)
+
+// ErrTxStuck is returned when a transaction is still pending after every replacement allowed.
+var ErrTxStuck = errors.New("transaction stuck after every replacement")
+
+// PendingTxOpts tunes how a pending transaction is watched and replaced.
+type PendingTxOpts struct {
+	PollInterval    time.Duration // Interval between two receipt checks
+	StuckAfter      time.Duration // Time without receipt after which the pending transaction is replaced
+	MaxReplacements int           // Number of speed-ups and cancellations sent at most
+}
+
+var DefaultPendingTxOpts = PendingTxOpts{
+	PollInterval:    time.Second,
+	StuckAfter:      time.Second * 10,
+	MaxReplacements: 5,
+}
+
+// ProfitCheck reports whether the transaction is still worth sending with the given fees.
+type ProfitCheck func(gasOpts *GasOpts) bool
+
+// PendingTxResult describes how a watched transaction ended.
+type PendingTxResult struct {
+	Tx           *types.Transaction // The transaction mined at the nonce, either the original, a speed-up or the cancellation
+	Receipt      *types.Receipt     // The receipt of Tx
+	Cancelled    bool               // Whether Tx is the cancellation
+	Replacements int                // Number of replacements sent
+}
+
+// PendingTxManager watches the sent transactions and replaces the stuck ones at the same nonce: with a
+// speed-up while it is still profitable, otherwise with a zero-value self-transfer cancelling it.
+type PendingTxManager struct {
+	client    Client
+	submitter Submitter
+	nonces    *NonceManager
+	signer    types.Signer
+	key       *ecdsa.PrivateKey
+	opts      PendingTxOpts
+}
+
+// NewPendingTxManager returns a manager replacing the transactions of the wallet of the nonce manager.
+//
+// Parameters:
+//   - client: The client used to read the receipts.
+//   - submitter: The submitter of the replacements, the one of the original transactions to keep them private.
+//   - nonces: The nonce manager of the wallet, told about the replacements and the mined transactions.
+//   - signer: The signer of the chain.
+//   - key: The private key of the wallet.
+//   - opts: The polling and replacement options.
+//
+// Returns:
+//   - *PendingTxManager: The pending transaction manager.
+func NewPendingTxManager(client Client, submitter Submitter, nonces *NonceManager, signer types.Signer, key *ecdsa.PrivateKey, opts PendingTxOpts) *PendingTxManager {
+	return &PendingTxManager{client: client, submitter: submitter, nonces: nonces, signer: signer, key: key, opts: opts}
+}
+
+// Wait waits for the transaction, or one of its replacements, to be mined.
+//
+// Every StuckAfter without receipt, the pending transaction is replaced with fees bumped by ReplacementBumpPercent:
+// by a speed-up of the same call as long as isProfitable accepts the bumped fees, then by a cancellation.
+//
+// Parameters:
+//   - ctx: The context bounding the whole wait.
+//   - tx: The sent transaction.
+//   - isProfitable: The check deciding between a speed-up and a cancellation.
+//
+// Returns:
+//   - *PendingTxResult: The mined transaction and its receipt, or the replacements count on error.
+//   - error: a *TxError of class ErrTimeout wrapping the context error, or ErrTxStuck when every replacement was used,
+//     or of class ErrDropped when no transaction of the nonce is known by the node anymore, the nonce is then done.
+func (m *PendingTxManager) Wait(ctx context.Context, tx *types.Transaction, isProfitable ProfitCheck) (*PendingTxResult, error) {
+	result := &PendingTxResult{}
+	sent := []*types.Transaction{tx}
+	current := tx
+	lastSend := time.Now()
+
+	ticker := time.NewTicker(m.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, sentTx := range sent {
+			receipt, err := m.client.TransactionReceipt(ctx, sentTx.Hash())
+			if err == nil {
+				m.nonces.Done(tx.Nonce())
+				result.Tx, result.Receipt = sentTx, receipt
+				result.Cancelled = isCancellation(sentTx, m.nonces.Address())
+				return result, nil
+			}
+			if !errors.Is(err, ethereum.NotFound) {
+				log.Debug().Err(err).Str("hash", sentTx.Hash().Hex()).Msg("Failed to get receipt")
+			}
+		}
+
+		if time.Since(lastSend) >= m.opts.StuckAfter {
+			if m.dropped(ctx, sent) {
+				// No transaction holds the nonce anymore, the next sync hands it out again
+				m.nonces.Done(tx.Nonce())
+				return result, &TxError{Class: ErrDropped, Err: fmt.Errorf("no transaction of nonce %d is known", tx.Nonce())}
+			}
+			if result.Replacements >= m.opts.MaxReplacements {
+				return result, &TxError{Class: ErrTimeout, Err: ErrTxStuck}
+			}
+
+			// Once cancelling, the unsent replacements keep cancelling too
+			replacement, err := m.replace(ctx, current, isCancellation(current, m.nonces.Address()), isProfitable)
+			lastSend = time.Now()
+			if err != nil {
+				log.Error().Err(err).Str("hash", current.Hash().Hex()).Uint64("nonce", tx.Nonce()).Msg("Failed to replace stuck transaction")
+			}
+			if replacement != nil {
+				// An underpriced replacement is not sent, but the next one must outbid it anyway
+				current = replacement
+				if err == nil {
+					sent = append(sent, replacement)
+					result.Cancelled = isCancellation(replacement, m.nonces.Address())
+					result.Replacements++
+					m.nonces.Sent(tx.Nonce(), replacement.Hash())
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, &TxError{Class: ErrTimeout, Err: ctx.Err()}
+		case <-ticker.C:
+		}
+	}
+}
+
+// WaitBundle waits for the transaction sent in a bundle to be mined in its target block.
+//
+// A bundle is only valid for its target block and never reaches the mempool, so it is neither sped up nor cancelled:
+// once a later block is known without its receipt, the nonce is given back to be used by the next bundle.
+//
+// Parameters:
+//   - ctx: The context bounding the whole wait.
+//   - tx: The transaction of the bundle.
+//   - targetBlock: The block the bundle is valid for.
+//
+// Returns:
+//   - *PendingTxResult: The mined transaction and its receipt.
+//   - error: a *TxError of class ErrNotIncluded when the target block passed without it, or of class ErrTimeout
+//     wrapping the context error.
+func (m *PendingTxManager) WaitBundle(ctx context.Context, tx *types.Transaction, targetBlock *big.Int) (*PendingTxResult, error) {
+	ticker := time.NewTicker(m.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// The head is read first, so a receipt of the target block is found before giving up on it
+		header, headerErr := m.client.HeaderByNumber(ctx, nil)
+		receipt, err := m.client.TransactionReceipt(ctx, tx.Hash())
+		if err == nil {
+			m.nonces.Done(tx.Nonce())
+			return &PendingTxResult{Tx: tx, Receipt: receipt}, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			log.Debug().Err(err).Str("hash", tx.Hash().Hex()).Msg("Failed to get receipt")
+		}
+		if headerErr == nil && header.Number.Cmp(targetBlock) > 0 {
+			m.nonces.Done(tx.Nonce())
+			m.nonces.Release(tx.Nonce())
+			return &PendingTxResult{}, &TxError{Class: ErrNotIncluded, Err: fmt.Errorf("block %v passed without nonce %d", targetBlock, tx.Nonce())}
+		}
+
+		select {
+		case <-ctx.Done():
+			return &PendingTxResult{}, &TxError{Class: ErrTimeout, Err: ctx.Err()}
+		case <-ticker.C:
+		}
+	}
+}
+
+// dropped reports whether none of the sent transactions is known by the node, neither pending nor mined.
+func (m *PendingTxManager) dropped(ctx context.Context, sent []*types.Transaction) bool {
+	for _, sentTx := range sent {
+		if _, _, err := m.client.TransactionByHash(ctx, sentTx.Hash()); !errors.Is(err, ethereum.NotFound) {
+			return false
+		}
+	}
+	return true
+}
+
+// replace signs and sends the transaction replacing current. The replacement is returned even when
+// the node rejected it as underpriced, nil if it could not be built.
+func (m *PendingTxManager) replace(ctx context.Context, current *types.Transaction, cancelled bool, isProfitable ProfitCheck) (*types.Transaction, error) {
+	header, err := m.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest header: %v", err)
+	}
+	if header.BaseFee == nil {
+		return nil, fmt.Errorf("block %v has no base fee", header.Number)
+	}
+
+	chainID := m.signer.ChainID()
+	var txData *types.DynamicFeeTx
+
+	speedUpGasOpts := BumpGasOpts(current, header.BaseFee, current.Gas())
+	if !cancelled && isProfitable(speedUpGasOpts) {
+		log.Info().Str("hash", current.Hash().Hex()).Str("priority fee", speedUpGasOpts.GasTipCap.String()).Msg("Speeding up stuck transaction")
+		txData = &types.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     current.Nonce(),
+			To:        current.To(),
+			Data:      current.Data(),
+			Value:     current.Value(),
+			Gas:       current.Gas(),
+			GasTipCap: speedUpGasOpts.GasTipCap,
+			GasFeeCap: speedUpGasOpts.GasFeeCap,
+		}
+	} else {
+		cancelGasOpts := BumpGasOpts(current, header.BaseFee, cancelGasLimit)
+		address := m.nonces.Address()
+		log.Info().Str("hash", current.Hash().Hex()).Str("priority fee", cancelGasOpts.GasTipCap.String()).Msg("Cancelling stuck transaction")
+		txData = &types.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     current.Nonce(),
+			To:        &address,
+			Value:     big.NewInt(0),
+			Gas:       cancelGasOpts.GasLimit,
+			GasTipCap: cancelGasOpts.GasTipCap,
+			GasFeeCap: cancelGasOpts.GasFeeCap,
+		}
+	}
+
+	replacement, err := types.SignNewTx(m.key, m.signer, txData)
+	if err != nil {
+		return nil, fmt.Errorf("sign tx: %w", err)
+	}
+
+	// The replacement targets the block following the latest one
+	targetBlock := new(big.Int).Add(header.Number, big.NewInt(1))
+	if err := m.submitter.Submit(ctx, replacement, targetBlock); err != nil {
+		if errors.Is(ClassifyTxError(err), ErrUnderpriced) {
+			return replacement, err
+		}
+		return nil, err
+	}
+
+	return replacement, nil
+}
+
+// isCancellation reports whether tx is a zero-value self-transfer of the address.
+func isCancellation(tx *types.Transaction, address common.Address) bool {
+	return tx.To() != nil && *tx.To() == address && len(tx.Data()) == 0
+}
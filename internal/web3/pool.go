@@ -0,0 +1,542 @@
+package web3
+
+import (
+	"context"
+	"defibotgo/internal/config"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type PoolOpts struct {
+	MaxHeadLag             uint64        // Number of blocks an endpoint may lag behind the best head before being demoted
+	HealthCheckInterval    time.Duration // Interval between two head checks of every endpoint
+	MaxConsecutiveFailures int           // Number of consecutive transport failures before benching an endpoint
+	FailureCooldown        time.Duration // Time a benched endpoint is skipped
+}
+
+var DefaultPoolOpts = PoolOpts{
+	MaxHeadLag:             3,
+	HealthCheckInterval:    5 * time.Second,
+	MaxConsecutiveFailures: 3,
+	FailureCooldown:        30 * time.Second,
+}
+
+// latencyWeight is the weight of the last request in the latency moving average
+const latencyWeight = 0.2
+
+// errorPenalty is the latency added to the score of an endpoint failing every request
+const errorPenalty = time.Second
+
+// EndpointStats is a snapshot of the health of a pool endpoint
+type EndpointStats struct {
+	Url                 string
+	Latency             time.Duration
+	Requests            uint64
+	Errors              uint64
+	ConsecutiveFailures int
+	Head                uint64
+	Stale               bool
+	Benched             bool
+}
+
+// EndpointAck is the answer of an endpoint to a broadcast transaction
+type EndpointAck struct {
+	Url          string
+	Latency      time.Duration // Time between the broadcast start and the answer
+	AlreadyKnown bool          // The endpoint already had the transaction, it counts as accepted
+	Err          error         // nil when the endpoint accepted the transaction
+}
+
+// BroadcastReport gathers the answers of the endpoints to a broadcast transaction
+type BroadcastReport struct {
+	Hash  common.Hash
+	First string        // Url of the first endpoint which accepted the transaction, empty if none did
+	Acks  []EndpointAck // Answers in the order they arrived
+
+	// Complete receives the report of every answer once the last endpoint answered, the broadcast returning on the
+	// first acceptance. It is closed without report when the broadcast was canceled before any acceptance.
+	Complete <-chan *BroadcastReport
+}
+
+// Accepted returns the number of endpoints which accepted the transaction.
+func (r *BroadcastReport) Accepted() int {
+	accepted := 0
+	for _, ack := range r.Acks {
+		if ack.Err == nil {
+			accepted++
+		}
+	}
+	return accepted
+}
+
+type rpcEndpoint struct {
+	url       string
+	rpcClient *rpc.Client
+	client    *ethclient.Client
+
+	mu                  sync.Mutex
+	latency             time.Duration
+	requests            uint64
+	errors              uint64
+	consecutiveFailures int
+	benchedUntil        time.Time
+	head                uint64
+	stale               bool
+}
+
+// RpcPool is a Client spreading the requests over several RPC endpoints of the same chain.
+//
+// Endpoints are ordered by health: benched and stale endpoints come last, the others are
+// sorted by latency weighted by their error rate. A request failing on transport (connection
+// refused, timeout, HTTP 5xx...) is retried on the next endpoint; an error answered by the node
+// itself (revert, nonce too low...) is returned as is.
+type RpcPool struct {
+	endpoints []*rpcEndpoint
+	opts      PoolOpts
+}
+
+var _ Client = (*RpcPool)(nil)
+
+// NewRpcPool dials every endpoint and returns the pool.
+//
+// An endpoint failing to dial (only possible for WebSocket/IPC) is skipped with a warning;
+// an error is returned when none could be dialed.
+func NewRpcPool(ctx context.Context, endpoints []config.RpcEndpoint, opts PoolOpts) (*RpcPool, error) {
+	pool := &RpcPool{opts: opts}
+
+	for _, endpoint := range endpoints {
+		headers := http.Header{}
+		for key, value := range endpoint.Headers {
+			headers.Set(key, value)
+		}
+
+		rpcClient, err := rpc.DialOptions(ctx, endpoint.Url, rpc.WithHeaders(headers))
+		if err != nil {
+			log.Warn().Err(err).Str("url", redactUrl(endpoint.Url)).Msg("Failed to dial rpc endpoint, skipping it")
+			continue
+		}
+
+		pool.endpoints = append(pool.endpoints, &rpcEndpoint{
+			url:       endpoint.Url,
+			rpcClient: rpcClient,
+			client:    ethclient.NewClient(rpcClient),
+		})
+	}
+
+	if len(pool.endpoints) == 0 {
+		return nil, fmt.Errorf("no rpc endpoint could be dialed")
+	}
+
+	return pool, nil
+}
+
+// Start monitors the head of every endpoint until the context is canceled.
+func (p *RpcPool) Start(ctx context.Context) {
+	p.CheckHealth(ctx)
+
+	go func() {
+		ticker := time.NewTicker(p.opts.HealthCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.CheckHealth(ctx)
+			}
+		}
+	}()
+}
+
+// CheckHealth fetches the block number of every endpoint and demotes the ones lagging behind the best head.
+func (p *RpcPool) CheckHealth(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, endpoint := range p.endpoints {
+		wg.Add(1)
+		go func(e *rpcEndpoint) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, p.opts.HealthCheckInterval)
+			defer cancel()
+
+			start := time.Now()
+			head, err := e.client.BlockNumber(checkCtx)
+			p.record(e, time.Since(start), err)
+			if err == nil {
+				e.mu.Lock()
+				e.head = head
+				e.mu.Unlock()
+			}
+		}(endpoint)
+	}
+	wg.Wait()
+
+	bestHead := uint64(0)
+	for _, endpoint := range p.endpoints {
+		endpoint.mu.Lock()
+		if endpoint.head > bestHead {
+			bestHead = endpoint.head
+		}
+		endpoint.mu.Unlock()
+	}
+
+	for _, endpoint := range p.endpoints {
+		endpoint.mu.Lock()
+		stale := endpoint.head+p.opts.MaxHeadLag < bestHead
+		if stale != endpoint.stale {
+			log.Warn().Str("url", redactUrl(endpoint.url)).Uint64("head", endpoint.head).Uint64("best head", bestHead).Bool("stale", stale).Msg("Rpc endpoint head status changed")
+		}
+		endpoint.stale = stale
+		endpoint.mu.Unlock()
+	}
+}
+
+// Stats returns a snapshot of every endpoint health, in the pool order.
+func (p *RpcPool) Stats() []EndpointStats {
+	stats := make([]EndpointStats, 0, len(p.endpoints))
+	now := time.Now()
+
+	for _, endpoint := range p.endpoints {
+		endpoint.mu.Lock()
+		stats = append(stats, EndpointStats{
+			Url:                 redactUrl(endpoint.url),
+			Latency:             endpoint.latency,
+			Requests:            endpoint.requests,
+			Errors:              endpoint.errors,
+			ConsecutiveFailures: endpoint.consecutiveFailures,
+			Head:                endpoint.head,
+			Stale:               endpoint.stale,
+			Benched:             now.Before(endpoint.benchedUntil),
+		})
+		endpoint.mu.Unlock()
+	}
+
+	return stats
+}
+
+// Close closes the connection of every endpoint.
+func (p *RpcPool) Close() {
+	for _, endpoint := range p.endpoints {
+		endpoint.rpcClient.Close()
+	}
+}
+
+// ordered returns the endpoints sorted from the healthiest to the least healthy.
+func (p *RpcPool) ordered() []*rpcEndpoint {
+	type scored struct {
+		endpoint *rpcEndpoint
+		rank     int
+		score    float64
+	}
+
+	now := time.Now()
+	candidates := make([]scored, len(p.endpoints))
+	for i, endpoint := range p.endpoints {
+		endpoint.mu.Lock()
+		rank := 0
+		if endpoint.stale {
+			rank = 1
+		}
+		if now.Before(endpoint.benchedUntil) {
+			rank = 2
+		}
+
+		errorRate := 0.0
+		if endpoint.requests > 0 {
+			errorRate = float64(endpoint.errors) / float64(endpoint.requests)
+		}
+		// The error rate also adds a flat penalty so an endpoint that never answered does not look the fastest
+		score := float64(endpoint.latency)*(1+4*errorRate) + errorRate*float64(errorPenalty)
+		candidates[i] = scored{endpoint: endpoint, rank: rank, score: score}
+		endpoint.mu.Unlock()
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].rank != candidates[j].rank {
+			return candidates[i].rank < candidates[j].rank
+		}
+		return candidates[i].score < candidates[j].score
+	})
+
+	endpoints := make([]*rpcEndpoint, len(candidates))
+	for i, candidate := range candidates {
+		endpoints[i] = candidate.endpoint
+	}
+	return endpoints
+}
+
+// record updates the endpoint statistics after a request.
+func (p *RpcPool) record(e *rpcEndpoint, elapsed time.Duration, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.requests++
+	if !isTransportError(err) {
+		if e.latency == 0 {
+			e.latency = elapsed
+		} else {
+			e.latency = time.Duration(latencyWeight*float64(elapsed) + (1-latencyWeight)*float64(e.latency))
+		}
+		e.consecutiveFailures = 0
+		return
+	}
+
+	e.errors++
+	e.consecutiveFailures++
+	if e.consecutiveFailures >= p.opts.MaxConsecutiveFailures {
+		e.benchedUntil = time.Now().Add(p.opts.FailureCooldown)
+		e.consecutiveFailures = 0
+		log.Warn().Err(err).Str("url", redactUrl(e.url)).Dur("cooldown", p.opts.FailureCooldown).Msg("Rpc endpoint benched")
+	}
+}
+
+// isTransportError reports whether the error comes from reaching the node rather than from the node answer.
+func isTransportError(err error) bool {
+	if err == nil || errors.Is(err, ethereum.NotFound) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var rpcErr rpc.Error
+	return !errors.As(err, &rpcErr)
+}
+
+// poolDo runs fn on the healthiest endpoint and fails over to the next ones on transport errors.
+func poolDo[T any](ctx context.Context, p *RpcPool, fn func(*ethclient.Client) (T, error)) (T, error) {
+	var result T
+	var err error
+
+	for _, endpoint := range p.ordered() {
+		start := time.Now()
+		result, err = fn(endpoint.client)
+		p.record(endpoint, time.Since(start), err)
+
+		if !isTransportError(err) || ctx.Err() != nil {
+			return result, err
+		}
+		log.Debug().Err(err).Str("url", redactUrl(endpoint.url)).Msg("Rpc endpoint failed, trying the next one")
+	}
+
+	return result, err
+}
+
+func (p *RpcPool) ChainID(ctx context.Context) (*big.Int, error) {
+	return poolDo(ctx, p, func(c *ethclient.Client) (*big.Int, error) { return c.ChainID(ctx) })
+}
+
+func (p *RpcPool) BlockNumber(ctx context.Context) (uint64, error) {
+	return poolDo(ctx, p, func(c *ethclient.Client) (uint64, error) { return c.BlockNumber(ctx) })
+}
+
+func (p *RpcPool) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return poolDo(ctx, p, func(c *ethclient.Client) (*types.Header, error) { return c.HeaderByNumber(ctx, number) })
+}
+
+func (p *RpcPool) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	type txResult struct {
+		tx        *types.Transaction
+		isPending bool
+	}
+
+	result, err := poolDo(ctx, p, func(c *ethclient.Client) (txResult, error) {
+		tx, isPending, err := c.TransactionByHash(ctx, hash)
+		return txResult{tx, isPending}, err
+	})
+	return result.tx, result.isPending, err
+}
+
+func (p *RpcPool) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	return poolDo(ctx, p, func(c *ethclient.Client) (*types.Receipt, error) { return c.TransactionReceipt(ctx, txHash) })
+}
+
+func (p *RpcPool) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return poolDo(ctx, p, func(c *ethclient.Client) ([]byte, error) { return c.CodeAt(ctx, contract, blockNumber) })
+}
+
+func (p *RpcPool) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return poolDo(ctx, p, func(c *ethclient.Client) ([]byte, error) { return c.CallContract(ctx, msg, blockNumber) })
+}
+
+func (p *RpcPool) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	return poolDo(ctx, p, func(c *ethclient.Client) (uint64, error) { return c.EstimateGas(ctx, msg) })
+}
+
+func (p *RpcPool) EstimateGasAtBlock(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) (uint64, error) {
+	return poolDo(ctx, p, func(c *ethclient.Client) (uint64, error) { return c.EstimateGasAtBlock(ctx, msg, blockNumber) })
+}
+
+func (p *RpcPool) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return poolDo(ctx, p, func(c *ethclient.Client) (*big.Int, error) { return c.SuggestGasPrice(ctx) })
+}
+
+func (p *RpcPool) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return poolDo(ctx, p, func(c *ethclient.Client) (*big.Int, error) { return c.SuggestGasTipCap(ctx) })
+}
+
+func (p *RpcPool) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return poolDo(ctx, p, func(c *ethclient.Client) ([]byte, error) { return c.PendingCodeAt(ctx, account) })
+}
+
+func (p *RpcPool) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return poolDo(ctx, p, func(c *ethclient.Client) (uint64, error) { return c.PendingNonceAt(ctx, account) })
+}
+
+func (p *RpcPool) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	_, err := poolDo(ctx, p, func(c *ethclient.Client) (struct{}, error) { return struct{}{}, c.SendTransaction(ctx, tx) })
+	return err
+}
+
+// Broadcast sends the signed transaction to every endpoint of the pool in parallel and returns as soon as one of
+// them accepted it, so a slow or censoring endpoint does not hold the transaction back.
+//
+// An endpoint answering that the transaction is already known accepted it, as another endpoint propagated it first.
+// The benched endpoints are tried too, a single acceptance is enough. The other answers are collected in the
+// background, until the deadline of the context, and delivered by the Complete channel of the report.
+//
+// Parameters:
+//   - ctx: The context bounding the broadcast.
+//   - tx: The signed transaction.
+//
+// Returns:
+//   - *BroadcastReport: The answers received until the first acceptance, or of every endpoint when none accepted.
+//   - error: nil when at least one endpoint accepted the transaction, otherwise the error of a node rejecting it
+//     (e.g. nonce too low) in priority over the transport errors.
+func (p *RpcPool) Broadcast(ctx context.Context, tx *types.Transaction) (*BroadcastReport, error) {
+	// The sends outlive the cancellation of the context once the transaction is accepted, to measure every endpoint
+	sendCtx, sendCancel := context.WithCancel(context.WithoutCancel(ctx))
+	if deadline, ok := ctx.Deadline(); ok {
+		sendCtx, sendCancel = context.WithDeadline(context.WithoutCancel(ctx), deadline)
+	}
+	acks := make(chan EndpointAck, len(p.endpoints))
+	complete := make(chan *BroadcastReport, 1)
+	start := time.Now()
+
+	for _, endpoint := range p.endpoints {
+		go func(e *rpcEndpoint) {
+			err := e.client.SendTransaction(sendCtx, tx)
+			elapsed := time.Since(start)
+			p.record(e, elapsed, err)
+
+			ack := EndpointAck{Url: redactUrl(e.url), Latency: elapsed, Err: err}
+			if IsAlreadyKnown(err) {
+				ack.AlreadyKnown, ack.Err = true, nil
+			}
+			acks <- ack
+		}(endpoint)
+	}
+
+	report := &BroadcastReport{Hash: tx.Hash(), Complete: complete}
+	var nodeErr, transportErr error
+	for received := 1; received <= len(p.endpoints); received++ {
+		var ack EndpointAck
+		select {
+		case ack = <-acks:
+		case <-ctx.Done():
+			sendCancel()
+			close(complete)
+			return report, ctx.Err()
+		}
+		report.Acks = append(report.Acks, ack)
+
+		switch {
+		case ack.Err == nil:
+			// The report returned is a snapshot, the background keeps appending to its own
+			report.First = ack.Url
+			first := *report
+			first.Acks = slices.Clone(report.Acks)
+			go func() {
+				defer sendCancel()
+				for ; received < len(p.endpoints); received++ {
+					report.Acks = append(report.Acks, <-acks)
+				}
+				complete <- report
+			}()
+			return &first, nil
+		case isTransportError(ack.Err):
+			transportErr = ack.Err
+		default:
+			nodeErr = ack.Err
+		}
+	}
+
+	sendCancel()
+	complete <- report
+	if nodeErr != nil {
+		return report, nodeErr
+	}
+	return report, transportErr
+}
+
+func (p *RpcPool) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	return poolDo(ctx, p, func(c *ethclient.Client) ([]types.Log, error) { return c.FilterLogs(ctx, q) })
+}
+
+// BatchCallContext sends the batch to the healthiest endpoint, failing over when the whole batch cannot be delivered.
+func (p *RpcPool) BatchCallContext(ctx context.Context, b []rpc.BatchElem) error {
+	_, err := poolDo(ctx, p, func(c *ethclient.Client) (struct{}, error) {
+		return struct{}{}, c.Client().BatchCallContext(ctx, b)
+	})
+	return err
+}
+
+// CallContext sends a raw JSON-RPC request to the healthiest endpoint, failing over on transport errors.
+func (p *RpcPool) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	_, err := poolDo(ctx, p, func(c *ethclient.Client) (struct{}, error) {
+		return struct{}{}, c.Client().CallContext(ctx, result, method, args...)
+	})
+	return err
+}
+
+func (p *RpcPool) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return subscribe(ctx, p, func(c *ethclient.Client) (ethereum.Subscription, error) { return c.SubscribeFilterLogs(ctx, q, ch) })
+}
+
+func (p *RpcPool) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	return subscribe(ctx, p, func(c *ethclient.Client) (ethereum.Subscription, error) { return c.SubscribeNewHead(ctx, ch) })
+}
+
+// subscribe runs fn on the healthiest endpoint supporting subscriptions (WebSocket or IPC).
+func subscribe(ctx context.Context, p *RpcPool, fn func(*ethclient.Client) (ethereum.Subscription, error)) (ethereum.Subscription, error) {
+	var err error = rpc.ErrNotificationsUnsupported
+
+	for _, endpoint := range p.ordered() {
+		if strings.HasPrefix(endpoint.url, "http://") || strings.HasPrefix(endpoint.url, "https://") {
+			continue
+		}
+
+		var subscription ethereum.Subscription
+		start := time.Now()
+		subscription, err = fn(endpoint.client)
+		p.record(endpoint, time.Since(start), err)
+
+		if err == nil || ctx.Err() != nil {
+			return subscription, err
+		}
+	}
+
+	return nil, err
+}
+
+// redactUrl keeps only the scheme and host of the url, as paths, queries and credentials often hold API keys.
+func redactUrl(rawUrl string) string {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return "invalid url"
+	}
+
+	return parsedUrl.Scheme + "://" + parsedUrl.Host
+}
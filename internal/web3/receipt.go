@@ -0,0 +1,91 @@
+package web3
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"math/big"
+)
+
+// TransferTopic is the topic of the ERC-20 Transfer(address,address,uint256) event.
+var TransferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// OpReceipt is a transaction receipt with the OP stack fields, which types.Receipt does not decode.
+type OpReceipt struct {
+	TxHash            common.Hash     `json:"transactionHash"`
+	BlockNumber       *hexutil.Big    `json:"blockNumber"`
+	From              common.Address  `json:"from"`
+	To                *common.Address `json:"to"`
+	Status            hexutil.Uint64  `json:"status"`
+	GasUsed           hexutil.Uint64  `json:"gasUsed"`
+	EffectiveGasPrice *hexutil.Big    `json:"effectiveGasPrice"`
+	L1Fee             *hexutil.Big    `json:"l1Fee"`
+	Logs              []*types.Log    `json:"logs"`
+}
+
+// L2Fee returns the execution fee paid on L2, gas used times effective gas price.
+func (r *OpReceipt) L2Fee() *big.Int {
+	if r.EffectiveGasPrice == nil {
+		return big.NewInt(0)
+	}
+	return new(big.Int).Mul(new(big.Int).SetUint64(uint64(r.GasUsed)), r.EffectiveGasPrice.ToInt())
+}
+
+// L1DataFee returns the fee paid for posting the transaction on L1, zero out of the OP stack.
+func (r *OpReceipt) L1DataFee() *big.Int {
+	if r.L1Fee == nil {
+		return big.NewInt(0)
+	}
+	return new(big.Int).Set(r.L1Fee.ToInt())
+}
+
+// TransfersTo sums the ERC-20 transfers of the receipt to the recipient.
+//
+// Parameters:
+//   - recipient: The address receiving the tokens.
+//   - token: The token transferred, every token is summed when it is the zero address.
+//
+// Returns:
+//   - *big.Int: The amount received.
+func (r *OpReceipt) TransfersTo(recipient common.Address, token common.Address) *big.Int {
+	total := big.NewInt(0)
+	for _, transferLog := range r.Logs {
+		if len(transferLog.Topics) != 3 || transferLog.Topics[0] != TransferTopic {
+			continue
+		}
+		if token != (common.Address{}) && transferLog.Address != token {
+			continue
+		}
+		if common.BytesToAddress(transferLog.Topics[2].Bytes()) != recipient {
+			continue
+		}
+		total.Add(total, new(big.Int).SetBytes(transferLog.Data))
+	}
+	return total
+}
+
+// GetOpReceipt fetches the receipt of a transaction with its OP stack fields.
+//
+// Parameters:
+//   - ctx: The context of the request.
+//   - client: The client used to send the raw eth_getTransactionReceipt.
+//   - hash: The transaction hash.
+//
+// Returns:
+//   - *OpReceipt: The receipt.
+//   - error: ethereum.NotFound if the transaction is not mined, or the request error.
+func GetOpReceipt(ctx context.Context, client Client, hash common.Hash) (*OpReceipt, error) {
+	var receipt *OpReceipt
+	if err := rawCall(ctx, client, rpc.BatchElem{Method: "eth_getTransactionReceipt", Args: []interface{}{hash}, Result: &receipt}); err != nil {
+		return nil, fmt.Errorf("failed to get receipt of %s: %w", hash.Hex(), err)
+	}
+	if receipt == nil {
+		return nil, ethereum.NotFound
+	}
+	return receipt, nil
+}
@@ -0,0 +1,114 @@
+package web3
+
+import (
+	"defibotgo/internal/models"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+)
+
+// DepositTxType is the type of the OP stack deposit transactions, minted from L1 without signature.
+const DepositTxType = 0x7E
+
+// ChainIDs maps the supported chains to their chain ID.
+var ChainIDs = map[models.Chain]*big.Int{
+	models.Optimism: big.NewInt(10),
+	models.Base:     big.NewInt(8453),
+}
+
+var (
+	// ErrDepositTx is returned for OP stack deposit transactions, which have no signature to recover.
+	ErrDepositTx = errors.New("deposit transaction has no signer")
+	// ErrUnsupportedTxType is returned for the transaction types the signer cannot handle.
+	ErrUnsupportedTxType = errors.New("unsupported transaction type")
+	// ErrChainIDMismatch is returned for transactions signed for another chain.
+	ErrChainIDMismatch = errors.New("transaction signed for another chain")
+	// ErrInvalidSender is returned when the signature does not recover to a valid sender.
+	ErrInvalidSender = errors.New("invalid transaction sender")
+)
+
+// SignerForChain returns the latest signer of the chain, able to recover the sender of every transaction type
+// supported by go-ethereum.
+//
+// Parameters:
+//   - chain: The blockchain network (e.g., models.Base).
+//
+// Returns:
+//   - types.Signer: The signer configured with the chain ID.
+//   - error: An error if the chain ID of the chain is unknown.
+func SignerForChain(chain models.Chain) (types.Signer, error) {
+	chainID, ok := ChainIDs[chain]
+	if !ok {
+		return nil, fmt.Errorf("no chain ID configured for chain %s", chain)
+	}
+
+	return types.LatestSignerForChainID(chainID), nil
+}
+
+// GetSender retrieves the sender address of a given transaction.
+//
+// Parameters:
+//   - signer: The signer of the chain, as returned by SignerForChain.
+//   - tx: A pointer to the transaction from which to extract the sender address.
+//
+// Returns:
+//   - common.Address: The sender address of the transaction.
+//   - error: ErrDepositTx, ErrUnsupportedTxType, ErrChainIDMismatch or ErrInvalidSender if the sender cannot be recovered.
+func GetSender(signer types.Signer, tx *types.Transaction) (common.Address, error) {
+	if tx.Type() == DepositTxType {
+		return common.Address{}, ErrDepositTx
+	}
+
+	sender, err := types.Sender(signer, tx)
+	if err != nil {
+		return common.Address{}, classifySenderError(err)
+	}
+
+	return sender, nil
+}
+
+// classifySenderError wraps the errors of go-ethereum into the sender recovery errors.
+func classifySenderError(err error) error {
+	switch {
+	case errors.Is(err, ErrDepositTx), errors.Is(err, ErrUnsupportedTxType), errors.Is(err, ErrChainIDMismatch), errors.Is(err, ErrInvalidSender):
+		return err
+	case errors.Is(err, types.ErrTxTypeNotSupported):
+		return fmt.Errorf("%w: %v", ErrUnsupportedTxType, err)
+	case errors.Is(err, types.ErrInvalidChainId):
+		return fmt.Errorf("%w: %v", ErrChainIDMismatch, err)
+	default:
+		return fmt.Errorf("%w: %v", ErrInvalidSender, err)
+	}
+}
+
+// IgnoredTxs counts the transactions skipped while computing the competitors priority fee, by reason.
+type IgnoredTxs struct {
+	Deposit         int // OP stack deposit transactions
+	UnsupportedType int // Transaction types unknown to the signer
+	ChainIDMismatch int // Transactions signed for another chain
+	InvalidSender   int // Transactions whose sender could not be recovered
+	Unavailable     int // Transactions which could not be fetched
+}
+
+// Add counts a skipped transaction under the reason of its error.
+func (i *IgnoredTxs) Add(err error) {
+	switch {
+	case errors.Is(err, ErrDepositTx):
+		i.Deposit++
+	case errors.Is(err, ErrUnsupportedTxType):
+		i.UnsupportedType++
+	case errors.Is(err, ErrChainIDMismatch):
+		i.ChainIDMismatch++
+	case errors.Is(err, ErrInvalidSender):
+		i.InvalidSender++
+	default:
+		i.Unavailable++
+	}
+}
+
+// Total returns the number of skipped transactions.
+func (i IgnoredTxs) Total() int {
+	return i.Deposit + i.UnsupportedType + i.ChainIDMismatch + i.InvalidSender + i.Unavailable
+}
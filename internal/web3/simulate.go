@@ -0,0 +1,126 @@
+package web3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"math/big"
+	"strings"
+)
+
+// ErrReverted is matched by every RevertError.
+var ErrReverted = errors.New("execution reverted")
+
+// RevertError is the revert of a simulated call or of a mined transaction, with its decoded reason.
+type RevertError struct {
+	Reason string // The revert reason, the custom error with its arguments or the node message when undecodable
+	Data   []byte // The raw revert data, empty when the node did not return it
+}
+
+func (e *RevertError) Error() string {
+	if e.Reason == "" {
+		return ErrReverted.Error()
+	}
+	return fmt.Sprintf("%v: %s", ErrReverted, e.Reason)
+}
+
+// Is makes errors.Is(err, ErrReverted) match every RevertError.
+func (e *RevertError) Is(target error) bool {
+	return target == ErrReverted
+}
+
+// pendingBlockNumber selects the pending block in the calls
+var pendingBlockNumber = big.NewInt(int64(rpc.PendingBlockNumber))
+
+// SimulateCall executes the call at the pending block, as the transaction would be executed if sent now.
+//
+// Parameters:
+//   - ctx: The context of the request.
+//   - caller: The client executing the eth_call.
+//   - msg: The call, with the sender of the transaction as From.
+//   - contractAbi: The ABI of the called contract used to decode its custom errors, may be nil.
+//
+// Returns:
+//   - error: a *RevertError if the call reverted, the request error if the node could not simulate it, nil otherwise.
+func SimulateCall(ctx context.Context, caller bind.ContractCaller, msg ethereum.CallMsg, contractAbi *abi.ABI) error {
+	_, err := caller.CallContract(ctx, msg, pendingBlockNumber)
+	if err == nil {
+		return nil
+	}
+
+	if revertErr := DecodeRevert(err, contractAbi); revertErr != nil {
+		return revertErr
+	}
+	return fmt.Errorf("failed to simulate call: %w", err)
+}
+
+// DecodeRevert extracts the revert of a call error.
+//
+// The revert data returned by the node is decoded as an Error(string), a Panic(uint256) or a custom error
+// of the contract ABI. When the node returned no data, its message is used as reason.
+//
+// Parameters:
+//   - err: The error of the call.
+//   - contractAbi: The ABI of the called contract used to decode its custom errors, may be nil.
+//
+// Returns:
+//   - *RevertError: The revert, nil if the error is not one.
+func DecodeRevert(err error, contractAbi *abi.ABI) *RevertError {
+	if err == nil {
+		return nil
+	}
+
+	var revertErr *RevertError
+	if errors.As(err, &revertErr) {
+		return revertErr
+	}
+
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if data, ok := revertData(dataErr.ErrorData()); ok {
+			return &RevertError{Reason: DecodeRevertData(data, contractAbi), Data: data}
+		}
+	}
+
+	if strings.Contains(strings.ToLower(err.Error()), "revert") {
+		return &RevertError{Reason: strings.TrimPrefix(strings.TrimPrefix(err.Error(), ErrReverted.Error()), ": ")}
+	}
+	return nil
+}
+
+// DecodeRevertData decodes the revert data into a human-readable reason, the hex data when it matches no known error.
+func DecodeRevertData(data []byte, contractAbi *abi.ABI) string {
+	if reason, err := abi.UnpackRevert(data); err == nil {
+		return reason
+	}
+
+	if contractAbi != nil && len(data) >= 4 {
+		if abiError, err := contractAbi.ErrorByID([4]byte(data[:4])); err == nil {
+			args, err := abiError.Unpack(data)
+			if err == nil {
+				return fmt.Sprintf("%s%v", abiError.Name, args)
+			}
+			return abiError.Name
+		}
+	}
+
+	return hexutil.Encode(data)
+}
+
+// revertData converts the error data of a node answer into bytes.
+func revertData(errorData interface{}) ([]byte, bool) {
+	switch data := errorData.(type) {
+	case string:
+		decoded, err := hexutil.Decode(data)
+		return decoded, err == nil && len(decoded) > 0
+	case []byte:
+		return data, len(data) > 0
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,242 @@
+package web3
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"defibotgo/internal/config"
+	"defibotgo/internal/models"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+	"io"
+	"math/big"
+	"net/http"
+)
+
+// FlashbotsSignatureHeader is the header authenticating the requests sent to a Flashbots-style relay.
+const FlashbotsSignatureHeader = "X-Flashbots-Signature"
+
+// conditionalBlockWindow is the number of blocks after the target block a conditional transaction stays valid.
+const conditionalBlockWindow = uint64(2)
+
+// Submitter broadcasts a signed transaction, either publicly or through a private channel.
+type Submitter interface {
+	// Submit hands the transaction over for inclusion, targetBlock being the block it is meant to land in.
+	Submit(ctx context.Context, tx *types.Transaction, targetBlock *big.Int) error
+}
+
+// NewSubmitter returns the submitter of the given kind.
+//
+// Parameters:
+//   - kind: The submission method, an empty kind selects models.SubmissionPublic.
+//   - writer: The client of the write endpoints, used by the public and conditional submissions.
+//   - relayUrl: The relay endpoint used by models.SubmissionBundle, in the RPC_NODE_* format; the write endpoints when empty.
+//   - authKey: The key signing the bundle requests, identifying the searcher to the relay.
+//
+// Returns:
+//   - Submitter: The submitter.
+//   - error: An error if the kind is unknown or the relay cannot be dialed.
+func NewSubmitter(kind models.SubmissionKind, writer Client, relayUrl string, authKey *ecdsa.PrivateKey) (Submitter, error) {
+	switch kind {
+	case "", models.SubmissionPublic:
+		return &publicSubmitter{client: writer}, nil
+	case models.SubmissionBundle:
+		if relayUrl == "" {
+			caller := asRawCaller(writer)
+			if caller == nil {
+				return nil, fmt.Errorf("write client cannot send bundles")
+			}
+			return &bundleSubmitter{caller: caller}, nil
+		}
+		relay, err := DialRelay(context.Background(), relayUrl, authKey)
+		if err != nil {
+			return nil, err
+		}
+		return &bundleSubmitter{caller: relay}, nil
+	case models.SubmissionConditional:
+		caller := asRawCaller(writer)
+		if caller == nil {
+			return nil, fmt.Errorf("write client cannot send conditional transactions")
+		}
+		return &conditionalSubmitter{caller: caller}, nil
+	default:
+		return nil, fmt.Errorf("unknown submission %q", kind)
+	}
+}
+
+// DialRelay connects to a Flashbots-style relay. Every request body is signed with authKey in the
+// FlashbotsSignatureHeader, the other headers of the endpoint are sent as is.
+//
+// Parameters:
+//   - ctx: The context of the dial.
+//   - relayUrl: The relay endpoint, in the RPC_NODE_* format.
+//   - authKey: The key signing the requests, no signature is sent when nil.
+//
+// Returns:
+//   - *rpc.Client: The client of the relay.
+//   - error: An error if the url is empty or cannot be dialed.
+func DialRelay(ctx context.Context, relayUrl string, authKey *ecdsa.PrivateKey) (*rpc.Client, error) {
+	endpoints := config.ParseRpcEndpoints(relayUrl)
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("relay url is empty")
+	}
+
+	headers := http.Header{}
+	for key, value := range endpoints[0].Headers {
+		headers.Set(key, value)
+	}
+
+	httpClient := &http.Client{Transport: &flashbotsTransport{key: authKey, base: http.DefaultTransport}}
+	relay, err := rpc.DialOptions(ctx, endpoints[0].Url, rpc.WithHeaders(headers), rpc.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial relay %s: %v", redactUrl(endpoints[0].Url), err)
+	}
+
+	return relay, nil
+}
+
+// Broadcaster is implemented by the clients able to send a transaction to several endpoints at once.
+type Broadcaster interface {
+	Broadcast(ctx context.Context, tx *types.Transaction) (*BroadcastReport, error)
+}
+
+// publicSubmitter sends the transaction to the public mempool with eth_sendRawTransaction, through every
+// write endpoint at once when the client is a Broadcaster.
+type publicSubmitter struct {
+	client TxSender
+}
+
+func (s *publicSubmitter) Submit(ctx context.Context, tx *types.Transaction, targetBlock *big.Int) error {
+	broadcaster, ok := s.client.(Broadcaster)
+	if !ok {
+		err := s.client.SendTransaction(ctx, tx)
+		if IsAlreadyKnown(err) {
+			return nil
+		}
+		return err
+	}
+
+	report, err := broadcaster.Broadcast(ctx, tx)
+	if err == nil {
+		log.Info().Str("hash", tx.Hash().Hex()).Str("first", report.First).Dur("latency", report.Acks[len(report.Acks)-1].Latency).Msg("Broadcast transaction")
+	}
+
+	// The latency of every endpoint is logged once the slowest one answered
+	go func() {
+		complete, ok := <-report.Complete
+		if !ok {
+			return
+		}
+		for _, ack := range complete.Acks {
+			log.Debug().Err(ack.Err).Str("hash", tx.Hash().Hex()).Str("url", ack.Url).Dur("latency", ack.Latency).Bool("already known", ack.AlreadyKnown).Msg("Broadcast answer")
+		}
+		log.Info().Str("hash", tx.Hash().Hex()).Int("accepted", complete.Accepted()).Int("endpoints", len(complete.Acks)).Msg("Broadcast answers")
+	}()
+	return err
+}
+
+// bundleSubmitter sends the transaction alone in a bundle only valid for the target block with eth_sendBundle.
+// The transaction never reaches the public mempool, a bundle not included is simply dropped: see
+// PendingTxManager.WaitBundle.
+type bundleSubmitter struct {
+	caller RawCaller
+}
+
+type bundleArgs struct {
+	Txs         []hexutil.Bytes `json:"txs"`
+	BlockNumber hexutil.Uint64  `json:"blockNumber"`
+}
+
+type bundleResult struct {
+	BundleHash string `json:"bundleHash"`
+}
+
+func (s *bundleSubmitter) Submit(ctx context.Context, tx *types.Transaction, targetBlock *big.Int) error {
+	rawTx, err := tx.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("rlp encode: %w", err)
+	}
+
+	var result bundleResult
+	args := bundleArgs{Txs: []hexutil.Bytes{rawTx}, BlockNumber: hexutil.Uint64(targetBlock.Uint64())}
+	if err := s.caller.CallContext(ctx, &result, "eth_sendBundle", args); err != nil {
+		return fmt.Errorf("failed to send bundle: %w", err)
+	}
+
+	return nil
+}
+
+// conditionalSubmitter sends the transaction with the OP stack eth_sendRawTransactionConditional, so the
+// sequencer drops it instead of including it late once the window after the target block is over.
+type conditionalSubmitter struct {
+	caller RawCaller
+}
+
+type conditionalOptions struct {
+	KnownAccounts  map[string]interface{} `json:"knownAccounts"`
+	BlockNumberMax hexutil.Uint64         `json:"blockNumberMax"`
+}
+
+func (s *conditionalSubmitter) Submit(ctx context.Context, tx *types.Transaction, targetBlock *big.Int) error {
+	rawTx, err := tx.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("rlp encode: %w", err)
+	}
+
+	options := conditionalOptions{
+		KnownAccounts:  map[string]interface{}{},
+		BlockNumberMax: hexutil.Uint64(targetBlock.Uint64() + conditionalBlockWindow),
+	}
+	if err := s.caller.CallContext(ctx, nil, "eth_sendRawTransactionConditional", hexutil.Bytes(rawTx), options); err != nil {
+		return fmt.Errorf("failed to send conditional transaction: %w", err)
+	}
+
+	return nil
+}
+
+// flashbotsTransport signs the body of every request as expected by the Flashbots relays:
+// the header holds the address of the key and its signature of the hex encoded keccak of the body.
+type flashbotsTransport struct {
+	key  *ecdsa.PrivateKey
+	base http.RoundTripper
+}
+
+func (t *flashbotsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.key == nil || req.Body == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	_ = req.Body.Close()
+
+	signature, err := SignFlashbotsPayload(body, t.key)
+	if err != nil {
+		return nil, err
+	}
+
+	signedReq := req.Clone(req.Context())
+	signedReq.Body = io.NopCloser(bytes.NewReader(body))
+	signedReq.ContentLength = int64(len(body))
+	signedReq.Header.Set(FlashbotsSignatureHeader, signature)
+
+	return t.base.RoundTrip(signedReq)
+}
+
+// SignFlashbotsPayload returns the FlashbotsSignatureHeader value of a request body.
+func SignFlashbotsPayload(body []byte, key *ecdsa.PrivateKey) (string, error) {
+	hash := accounts.TextHash([]byte(hexutil.Encode(crypto.Keccak256(body))))
+	signature, err := crypto.Sign(hash, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign relay request: %v", err)
+	}
+
+	return crypto.PubkeyToAddress(key.PublicKey).Hex() + ":" + hexutil.Encode(signature), nil
+}
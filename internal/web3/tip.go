@@ -0,0 +1,170 @@
+package web3
+
+import (
+	"context"
+	"defibotgo/internal/models"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+	"math/big"
+)
+
+// TipResolver computes the estimated tip once the batch it was prepared with has been sent.
+type TipResolver func(ctx context.Context, client Client) (*big.Int, error)
+
+// TipEstimator estimates the priority fee paid by the competitors of a contract.
+//
+// The estimation is split in two steps so its requests travel in the batch of the iteration:
+// Prepare adds the requests to the batch, and the returned resolver computes the tip from their results.
+type TipEstimator interface {
+	Prepare(batch *Batch, toBlock *big.Int) TipResolver
+}
+
+// NewTipEstimator returns the estimator of the given kind.
+//
+// Parameters:
+//   - kind: The estimation method, an empty kind selects models.TipEstimatorLogs.
+//   - signer: The signer of the chain used to recover the transactions sender, as returned by SignerForChain.
+//   - senderAddress: The Ethereum address of the sender whose transactions are to be excluded.
+//   - contractAddress: The contract's Ethereum address for which recent transactions are analyzed.
+//   - blockRange: The number of blocks before toBlock to analyze.
+//   - percentile: The reward percentile used by models.TipEstimatorFeeHistory, between 0 and 100.
+//
+// Returns:
+//   - TipEstimator: The estimator.
+//   - error: An error if the kind is unknown or the percentile out of range.
+func NewTipEstimator(kind models.TipEstimatorKind, signer types.Signer, senderAddress common.Address, contractAddress common.Address, blockRange *big.Int, percentile float64) (TipEstimator, error) {
+	if blockRange == nil {
+		blockRange = big.NewInt(0)
+	}
+
+	switch kind {
+	case "", models.TipEstimatorLogs:
+		return &logsTipEstimator{signer: signer, sender: senderAddress, contract: contractAddress, blockRange: blockRange}, nil
+	case models.TipEstimatorFeeHistory:
+		if percentile < 0 || percentile > 100 {
+			return nil, fmt.Errorf("fee history percentile %v out of range", percentile)
+		}
+		return &feeHistoryTipEstimator{blockCount: blockCount(blockRange), percentile: percentile}, nil
+	case models.TipEstimatorBlockReceipts:
+		return &receiptsTipEstimator{sender: senderAddress, contract: contractAddress, blockCount: blockCount(blockRange)}, nil
+	default:
+		return nil, fmt.Errorf("unknown tip estimator %q", kind)
+	}
+}
+
+// blockCount returns the number of blocks covered by a range, toBlock included, as PastTransactionsQuery does.
+func blockCount(blockRange *big.Int) uint64 {
+	return blockRange.Uint64() + 1
+}
+
+// logsTipEstimator is the maximum tip of the transactions which emitted logs on the contract.
+// It only sees competitors whose transactions emitted logs, and needs a second batch to fetch them.
+type logsTipEstimator struct {
+	signer     types.Signer
+	sender     common.Address
+	contract   common.Address
+	blockRange *big.Int
+}
+
+func (e *logsTipEstimator) Prepare(batch *Batch, toBlock *big.Int) TipResolver {
+	logs := batch.FilterLogs(PastTransactionsQuery(e.contract, e.blockRange, toBlock))
+
+	return func(ctx context.Context, client Client) (*big.Int, error) {
+		if logs.Err != nil {
+			return nil, fmt.Errorf("failed to filter logs: %v", logs.Err)
+		}
+		maxPriorityFee, ignoredTxs, err := GetPriorityFeeFromLogs(ctx, client, e.signer, e.sender, logs.Value)
+		if ignoredTxs.Total() > 0 {
+			log.Debug().Interface("ignored", ignoredTxs).Msg("Transactions ignored for the priority fee")
+		}
+		return maxPriorityFee, err
+	}
+}
+
+// feeHistoryTipEstimator is the maximum over the recent blocks of the eth_feeHistory reward percentile.
+// It reflects the whole block space and not only the competitors of the contract.
+type feeHistoryTipEstimator struct {
+	blockCount uint64
+	percentile float64
+}
+
+func (e *feeHistoryTipEstimator) Prepare(batch *Batch, toBlock *big.Int) TipResolver {
+	feeHistory := batch.FeeHistory(e.blockCount, toBlock, []float64{e.percentile})
+
+	return func(ctx context.Context, client Client) (*big.Int, error) {
+		if feeHistory.Err != nil {
+			return nil, fmt.Errorf("failed to get fee history: %v", feeHistory.Err)
+		}
+
+		maxPriorityFee := big.NewInt(0)
+		for _, rewards := range feeHistory.Value.Reward {
+			if len(rewards) > 0 && rewards[0] != nil && rewards[0].Cmp(maxPriorityFee) == 1 {
+				maxPriorityFee = rewards[0]
+			}
+		}
+
+		return maxPriorityFee, nil
+	}
+}
+
+// receiptsTipEstimator is the maximum tip paid by the transactions sent to the contract in the recent blocks,
+// found in their receipts. Unlike the logs, it also sees the reverted transactions of the competitors.
+type receiptsTipEstimator struct {
+	sender     common.Address
+	contract   common.Address
+	blockCount uint64
+}
+
+func (e *receiptsTipEstimator) Prepare(batch *Batch, toBlock *big.Int) TipResolver {
+	// The base fees are needed to get the tip out of the effective gas price
+	feeHistory := batch.FeeHistory(e.blockCount, toBlock, nil)
+
+	fromBlock := uint64(0)
+	if toBlock.Uint64() >= e.blockCount {
+		fromBlock = toBlock.Uint64() - e.blockCount + 1
+	}
+
+	receipts := make(map[uint64]*BatchResult[[]*ReceiptSummary], e.blockCount)
+	for n := fromBlock; n <= toBlock.Uint64(); n++ {
+		receipts[n] = batch.BlockReceipts(new(big.Int).SetUint64(n))
+	}
+
+	return func(ctx context.Context, client Client) (*big.Int, error) {
+		if feeHistory.Err != nil {
+			return nil, fmt.Errorf("failed to get fee history: %v", feeHistory.Err)
+		}
+
+		baseFees := make(map[uint64]*big.Int, len(feeHistory.Value.BaseFee))
+		for i, baseFee := range feeHistory.Value.BaseFee {
+			baseFees[feeHistory.Value.OldestBlock.Uint64()+uint64(i)] = baseFee
+		}
+
+		maxPriorityFee := big.NewInt(0)
+		for n, blockReceipts := range receipts {
+			if blockReceipts.Err != nil {
+				return nil, fmt.Errorf("failed to get receipts of block %v: %v", n, blockReceipts.Err)
+			}
+
+			baseFee, ok := baseFees[n]
+			if !ok {
+				return nil, fmt.Errorf("no base fee for block %v", n)
+			}
+
+			for _, receipt := range blockReceipts.Value {
+				// Deposit transactions are minted from L1 and pay no tip
+				if receipt.Type == DepositTxType || receipt.To == nil || *receipt.To != e.contract || receipt.From == e.sender || receipt.EffectiveGasPrice == nil {
+					continue
+				}
+
+				tip := new(big.Int).Sub(receipt.EffectiveGasPrice.ToInt(), baseFee)
+				if tip.Cmp(maxPriorityFee) == 1 {
+					maxPriorityFee = tip
+				}
+			}
+		}
+
+		return maxPriorityFee, nil
+	}
+}
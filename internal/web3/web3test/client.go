@@ -0,0 +1,277 @@
+// Package web3test provides an in-memory web3.Client to exercise the bot without a live RPC node.
+package web3test
+
+import (
+	"context"
+	"defibotgo/internal/web3"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"sync"
+)
+
+// Client is a fake chain client backed by maps. The zero value is not usable, build it with NewClient.
+//
+// Every field can be set directly before use; the methods only read them under the mutex
+// (except Sent which is appended by SendTransaction).
+type Client struct {
+	mu sync.Mutex
+
+	ChainIDValue *big.Int
+	Head         uint64
+	Headers      map[uint64]*types.Header
+	Transactions map[common.Hash]*types.Transaction
+	Receipts     map[common.Hash]*types.Receipt
+	Logs         []types.Log
+	Nonces       map[common.Address]uint64
+	Codes        map[common.Address][]byte
+
+	GasEstimate uint64
+	GasPrice    *big.Int
+	GasTipCap   *big.Int
+
+	// CallContractFn answers eth_call; returns an error when nil
+	CallContractFn func(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+	// SendErr is returned by SendTransaction when set
+	SendErr error
+	// Sent records every transaction given to SendTransaction
+	Sent []*types.Transaction
+	// Calls counts the method invocations by name
+	Calls map[string]int
+}
+
+var _ web3.Client = (*Client)(nil)
+
+// NewClient returns an empty fake client for the given chain ID.
+func NewClient(chainID int64) *Client {
+	return &Client{
+		ChainIDValue: big.NewInt(chainID),
+		Headers:      map[uint64]*types.Header{},
+		Transactions: map[common.Hash]*types.Transaction{},
+		Receipts:     map[common.Hash]*types.Receipt{},
+		Nonces:       map[common.Address]uint64{},
+		Codes:        map[common.Address][]byte{},
+		GasPrice:     big.NewInt(0),
+		GasTipCap:    big.NewInt(0),
+		Calls:        map[string]int{},
+	}
+}
+
+// AddHeader stores the header and moves the head forward if needed.
+func (c *Client) AddHeader(header *types.Header) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	number := header.Number.Uint64()
+	c.Headers[number] = header
+	if number > c.Head {
+		c.Head = number
+	}
+}
+
+// AddTransaction stores the transaction and, when log is not nil, a log pointing to it.
+func (c *Client) AddTransaction(tx *types.Transaction, log *types.Log) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Transactions[tx.Hash()] = tx
+	if log != nil {
+		log.TxHash = tx.Hash()
+		c.Logs = append(c.Logs, *log)
+	}
+}
+
+// AddReceipt stores the receipt of the transaction, marking it as mined.
+func (c *Client) AddReceipt(receipt *types.Receipt) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Receipts[receipt.TxHash] = receipt
+}
+
+// SentTransactions returns a copy of the transactions given to SendTransaction.
+func (c *Client) SentTransactions() []*types.Transaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]*types.Transaction(nil), c.Sent...)
+}
+
+func (c *Client) count(method string) {
+	c.Calls[method]++
+}
+
+func (c *Client) ChainID(ctx context.Context) (*big.Int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count("ChainID")
+
+	return new(big.Int).Set(c.ChainIDValue), nil
+}
+
+func (c *Client) BlockNumber(ctx context.Context) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count("BlockNumber")
+
+	return c.Head, nil
+}
+
+func (c *Client) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count("HeaderByNumber")
+
+	n := c.Head
+	if number != nil {
+		n = number.Uint64()
+	}
+
+	header, ok := c.Headers[n]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return header, nil
+}
+
+func (c *Client) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count("TransactionByHash")
+
+	tx, ok := c.Transactions[hash]
+	if !ok {
+		return nil, false, ethereum.NotFound
+	}
+	_, mined := c.Receipts[hash]
+	return tx, !mined, nil
+}
+
+func (c *Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count("TransactionReceipt")
+
+	receipt, ok := c.Receipts[txHash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return receipt, nil
+}
+
+func (c *Client) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count("CodeAt")
+
+	return c.Codes[contract], nil
+}
+
+func (c *Client) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count("PendingCodeAt")
+
+	return c.Codes[account], nil
+}
+
+func (c *Client) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	c.mu.Lock()
+	fn := c.CallContractFn
+	c.count("CallContract")
+	c.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("web3test: no CallContractFn configured")
+	}
+	return fn(ctx, msg, blockNumber)
+}
+
+func (c *Client) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count("EstimateGas")
+
+	return c.GasEstimate, nil
+}
+
+func (c *Client) EstimateGasAtBlock(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count("EstimateGasAtBlock")
+
+	return c.GasEstimate, nil
+}
+
+func (c *Client) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count("SuggestGasPrice")
+
+	return new(big.Int).Set(c.GasPrice), nil
+}
+
+func (c *Client) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count("SuggestGasTipCap")
+
+	return new(big.Int).Set(c.GasTipCap), nil
+}
+
+func (c *Client) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count("PendingNonceAt")
+
+	return c.Nonces[account], nil
+}
+
+func (c *Client) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count("SendTransaction")
+
+	if c.SendErr != nil {
+		return c.SendErr
+	}
+	c.Sent = append(c.Sent, tx)
+	c.Transactions[tx.Hash()] = tx
+	return nil
+}
+
+func (c *Client) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count("FilterLogs")
+
+	var logs []types.Log
+	for _, l := range c.Logs {
+		if q.FromBlock != nil && l.BlockNumber < q.FromBlock.Uint64() {
+			continue
+		}
+		if q.ToBlock != nil && l.BlockNumber > q.ToBlock.Uint64() {
+			continue
+		}
+		if len(q.Addresses) > 0 && !containsAddress(q.Addresses, l.Address) {
+			continue
+		}
+		logs = append(logs, l)
+	}
+	return logs, nil
+}
+
+func (c *Client) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return nil, fmt.Errorf("web3test: subscriptions are not supported")
+}
+
+func containsAddress(addresses []common.Address, address common.Address) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,352 @@
+package web3test
+
+import (
+	"bytes"
+	"defibotgo/internal/web3"
+	"encoding/json"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EthService is a minimal "eth" JSON-RPC namespace served by NewRPCServer.
+type EthService struct {
+	mu sync.Mutex
+
+	Head        uint64
+	ChainID     int64
+	Nonces      map[common.Address]uint64
+	GasEstimate uint64
+
+	// BaseFees and Rewards answer eth_feeHistory, the reward is the same for every percentile
+	BaseFees map[uint64]*big.Int
+	Rewards  map[uint64]*big.Int
+	// BlockReceipts answers eth_getBlockReceipts
+	BlockReceipts map[uint64][]*web3.ReceiptSummary
+	// Transactions answers eth_getTransactionByHash with raw JSON, to serve types go-ethereum cannot encode
+	Transactions map[common.Hash]json.RawMessage
+	// Receipts answers eth_getTransactionReceipt with raw JSON, to serve the OP stack fields
+	Receipts map[common.Hash]json.RawMessage
+	// Logs answers eth_getLogs, which fails when nil; Headers answers eth_getBlockByNumber
+	Logs    []types.Log
+	Headers map[uint64]*types.Header
+	// CallResult answers eth_call, or CallErr when set; the block overrides of the last call are kept in CallBlockOverrides
+	CallResult         hexutil.Bytes
+	CallErr            error
+	CallBlockOverrides map[string]interface{}
+}
+
+type feeHistory struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas,omitempty"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+func (s *EthService) SetHead(head uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Head = head
+}
+
+func (s *EthService) BlockNumber() hexutil.Uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return hexutil.Uint64(s.Head)
+}
+
+func (s *EthService) ChainId() *hexutil.Big {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return (*hexutil.Big)(big.NewInt(s.ChainID))
+}
+
+func (s *EthService) GetTransactionCount(account common.Address, block rpc.BlockNumberOrHash) hexutil.Uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return hexutil.Uint64(s.Nonces[account])
+}
+
+func (s *EthService) EstimateGas(args map[string]interface{}, block *rpc.BlockNumberOrHash) hexutil.Uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return hexutil.Uint64(s.GasEstimate)
+}
+
+func (s *EthService) Call(args map[string]interface{}, block rpc.BlockNumberOrHash, stateOverrides *map[common.Address]interface{}, blockOverrides *map[string]interface{}) (hexutil.Bytes, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.CallBlockOverrides = nil
+	if blockOverrides != nil {
+		s.CallBlockOverrides = *blockOverrides
+	}
+	if s.CallErr != nil {
+		return nil, s.CallErr
+	}
+	return s.CallResult, nil
+}
+
+// RevertError is an eth_call error carrying revert data, as returned by the nodes.
+type RevertError struct {
+	Data []byte
+}
+
+func (e *RevertError) Error() string {
+	return "execution reverted"
+}
+
+func (e *RevertError) ErrorCode() int {
+	return 3
+}
+
+func (e *RevertError) ErrorData() interface{} {
+	return hexutil.Encode(e.Data)
+}
+
+func (s *EthService) FeeHistory(blockCount hexutil.Uint, newest rpc.BlockNumber, percentiles []float64) *feeHistory {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last := s.Head
+	if newest >= 0 {
+		last = uint64(newest)
+	}
+	oldest := last + 1 - uint64(blockCount)
+
+	history := &feeHistory{OldestBlock: (*hexutil.Big)(new(big.Int).SetUint64(oldest))}
+	for n := oldest; n <= last+1; n++ {
+		history.BaseFee = append(history.BaseFee, (*hexutil.Big)(s.BaseFees[n]))
+		if n > last {
+			break
+		}
+		history.GasUsedRatio = append(history.GasUsedRatio, 0.5)
+		if len(percentiles) > 0 {
+			rewards := make([]*hexutil.Big, len(percentiles))
+			for i := range rewards {
+				rewards[i] = (*hexutil.Big)(s.Rewards[n])
+			}
+			history.Reward = append(history.Reward, rewards)
+		}
+	}
+
+	return history
+}
+
+func (s *EthService) GetBlockReceipts(block rpc.BlockNumber) []*web3.ReceiptSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.BlockReceipts[uint64(block)]
+}
+
+func (s *EthService) GetTransactionByHash(hash common.Hash) json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, ok := s.Transactions[hash]
+	if !ok {
+		return json.RawMessage("null")
+	}
+	return tx
+}
+
+func (s *EthService) GetTransactionReceipt(hash common.Hash) json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	receipt, ok := s.Receipts[hash]
+	if !ok {
+		return json.RawMessage("null")
+	}
+	return receipt
+}
+
+// logFilter is the filter of eth_getLogs as sent by go-ethereum
+type logFilter struct {
+	FromBlock *hexutil.Big     `json:"fromBlock"`
+	ToBlock   *hexutil.Big     `json:"toBlock"`
+	Address   []common.Address `json:"address"`
+	Topics    [][]common.Hash  `json:"topics"`
+}
+
+func (f *logFilter) matches(log *types.Log) bool {
+	if f.FromBlock != nil && log.BlockNumber < f.FromBlock.ToInt().Uint64() {
+		return false
+	}
+	if f.ToBlock != nil && log.BlockNumber > f.ToBlock.ToInt().Uint64() {
+		return false
+	}
+	if len(f.Address) > 0 && !slices.Contains(f.Address, log.Address) {
+		return false
+	}
+	for i, topics := range f.Topics {
+		if len(topics) == 0 {
+			continue
+		}
+		if i >= len(log.Topics) || !slices.Contains(topics, log.Topics[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *EthService) GetLogs(filter logFilter) ([]types.Log, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Logs == nil {
+		return nil, errors.New("eth_getLogs is not served")
+	}
+
+	logs := []types.Log{}
+	for i := range s.Logs {
+		if filter.matches(&s.Logs[i]) {
+			logs = append(logs, s.Logs[i])
+		}
+	}
+	return logs, nil
+}
+
+func (s *EthService) GetBlockByNumber(number rpc.BlockNumber, fullTx bool) *types.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Headers[uint64(number)]
+}
+
+// RelayService is a stand-in "eth" namespace of a private relay, recording the raw transactions it receives.
+type RelayService struct {
+	mu sync.Mutex
+
+	// SendErr is returned by eth_sendRawTransaction when set
+	SendErr error
+	// SendDelay delays the answer of eth_sendRawTransaction, as a slow endpoint
+	SendDelay time.Duration
+	// RawTransactions records the transactions of eth_sendRawTransaction
+	RawTransactions []hexutil.Bytes
+	// Bundles records the eth_sendBundle requests
+	Bundles []RelayBundle
+	// Conditionals records the eth_sendRawTransactionConditional requests
+	Conditionals []RelayConditional
+}
+
+// RelayBundle is an eth_sendBundle request.
+type RelayBundle struct {
+	Txs         []hexutil.Bytes `json:"txs"`
+	BlockNumber hexutil.Uint64  `json:"blockNumber"`
+}
+
+// RelayConditional is an eth_sendRawTransactionConditional request.
+type RelayConditional struct {
+	Tx      hexutil.Bytes
+	Options RelayConditionalOptions
+}
+
+// RelayConditionalOptions are the conditions of an eth_sendRawTransactionConditional request.
+type RelayConditionalOptions struct {
+	KnownAccounts  map[string]interface{} `json:"knownAccounts"`
+	BlockNumberMin *hexutil.Uint64        `json:"blockNumberMin"`
+	BlockNumberMax *hexutil.Uint64        `json:"blockNumberMax"`
+}
+
+func (s *RelayService) SendRawTransaction(tx hexutil.Bytes) (common.Hash, error) {
+	time.Sleep(s.SendDelay)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.SendErr != nil {
+		return common.Hash{}, s.SendErr
+	}
+	s.RawTransactions = append(s.RawTransactions, tx)
+	return crypto.Keccak256Hash(tx), nil
+}
+
+func (s *RelayService) SendBundle(bundle RelayBundle) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Bundles = append(s.Bundles, bundle)
+	var txs []byte
+	for _, tx := range bundle.Txs {
+		txs = append(txs, tx...)
+	}
+	return map[string]string{"bundleHash": crypto.Keccak256Hash(txs).Hex()}
+}
+
+func (s *RelayService) SendRawTransactionConditional(tx hexutil.Bytes, options RelayConditionalOptions) common.Hash {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Conditionals = append(s.Conditionals, RelayConditional{Tx: tx, Options: options})
+	return crypto.Keccak256Hash(tx)
+}
+
+// NewRPCHandler returns a JSON-RPC handler serving the given services, keyed by namespace (e.g. "eth").
+func NewRPCHandler(services map[string]interface{}) http.Handler {
+	server := rpc.NewServer()
+	for namespace, service := range services {
+		if err := server.RegisterName(namespace, service); err != nil {
+			panic(err)
+		}
+	}
+
+	return server
+}
+
+// NewRPCServer serves the given services over HTTP. The server must be closed by the caller.
+func NewRPCServer(services map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(NewRPCHandler(services))
+}
+
+// NewFailingServer answers every request with the given HTTP status code.
+func NewFailingServer(statusCode int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, http.StatusText(statusCode), statusCode)
+	}))
+}
+
+// CountRequests wraps the handler and increments counter on every HTTP request, a JSON-RPC batch counting once.
+func CountRequests(handler http.Handler, counter *atomic.Int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter.Add(1)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// CaptureRequest wraps the handler and stores the header value and the body of the last request.
+func CaptureRequest(handler http.Handler, key string, value *atomic.Value) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		value.Store(CapturedRequest{Header: r.Header.Get(key), Body: body})
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// CapturedRequest is the header value and the body of a request seen by CaptureRequest.
+type CapturedRequest struct {
+	Header string
+	Body   []byte
+}
+
+// RequireHeader wraps the handler and rejects the requests missing the header value with 401.
+func RequireHeader(handler http.Handler, key string, value string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(key) != value {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
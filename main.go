@@ -2,89 +2,97 @@ package main
 
 import (
 	"context"
+	"defibotgo/internal/accounting"
+	"defibotgo/internal/competition"
 	"defibotgo/internal/config"
 	"defibotgo/internal/logging"
 	"defibotgo/internal/models"
 	protocolconfig "defibotgo/internal/protocols/config"
+	"defibotgo/internal/protocols/harvest"
+	"defibotgo/internal/protocols/impermax"
 	"defibotgo/internal/protocols/tarot"
 	"defibotgo/internal/web3"
+	"errors"
 	"flag"
-	"fmt"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/rs/zerolog/log"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
-	"time"
 )
 
-var validChains = map[models.Chain]bool{
-	models.Optimism: true,
-	models.Base:     true,
+// harvesterRegistry builds the harvester of the pools of every protocol
+var harvesterRegistry = harvest.Registry{
+	models.Tarot:    tarot.NewHarvester,
+	models.Impermax: impermax.NewHarvester,
 }
 
-var validProtocols = map[models.Protocol]bool{
-	models.Tarot:    true,
-	models.Impermax: true,
-}
-
-var validPools = map[models.Pool]bool{
-	models.FbombCbbtc: true,
-	models.UsdcAero:   true,
-	models.WethTarot:  true,
-	models.AeroTarot:  true,
-}
-
-var poolRegistry = map[string]map[string]map[string]models.TarotOpts{
-	string(models.Base): {
-		string(models.Tarot): {
-			string(models.UsdcAero):  protocolconfig.TarotBaseUsdcAero,
-			string(models.WethTarot): protocolconfig.TarotBaseWethTarot,
-			string(models.AeroTarot): protocolconfig.TarotBaseAeroTarot,
-		},
-		string(models.Impermax): {
-			string(models.FbombCbbtc): protocolconfig.ImpermaxBaseSTKDUNIV2,
-		},
-	},
-}
-
-var walletRegistry = map[string]string{
-	strings.ToUpper(config.GetSecret(config.WalletTarotAddressOne)):    config.GetSecret(config.WalletTarotKeyOne),
-	strings.ToUpper(config.GetSecret(config.WalletTarotAddressTwo)):    config.GetSecret(config.WalletTarotKeyTwo),
-	strings.ToUpper(config.GetSecret(config.WalletTarotAddressThree)):  config.GetSecret(config.WalletTarotKeyThree),
-	strings.ToUpper(config.GetSecret(config.WalletImpermaxAddressOne)): config.GetSecret(config.WalletImpermaxKeyOne),
-}
+var nonceRegistry = web3.NewNonceRegistry()
 
 func main() {
 	logging.Init()
 	rootCtx, rootCancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer rootCancel()
 
-	// Get command args to build the pool opts
-	chain, protocol, poolID := getCmdArgs()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case reportCommand:
+			if err := runReport(rootCtx, os.Args[2:]); err != nil {
+				log.Fatal().Err(err).Msg("Error exporting report")
+			}
+			return
+		case competitorsCommand:
+			if err := runCompetitors(rootCtx, os.Args[2:]); err != nil {
+				log.Fatal().Err(err).Msg("Error summarizing competitors")
+			}
+			return
+		case superviseCommand:
+			if err := runSupervise(rootCtx, os.Args[2:]); err != nil {
+				log.Fatal().Err(err).Msg("Error supervising pools")
+			}
+			return
+		}
+	}
 
-	ethClient, err := web3.BuildWeb3Client(chain, true)
-	ethClientWriter, err2 := web3.BuildWeb3Client(chain, false)
+	// Get command args to build the pool opts
+	configPath, chain, protocol, poolID := getCmdArgs()
 
-	if err != nil || err2 != nil {
-		log.Fatal().Err(err).Msg("Error building eth client")
+	// Only the secrets of the pool, of its chain and of its wallet are required
+	selection := protocolconfig.Selection{
+		Match: func(opts *models.PoolOpts) bool {
+			return opts.Chain == chain && opts.Protocol == protocol && opts.Pool == poolID
+		},
+		Keys: true,
 	}
-
-	poolOpts, poolErr := getPoolOpts(chain, protocol, poolID)
+	cfg, err := loadConfig(configPath, selection)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error loading config")
+	}
+	protocolOpts, poolErr := cfg.Pool(chain, protocol, poolID)
 	if poolErr != nil {
 		log.Fatal().Err(poolErr).Msg("Error getting pool")
 	}
+	poolOpts := protocolOpts.Opts()
 
-	if poolOpts.Sender == protocolconfig.ZeroAddress {
-		log.Fatal().Msg("pool options sender is null")
-	}
+	// The tunables of the pool follow the config file, reloaded on SIGHUP or when it changes
+	reloader := protocolconfig.NewReloader(configPath, selection, cfg)
+	liveOpts := reloader.Opts(protocolOpts)
+	watchConfig(rootCtx, reloader)
 
-	senderAddress := strings.ToUpper(poolOpts.Sender.Hex())
-	walletPrivateKey := walletRegistry[senderAddress]
+	ethClient, err := web3.BuildWeb3Client(chain, true)
+	ethClientWriter, err2 := web3.BuildWeb3Client(chain, false)
 
-	if walletPrivateKey == "" {
-		log.Fatal().Msg("wallet test private key not found")
+	if err != nil || err2 != nil {
+		log.Fatal().Err(errors.Join(err, err2)).Msg("Error building eth client")
+	}
+	ethClient.Start(rootCtx)
+	ethClientWriter.Start(rootCtx)
+
+	senderAddress := poolOpts.Sender.Hex()
+	walletPrivateKey, err := cfg.PrivateKey(poolOpts.Sender)
+	if err != nil {
+		log.Fatal().Err(err).Msg("wallet private key not found")
 	}
 
 	walletPrivateKeyCiph, err := crypto.HexToECDSA(walletPrivateKey)
@@ -92,111 +100,128 @@ func main() {
 		log.Fatal().Err(err).Msg("wallet private key error")
 	}
 
+	// The nonces of a wallet are shared by every pool it sends transactions for
+	nonceManager := nonceRegistry.Get(chain, ethClientWriter, poolOpts.Sender)
+	if err := nonceManager.Sync(rootCtx); err != nil {
+		log.Fatal().Err(err).Msg("Error syncing nonce")
+	}
+
 	blockNumber, err := ethClient.BlockNumber(rootCtx)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Error getting block number")
 	}
 
-	startBlock := blockNumber
-	log.Debug().Msgf("Start at %v", blockNumber)
-
-	for {
-		currentBlock, err := ethClient.BlockNumber(rootCtx)
-		if err != nil {
-			log.Error().Err(err).Msg("Error getting block number in loop")
-			continue
-		}
-
-		if currentBlock > startBlock {
-			blockNumber = currentBlock
-			break
-		}
-		time.Sleep(100 * time.Millisecond)
+	// The pool options, the decisions, the sent transactions and their realized results are written to the ledger
+	ledger, err := accounting.OpenSqliteLedger(accounting.DefaultLedgerPath)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error opening ledger")
 	}
+	defer ledger.Close()
 
 	log.Info().Uint64("block number", blockNumber).Str("wallet address", senderAddress).Str("chain", string(chain)).Msgf("Running on %s on %s %s", string(protocol), string(chain), string(poolID))
-	tarot.Run(rootCtx, ethClient, ethClientWriter, &poolOpts, walletPrivateKeyCiph)
+	// Every reinvest on the lender is recorded to follow who wins it and how they bid
+	trackerOpts := competition.TrackerOpts{Chain: chain, Pool: poolID, Lender: poolOpts.ContractLender, Labels: competition.Labels(protocolconfig.KnownBots, cfg.Senders(chain)...)}
+	go competition.NewTracker(trackerOpts, ledger).Run(rootCtx, ethClient)
+
+	harvester, err := harvesterRegistry.New(ethClient, protocolOpts)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error building harvester")
+	}
+	priceCache, err := harvest.NewPriceCache()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error building price cache")
+	}
+	resources := harvest.Resources{EthClient: ethClient, EthClientWriter: ethClientWriter, PriceCache: priceCache, NonceManager: nonceManager, Ledger: ledger, Opts: liveOpts}
+	if err := harvest.Run(rootCtx, harvester, walletPrivateKeyCiph, resources); err != nil {
+		log.Fatal().Err(err).Msg("Error running harvest")
+	}
 }
 
-// getCmdArgs parses and validates command-line arguments for chain, protocol, and pool.
+// getCmdArgs parses and validates command-line arguments for the configuration file, chain, protocol, and pool.
 //
-// It defines the expected flags (-chain, -protocol, -pool), parses the input once,
-// and validates each argument against a predefined set of allowed values.
+// It defines the expected flags (-config, -chain, -protocol, -pool), parses the input once,
+// and checks each required argument is set. The pool is looked up in the configuration file.
 //
-// If any argument is missing or invalid, the function logs a fatal error and exits the program.
+// If any argument is missing, the function logs a fatal error and exits the program.
 //
 // Returns:
-// - models.Chain: the validated blockchain chain
-// - models.Protocol: the validated DeFi protocol
-// - models.Pool: the validated pool identifier
-func getCmdArgs() (models.Chain, models.Protocol, models.Pool) {
+// - string: the path of the configuration file
+// - models.Chain: the blockchain chain
+// - models.Protocol: the DeFi protocol
+// - models.Pool: the pool identifier
+func getCmdArgs() (string, models.Chain, models.Protocol, models.Pool) {
+	var configPath string
 	var chainStr string
 	var protocolStr string
 	var poolStr string
 
+	flag.StringVar(&configPath, "config", protocolconfig.DefaultPath, "Configuration file of the chains, wallets and pools")
 	flag.StringVar(&chainStr, "chain", "", "Blockchain to connect to (required)")
 	flag.StringVar(&protocolStr, "protocol", "", "Protocol to connect to (required)")
 	flag.StringVar(&poolStr, "pool", "", "Pool to connect to (required)")
 
 	flag.Parse()
 
-	chain := validateArg[models.Chain](chainStr, "chain", validChains)
-	protocol := validateArg[models.Protocol](protocolStr, "protocol", validProtocols)
-	poolID := validateArg[models.Pool](poolStr, "pool", validPools)
+	chain := requireArg[models.Chain](chainStr, "chain")
+	protocol := requireArg[models.Protocol](protocolStr, "protocol")
+	poolID := requireArg[models.Pool](poolStr, "pool")
 
-	return chain, protocol, poolID
+	return configPath, chain, protocol, poolID
 }
 
-// validateArg parses and validates a command-line flag input against a set of allowed values.
+// requireArg parses a required command-line flag input.
 //
 // T must be a string-like type (e.g., models.Chain, models.Protocol, models.Pool).
 //
 // Parameters:
 // - valueStr: the raw string input from the command line
 // - name: the human-readable name of the flag (for error messages)
-// - validValues: a map containing the set of allowed values for this type
 //
-// If validation fails, the function logs a fatal error and exits the program.
-// Otherwise, it returns the validated and correctly typed value.
-func validateArg[T ~string](valueStr, name string, validValues map[T]bool) T {
+// If the flag is empty, the function logs a fatal error and exits the program.
+// Otherwise, it returns the upper-cased and correctly typed value.
+func requireArg[T ~string](valueStr, name string) T {
 	if valueStr == "" {
 		log.Fatal().Msgf("Error: -%s parameter is required", name)
 	}
 
-	value := T(strings.ToUpper(valueStr))
-
-	if !validValues[value] {
-		log.Fatal().Str(name, valueStr).Msgf("Error: Invalid %s", name)
-	}
-
-	return value
+	return T(strings.ToUpper(valueStr))
 }
 
-// getPoolOpts retrieves the configuration options for a specific pool based on the given chain, protocol, and pool ID.
+// loadConfig reads the configuration file, and points the RPC clients of the chains of the selected pools to their
+// endpoints.
 //
 // Parameters:
-// - chain: the blockchain network (e.g., Base, Optimism)
-// - protocol: the DeFi protocol (e.g., Tarot, Impermax)
-// - poolID: the pool identifier
+// - path: the path of the configuration file
+// - selection: the pools to run or read, whose secrets are required
 //
 // Returns:
-// - models.TarotOpts: the configuration options for the selected pool
-// - error: non-nil if the pool was not found
-func getPoolOpts(chain models.Chain, protocol models.Protocol, poolID models.Pool) (models.TarotOpts, error) {
-	protocols, ok := poolRegistry[string(chain)]
-	if !ok {
-		return models.TarotOpts{}, fmt.Errorf("no protocols found for chain=%s", chain)
-	}
-
-	pools, ok := protocols[string(protocol)]
-	if !ok {
-		return models.TarotOpts{}, fmt.Errorf("no pools found for chain=%s protocol=%s", chain, protocol)
+// - *protocolconfig.Config: the selected pools, with their chains and wallets
+// - error: non-nil if the file cannot be read, is invalid or a secret of the selected pools is not set
+func loadConfig(path string, selection protocolconfig.Selection) (*protocolconfig.Config, error) {
+	cfg, err := protocolconfig.Load(path, selection)
+	if err != nil {
+		return nil, err
 	}
 
-	pool, ok := pools[string(poolID)]
-	if !ok {
-		return models.TarotOpts{}, fmt.Errorf("pool not found for chain=%s protocol=%s poolID=%s", chain, protocol, poolID)
+	for chain, chainCfg := range cfg.Chains {
+		config.ChainToRpcEndpointsRead[chain] = chainCfg.RpcRead
+		config.ChainToRpcEndpointsWrite[chain] = chainCfg.RpcWrite
 	}
+	return cfg, nil
+}
 
-	return pool, nil
+// watchConfig reloads the tunables of the running pools on SIGHUP and whenever the configuration file changes, until
+// the context is canceled.
+//
+// Parameters:
+// - ctx: the context stopping the watch
+// - reloader: the reloader of the configuration file
+func watchConfig(ctx context.Context, reloader *protocolconfig.Reloader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hup)
+		reloader.Watch(ctx, hup, protocolconfig.ReloadPollInterval)
+	}()
 }
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"defibotgo/internal/accounting"
+	"defibotgo/internal/models"
+	protocolconfig "defibotgo/internal/protocols/config"
+	"defibotgo/internal/report"
+	"defibotgo/internal/services"
+	"defibotgo/internal/web3"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// reportCommand is the subcommand exporting the harvest history
+const reportCommand = "report"
+
+// reportScanBlocks is the default number of blocks scanned on chain, about a week of 2 seconds blocks
+const reportScanBlocks = uint64(302400)
+
+// runReport exports the harvests summed by pool, day and wallet.
+//
+// The harvests are read from the ledger. When there is no ledger, they are rebuilt from the Reinvest events
+// of the wallets of the pools of the chain in the configuration file, within the scanned block range.
+//
+// Parameters:
+// - ctx: the context of the on-chain scan
+// - args: the arguments following the subcommand
+//
+// Returns:
+// - error: non-nil if the arguments are invalid or the harvests cannot be read or exported
+func runReport(ctx context.Context, args []string) error {
+	flags := flagSet(reportCommand)
+	formatStr := flags.String("format", "csv", "Export format: csv or json")
+	outPath := flags.String("out", "", "File to write the report to (default stdout)")
+	ledgerPath := flags.String("ledger", accounting.DefaultLedgerPath, "Ledger database to read the harvests from")
+	chainStr := flags.String("chain", "", "Chain to report on, required to scan the chain when there is no ledger")
+	configPath := flags.String("config", protocolconfig.DefaultPath, "Configuration file of the pools scanned when there is no ledger")
+	days := flags.Int("days", 0, "Only report the last days (default all)")
+	fromBlock := flags.Uint64("from-block", 0, "First block scanned on chain (default -blocks before -to-block)")
+	toBlock := flags.Uint64("to-block", 0, "Last block scanned on chain (default latest)")
+	blocks := flags.Uint64("blocks", reportScanBlocks, "Number of blocks scanned on chain when -from-block is not set")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	format := report.Format(strings.ToUpper(*formatStr))
+	if format != report.FormatCsv && format != report.FormatJson {
+		return fmt.Errorf("invalid format %q", *formatStr)
+	}
+
+	chain := models.Chain(strings.ToUpper(*chainStr))
+	if _, ok := web3.ChainIDs[chain]; chain != "" && !ok {
+		return fmt.Errorf("invalid chain %q", *chainStr)
+	}
+
+	var harvests []accounting.Harvest
+	if _, err := os.Stat(*ledgerPath); err == nil {
+		ledger, err := accounting.OpenSqliteLedger(*ledgerPath)
+		if err != nil {
+			return err
+		}
+		harvests, err = ledger.Harvests()
+		_ = ledger.Close()
+		if err != nil {
+			return err
+		}
+	} else if errors.Is(err, os.ErrNotExist) {
+		if chain == "" {
+			return fmt.Errorf("no ledger at %s, -chain is required to scan the chain", *ledgerPath)
+		}
+		cfg, err := loadConfig(*configPath, protocolconfig.ChainSelection(chain, false))
+		if err != nil {
+			return err
+		}
+		log.Info().Str("ledger", *ledgerPath).Str("chain", string(chain)).Msg("No ledger, scanning the reinvests on chain")
+		harvests, err = scanReinvests(ctx, cfg, chain, *fromBlock, *toBlock, *blocks)
+		if err != nil {
+			return err
+		}
+	} else {
+		return fmt.Errorf("failed to open ledger: %w", err)
+	}
+
+	var since time.Time
+	if *days > 0 {
+		since = time.Now().UTC().AddDate(0, 0, -*days)
+	}
+	rows := report.Aggregate(report.Filter(harvests, chain, since))
+
+	var out io.Writer = os.Stdout
+	if *outPath != "" {
+		file, err := os.Create(*outPath)
+		if err != nil {
+			return fmt.Errorf("failed to create report: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	return report.Write(out, format, rows)
+}
+
+// scanReinvests rebuilds the harvests of the wallets of every pool of the chain in the configuration from the chain.
+//
+// The bounties are converted to ETH with the current reward pair value, or left at zero when it is unavailable.
+func scanReinvests(ctx context.Context, cfg *protocolconfig.Config, chain models.Chain, fromBlock uint64, toBlock uint64, blocks uint64) ([]accounting.Harvest, error) {
+	ethClient, err := web3.BuildWeb3Client(chain, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build eth client: %w", err)
+	}
+	ethClient.Start(ctx)
+	defer ethClient.Close()
+
+	if toBlock == 0 {
+		toBlock, err = ethClient.BlockNumber(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get block number: %w", err)
+		}
+	}
+	if fromBlock == 0 && toBlock > blocks {
+		fromBlock = toBlock - blocks
+	}
+
+	rewardPair, err := services.GetPoolPrice(chain)
+	if err != nil {
+		log.Warn().Err(err).Str("chain", string(chain)).Msg("Failed to get the reward pair value, the ETH amounts are zero")
+	}
+
+	var harvests []accounting.Harvest
+	for _, protocolOpts := range cfg.ChainPools(chain) {
+		poolOpts := protocolOpts.Opts()
+		scan := report.ReinvestScan{Chain: chain, Pool: poolOpts.Pool, Lender: poolOpts.ContractLender, Wallet: poolOpts.Sender, FromBlock: fromBlock, ToBlock: toBlock}
+		poolHarvests, err := report.ScanReinvests(ctx, ethClient, scan, rewardPair)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", poolOpts.Pool, err)
+		}
+		log.Info().Str("chain", string(chain)).Str("pool", string(poolOpts.Pool)).Int("reinvests", len(poolHarvests)).Uint64("from", fromBlock).Uint64("to", toBlock).Msg("Scanned reinvests")
+		harvests = append(harvests, poolHarvests...)
+	}
+
+	return harvests, nil
+}
+
+// flagSet returns the flag set of a subcommand, returning the parse errors instead of exiting.
+func flagSet(command string) *flag.FlagSet {
+	return flag.NewFlagSet(command, flag.ContinueOnError)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"defibotgo/internal/accounting"
+	"defibotgo/internal/competition"
+	"defibotgo/internal/models"
+	protocolconfig "defibotgo/internal/protocols/config"
+	"defibotgo/internal/protocols/harvest"
+	"defibotgo/internal/supervisor"
+	"defibotgo/internal/web3"
+	"fmt"
+	"github.com/dgraph-io/ristretto"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rs/zerolog/log"
+	"strings"
+)
+
+// superviseCommand is the subcommand running every configured pool in one process
+const superviseCommand = "supervise"
+
+// chainResources are the clients and the price cache shared by the pools of a chain
+type chainResources struct {
+	ethClient       *web3.RpcPool
+	ethClientWriter *web3.RpcPool
+	priceCache      *ristretto.Cache
+}
+
+// runSupervise runs a harvest worker and a competition tracker per pool of the configuration file, restarting the
+// failed ones, until the context is canceled.
+//
+// Parameters:
+// - ctx: the context stopping the workers, canceled on SIGINT or SIGTERM
+// - args: the arguments following the subcommand
+//
+// Returns:
+// - error: non-nil if the arguments are invalid, no pool can run or the workers did not stop in time
+func runSupervise(ctx context.Context, args []string) error {
+	flags := flagSet(superviseCommand)
+	configPath := flags.String("config", protocolconfig.DefaultPath, "Configuration file of the chains, wallets and pools")
+	chainStr := flags.String("chain", "", "Only run the pools of this chain (default every chain)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	chainFilter := models.Chain(strings.ToUpper(*chainStr))
+	if _, ok := web3.ChainIDs[chainFilter]; chainFilter != "" && !ok {
+		return fmt.Errorf("invalid chain %q", *chainStr)
+	}
+	// Only the secrets of the supervised pools, of their chains and of their wallets are required
+	selection := protocolconfig.ChainSelection(chainFilter, true)
+	cfg, err := loadConfig(*configPath, selection)
+	if err != nil {
+		return err
+	}
+
+	// The pool options, the decisions, the sent transactions and their realized results are written to the ledger
+	ledger, err := accounting.OpenSqliteLedger(accounting.DefaultLedgerPath)
+	if err != nil {
+		return fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer ledger.Close()
+
+	resources := map[models.Chain]*chainResources{}
+	defer func() {
+		for _, chainRes := range resources {
+			chainRes.ethClient.Close()
+			chainRes.ethClientWriter.Close()
+		}
+	}()
+	keys := map[common.Address]*ecdsa.PrivateKey{}
+
+	// The tunables of the pools follow the config file, reloaded on SIGHUP or when it changes
+	reloader := protocolconfig.NewReloader(*configPath, selection, cfg)
+	watchConfig(ctx, reloader)
+
+	sup := supervisor.New(supervisor.DefaultOpts)
+	workers := 0
+	for _, protocolOpts := range cfg.Pools {
+		poolOpts := protocolOpts.Opts()
+		name := fmt.Sprintf("%s/%s/%s", poolOpts.Chain, poolOpts.Protocol, poolOpts.Pool)
+
+		walletPrivateKey, err := walletKey(cfg, keys, poolOpts.Sender)
+		if err != nil {
+			log.Warn().Err(err).Str("chain", string(poolOpts.Chain)).Str("pool", name).Msg("Skipping pool")
+			continue
+		}
+
+		// The clients of a chain are built once, for its first pool
+		chainRes, ok := resources[poolOpts.Chain]
+		if !ok {
+			if chainRes, err = buildChainResources(ctx, poolOpts.Chain); err != nil {
+				return err
+			}
+			resources[poolOpts.Chain] = chainRes
+		}
+
+		sup.Add(poolWorker(name, reloader.Opts(protocolOpts), walletPrivateKey, chainRes, ledger))
+		sup.Add(trackerWorker(name, poolOpts, cfg.Senders(poolOpts.Chain), chainRes, ledger))
+		workers++
+	}
+	if workers == 0 {
+		return fmt.Errorf("no pool to run")
+	}
+
+	return sup.Run(ctx)
+}
+
+// poolWorker returns the worker harvesting a pool.
+//
+// Parameters:
+// - name: the name of the worker
+// - liveOpts: the options of the pool, whose tunables are reloaded
+// - walletPrivateKey: the key of the sender of the pool
+// - chainRes: the clients and the price cache of the chain of the pool
+// - ledger: the ledger shared by every pool
+//
+// Returns:
+// - supervisor.Worker: the worker, building its harvester and syncing its nonce on every start
+func poolWorker(name string, liveOpts *models.LiveOpts, walletPrivateKey *ecdsa.PrivateKey, chainRes *chainResources, ledger accounting.Ledger) supervisor.Worker {
+	return supervisor.Worker{
+		Name: name,
+		Run: func(ctx context.Context) error {
+			// A restarted worker keeps the reloaded tunables
+			protocolOpts := liveOpts.Load()
+			poolOpts := protocolOpts.Opts()
+			harvester, err := harvesterRegistry.New(chainRes.ethClient, protocolOpts)
+			if err != nil {
+				return fmt.Errorf("failed to build harvester: %w", err)
+			}
+
+			// The nonces of a wallet are shared by every pool it sends transactions for
+			nonceManager := nonceRegistry.Get(poolOpts.Chain, chainRes.ethClientWriter, poolOpts.Sender)
+			if err := nonceManager.Sync(ctx); err != nil {
+				return fmt.Errorf("failed to sync nonce: %w", err)
+			}
+
+			log.Info().Str("chain", string(poolOpts.Chain)).Str("wallet address", poolOpts.Sender.Hex()).Msgf("Running on %s on %s %s", poolOpts.Protocol, poolOpts.Chain, poolOpts.Pool)
+			return harvest.Run(ctx, harvester, walletPrivateKey, harvest.Resources{
+				EthClient:       chainRes.ethClient,
+				EthClientWriter: chainRes.ethClientWriter,
+				PriceCache:      chainRes.priceCache,
+				NonceManager:    nonceManager,
+				Ledger:          ledger,
+				Opts:            liveOpts,
+			})
+		},
+	}
+}
+
+// trackerWorker returns the worker recording every reinvest on the lender of a pool, labeling the senders of the
+// bot as ours.
+func trackerWorker(name string, poolOpts *models.PoolOpts, ours []common.Address, chainRes *chainResources, ledger accounting.Ledger) supervisor.Worker {
+	trackerOpts := competition.TrackerOpts{Chain: poolOpts.Chain, Pool: poolOpts.Pool, Lender: poolOpts.ContractLender, Labels: competition.Labels(protocolconfig.KnownBots, ours...)}
+	return supervisor.Worker{
+		Name: name + "/tracker",
+		Run: func(ctx context.Context) error {
+			competition.NewTracker(trackerOpts, ledger).Run(ctx, chainRes.ethClient)
+			return nil
+		},
+	}
+}
+
+// buildChainResources builds and starts the reader and writer clients of a chain, and its price cache.
+func buildChainResources(ctx context.Context, chain models.Chain) (*chainResources, error) {
+	ethClient, err := web3.BuildWeb3Client(chain, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build %s eth client: %w", chain, err)
+	}
+	ethClientWriter, err := web3.BuildWeb3Client(chain, false)
+	if err != nil {
+		ethClient.Close()
+		return nil, fmt.Errorf("failed to build %s eth client writer: %w", chain, err)
+	}
+	priceCache, err := harvest.NewPriceCache()
+	if err != nil {
+		ethClient.Close()
+		ethClientWriter.Close()
+		return nil, fmt.Errorf("failed to build %s price cache: %w", chain, err)
+	}
+	ethClient.Start(ctx)
+	ethClientWriter.Start(ctx)
+
+	return &chainResources{ethClient: ethClient, ethClientWriter: ethClientWriter, priceCache: priceCache}, nil
+}
+
+// walletKey returns the private key of a wallet of the configuration file, parsed once per wallet.
+//
+// Parameters:
+// - cfg: the configuration file
+// - keys: the keys already parsed, by address
+// - sender: the sender of a pool
+//
+// Returns:
+// - *ecdsa.PrivateKey: the key of the sender
+// - error: non-nil if the key of the sender is invalid
+func walletKey(cfg *protocolconfig.Config, keys map[common.Address]*ecdsa.PrivateKey, sender common.Address) (*ecdsa.PrivateKey, error) {
+	if key, ok := keys[sender]; ok {
+		return key, nil
+	}
+	walletPrivateKey, err := cfg.PrivateKey(sender)
+	if err != nil {
+		return nil, err
+	}
+	key, err := crypto.HexToECDSA(walletPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key of wallet %s: %w", sender.Hex(), err)
+	}
+	keys[sender] = key
+	return key, nil
+}
@@ -0,0 +1,175 @@
+package accounting
+
+import (
+	"database/sql"
+	"defibotgo/internal/accounting"
+	"defibotgo/internal/competition"
+	"defibotgo/internal/models"
+	"defibotgo/internal/web3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+var wallet = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+var rewardToken = common.HexToAddress("0x940181a94A35A4569E4529A3CDfB74e38FD98631")
+
+// newReceipt returns a receipt transferring reward tokens to the wallet.
+func newReceipt(status uint64, reward int64, gasUsed uint64, gasPrice int64, l1Fee int64) *web3.OpReceipt {
+	receipt := &web3.OpReceipt{
+		TxHash:            common.HexToHash("0x01"),
+		BlockNumber:       (*hexutil.Big)(big.NewInt(100)),
+		Status:            hexutil.Uint64(status),
+		GasUsed:           hexutil.Uint64(gasUsed),
+		EffectiveGasPrice: (*hexutil.Big)(big.NewInt(gasPrice)),
+		L1Fee:             (*hexutil.Big)(big.NewInt(l1Fee)),
+	}
+	if reward > 0 {
+		receipt.Logs = []*types.Log{{
+			Address: rewardToken,
+			Topics:  []common.Hash{web3.TransferTopic, {}, common.BytesToHash(wallet.Bytes())},
+			Data:    common.BigToHash(big.NewInt(reward)).Bytes(),
+		}}
+	}
+	return receipt
+}
+
+func TestRealize(t *testing.T) {
+	// The reward token is worth half an ETH
+	rewardPair := big.NewInt(5e17)
+	harvest := accounting.Realize(newReceipt(1, 1000, 100, 2, 50), models.Base, models.UsdcAero, wallet, rewardToken, rewardPair)
+
+	if !harvest.Success || harvest.BlockNumber != 100 {
+		t.Fatalf("unexpected harvest: %+v", harvest)
+	}
+	if harvest.RewardToken.Cmp(big.NewInt(1000)) != 0 || harvest.RewardEth.Cmp(big.NewInt(500)) != 0 {
+		t.Fatalf("unexpected reward: expected %v token and %v eth, got %v and %v", 1000, 500, harvest.RewardToken, harvest.RewardEth)
+	}
+	if harvest.Fee().Cmp(big.NewInt(250)) != 0 {
+		t.Fatalf("unexpected fee: expected %v, got %v", 250, harvest.Fee())
+	}
+	if harvest.ProfitEth.Cmp(big.NewInt(250)) != 0 {
+		t.Fatalf("unexpected profit: expected %v, got %v", 250, harvest.ProfitEth)
+	}
+}
+
+func TestSqliteLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "defibot.db")
+	rewardPair := big.NewInt(1e18)
+	position := accounting.Position{Chain: models.Base, Pool: models.UsdcAero, Wallet: wallet}
+
+	ledger, err := accounting.OpenSqliteLedger(path)
+	if err != nil {
+		t.Fatalf("failed to open ledger: %v", err)
+	}
+
+	ledger.RecordPool(&models.TarotOpts{PoolOpts: models.PoolOpts{Chain: models.Base, Pool: models.UsdcAero, Sender: wallet}, ReinvestBounty: big.NewInt(1)})
+	ledger.RecordIteration(accounting.Iteration{Chain: models.Base, Pool: models.UsdcAero, Wallet: wallet, BlockNumber: 99, Earned: big.NewInt(1), RewardEth: big.NewInt(1), L2Fee: big.NewInt(2), Diff: -50, Decision: accounting.DecisionUnworthy})
+	ledger.RecordIteration(accounting.Iteration{Chain: models.Base, Pool: models.UsdcAero, Wallet: wallet, BlockNumber: 100, Earned: big.NewInt(1), RewardEth: big.NewInt(4), L2Fee: big.NewInt(1), L1Fee: big.NewInt(1), Diff: 100, Decision: accounting.DecisionSent})
+	ledger.RecordReinvest(competition.Reinvest{Chain: models.Base, Pool: models.UsdcAero, Lender: wallet, Sender: wallet, TxHash: common.HexToHash("0x01"), BlockNumber: 101, Reward: big.NewInt(100), Bounty: big.NewInt(2), GasUsed: 21000, Tip: big.NewInt(1), L2Fee: big.NewInt(1), L1Fee: big.NewInt(1)})
+	// The same reinvest decoded from its event alone keeps the receipt fields
+	ledger.RecordReinvest(competition.Reinvest{Chain: models.Base, Pool: models.UsdcAero, Lender: wallet, Sender: wallet, TxHash: common.HexToHash("0x01"), BlockNumber: 101, Reward: big.NewInt(100), Bounty: big.NewInt(2), Tip: big.NewInt(0), L2Fee: big.NewInt(0), L1Fee: big.NewInt(0)})
+	ledger.RecordTransaction(accounting.SentTransaction{Chain: models.Base, Pool: models.UsdcAero, Wallet: wallet, Hash: common.HexToHash("0x01"), Nonce: 1, TargetBlock: 101, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2), GasLimit: 21000})
+
+	harvests := []accounting.Harvest{
+		accounting.Realize(newReceipt(1, 1000, 100, 2, 50), models.Base, models.UsdcAero, wallet, rewardToken, rewardPair),
+		// A failed reinvest only costs its fees
+		accounting.Realize(newReceipt(0, 0, 100, 1, 20), models.Base, models.UsdcAero, wallet, rewardToken, rewardPair),
+		// Another pool of the wallet
+		accounting.Realize(newReceipt(1, 5000, 100, 1, 0), models.Base, models.WethTarot, wallet, rewardToken, rewardPair),
+	}
+	for i, harvest := range harvests {
+		harvest.TxHash = common.BigToHash(big.NewInt(int64(i + 1)))
+		ledger.Record(harvest)
+	}
+
+	// The totals include the harvests still queued
+	if totals := ledger.Totals(position); totals.Harvests != 2 {
+		t.Fatalf("unexpected harvests before close: expected 2, got %v", totals.Harvests)
+	}
+	if err := ledger.Close(); err != nil {
+		t.Fatalf("failed to close ledger: %v", err)
+	}
+
+	// The records are flushed on close
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	for table, expected := range map[string]int{"pools": 1, "iterations": 2, "transactions": 1, "harvests": 3, "reinvests": 1} {
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count); err != nil {
+			t.Fatalf("failed to count %s: %v", table, err)
+		}
+		if count != expected {
+			t.Fatalf("unexpected %s rows: expected %v, got %v", table, expected, count)
+		}
+	}
+	var gasUsed uint64
+	var tip string
+	if err := db.QueryRow("SELECT gas_used, tip FROM reinvests").Scan(&gasUsed, &tip); err != nil {
+		t.Fatalf("failed to read reinvest: %v", err)
+	}
+	if gasUsed != 21000 || tip != "1" {
+		t.Fatalf("unexpected reinvest receipt fields: expected %v and %v, got %v and %v", 21000, "1", gasUsed, tip)
+	}
+	_ = db.Close()
+
+	// The totals are restored from the database
+	ledger, err = accounting.OpenSqliteLedger(path)
+	if err != nil {
+		t.Fatalf("failed to reopen ledger: %v", err)
+	}
+	defer ledger.Close()
+
+	totals := ledger.Totals(position)
+	if totals.Harvests != 2 || totals.Failed != 1 {
+		t.Fatalf("unexpected harvests: expected 2 with 1 failed, got %v with %v failed", totals.Harvests, totals.Failed)
+	}
+	if totals.RewardEth.Cmp(big.NewInt(1000)) != 0 || totals.Fee.Cmp(big.NewInt(370)) != 0 || totals.ProfitEth.Cmp(big.NewInt(630)) != 0 {
+		t.Fatalf("unexpected totals: %+v", totals)
+	}
+
+	if other := ledger.Totals(accounting.Position{Chain: models.Base, Pool: models.AeroTarot, Wallet: wallet}); other.Harvests != 0 || other.ProfitEth.Sign() != 0 {
+		t.Fatalf("unexpected totals of an unknown position: %+v", other)
+	}
+}
+
+func TestSqliteLedgerRecordTwice(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "defibot.db")
+	position := accounting.Position{Chain: models.Base, Pool: models.UsdcAero, Wallet: wallet}
+
+	ledger, err := accounting.OpenSqliteLedger(path)
+	if err != nil {
+		t.Fatalf("failed to open ledger: %v", err)
+	}
+
+	// The second record of the transaction replaces the first one
+	ledger.Record(accounting.Realize(newReceipt(1, 1000, 100, 2, 50), models.Base, models.UsdcAero, wallet, rewardToken, big.NewInt(1e18)))
+	ledger.Record(accounting.Realize(newReceipt(1, 2000, 100, 2, 50), models.Base, models.UsdcAero, wallet, rewardToken, big.NewInt(1e18)))
+
+	recorded := ledger.Totals(position)
+	if recorded.Harvests != 1 || recorded.RewardEth.Cmp(big.NewInt(2000)) != 0 || recorded.Fee.Cmp(big.NewInt(250)) != 0 {
+		t.Fatalf("unexpected totals: %+v", recorded)
+	}
+	if err := ledger.Close(); err != nil {
+		t.Fatalf("failed to close ledger: %v", err)
+	}
+
+	// The totals kept in memory are the ones restored from the database
+	ledger, err = accounting.OpenSqliteLedger(path)
+	if err != nil {
+		t.Fatalf("failed to reopen ledger: %v", err)
+	}
+	defer ledger.Close()
+
+	restored := ledger.Totals(position)
+	if restored.Harvests != recorded.Harvests || restored.RewardEth.Cmp(recorded.RewardEth) != 0 || restored.ProfitEth.Cmp(recorded.ProfitEth) != 0 {
+		t.Fatalf("restored totals %+v differ from the recorded ones %+v", restored, recorded)
+	}
+}
@@ -0,0 +1,142 @@
+package competition
+
+import (
+	"context"
+	"defibotgo/internal/competition"
+	"defibotgo/internal/config"
+	"defibotgo/internal/contract_abi"
+	"defibotgo/internal/models"
+	"defibotgo/internal/web3"
+	"defibotgo/internal/web3/web3test"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"testing"
+	"time"
+)
+
+var ourWallet = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+var botWallet = common.HexToAddress("0x00000000000000000000000000000000000000bb")
+var unknownWallet = common.HexToAddress("0x00000000000000000000000000000000000000cc")
+var lender = common.HexToAddress("0x042c37762d1d126bc61eac2f5ceb7a96318f5db9")
+
+var testPoolOpts = web3.PoolOpts{
+	MaxHeadLag:             3,
+	HealthCheckInterval:    time.Second,
+	MaxConsecutiveFailures: 2,
+	FailureCooldown:        time.Minute,
+}
+
+var labels = competition.Labels(map[common.Address]string{botWallet: "fast bot"}, ourWallet)
+
+func newReinvest(sender common.Address, block uint64, tip int64, gasUsed uint64) competition.Reinvest {
+	return competition.Reinvest{Sender: sender, BlockNumber: block, Tip: big.NewInt(tip), GasUsed: gasUsed, Bounty: big.NewInt(10)}
+}
+
+func TestSummarize(t *testing.T) {
+	summary := competition.Summarize(models.Base, models.UsdcAero, []competition.Reinvest{
+		newReinvest(botWallet, 10, 300, 400000),
+		newReinvest(botWallet, 20, 200, 410000),
+		newReinvest(botWallet, 30, 400, 420000),
+		newReinvest(ourWallet, 40, 100, 500000),
+		newReinvest(unknownWallet, 50, 50, 450000),
+	}, labels)
+
+	if summary.Reinvests != 5 || summary.FromBlock != 10 || summary.ToBlock != 50 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if summary.MedianTip.Cmp(big.NewInt(200)) != 0 {
+		t.Fatalf("unexpected median tip: expected %v, got %v", 200, summary.MedianTip)
+	}
+
+	// The most active sender first
+	bot := summary.Senders[0]
+	if bot.Sender != botWallet || bot.Label != "fast bot" || bot.Wins != 3 || bot.WinRate != 60 {
+		t.Fatalf("unexpected bot stats: %+v", bot)
+	}
+	if bot.MedianTip.Cmp(big.NewInt(300)) != 0 || bot.TipPremium != 50 || bot.MedianGasUsed != 410000 || bot.Bounty.Cmp(big.NewInt(30)) != 0 {
+		t.Fatalf("unexpected bot bids: %+v", bot)
+	}
+
+	ours := summary.Ours()
+	if ours.Wins != 1 || ours.WinRate != 20 || ours.LastBlock != 40 {
+		t.Fatalf("unexpected stats of our wallets: %+v", ours)
+	}
+
+	for _, sender := range summary.Senders {
+		if sender.Sender == unknownWallet && (sender.Label != "" || sender.TipPremium != -75) {
+			t.Fatalf("unexpected unknown sender stats: %+v", sender)
+		}
+	}
+
+	if empty := competition.Summarize(models.Base, models.UsdcAero, nil, labels); empty.Reinvests != 0 || len(empty.Senders) != 0 {
+		t.Fatalf("unexpected empty summary: %+v", empty)
+	}
+}
+
+type reinvestRecorder struct {
+	reinvests []competition.Reinvest
+}
+
+func (r *reinvestRecorder) RecordReinvest(reinvest competition.Reinvest) {
+	r.reinvests = append(r.reinvests, reinvest)
+}
+
+func TestTrackerPoll(t *testing.T) {
+	lenderAbi, err := web3.LoadAbi(contract_abi.CONTRACT_ABI_LENDER)
+	if err != nil {
+		t.Fatalf("failed to load abi: %v", err)
+	}
+	reinvest := lenderAbi.Events["Reinvest"]
+
+	ethService := &web3test.EthService{Head: 1000, ChainID: 8453, Logs: []types.Log{}, Headers: map[uint64]*types.Header{}, Receipts: map[common.Hash]json.RawMessage{}}
+	addReinvest := func(block uint64, sender common.Address, tip int64) {
+		data, err := reinvest.Inputs.NonIndexed().Pack(big.NewInt(1000), big.NewInt(20))
+		if err != nil {
+			t.Fatalf("failed to pack reinvest: %v", err)
+		}
+		hash := common.BigToHash(new(big.Int).SetUint64(block))
+		ethService.Logs = append(ethService.Logs, types.Log{Address: lender, Topics: []common.Hash{reinvest.ID, common.BytesToHash(sender.Bytes())}, Data: data, BlockNumber: block, TxHash: hash})
+		ethService.Headers[block] = &types.Header{Number: new(big.Int).SetUint64(block), Time: block * 2, Difficulty: big.NewInt(0), BaseFee: big.NewInt(1000)}
+		ethService.Receipts[hash] = json.RawMessage(`{"transactionHash":"` + hash.Hex() + `","status":"0x1","gasUsed":"0x64","effectiveGasPrice":"` + hexutil.EncodeBig(big.NewInt(1000+tip)) + `","logs":[]}`)
+	}
+	// Out of the window of the first poll
+	addReinvest(800, botWallet, 500)
+	addReinvest(950, botWallet, 300)
+	addReinvest(990, ourWallet, 100)
+
+	server := web3test.NewRPCServer(map[string]interface{}{"eth": ethService})
+	defer server.Close()
+
+	pool, err := web3.NewRpcPool(context.Background(), config.ParseRpcEndpoints(server.URL), testPoolOpts)
+	if err != nil {
+		t.Fatalf("failed to build pool: %v", err)
+	}
+	defer pool.Close()
+
+	recorder := &reinvestRecorder{}
+	tracker := competition.NewTracker(competition.TrackerOpts{Chain: models.Base, Pool: models.UsdcAero, Lender: lender, Labels: labels, Window: 100}, recorder)
+
+	found, err := tracker.Poll(context.Background(), pool)
+	if err != nil || found != 2 {
+		t.Fatalf("unexpected first poll: found %v, err %v", found, err)
+	}
+	if recorder.reinvests[0].Tip.Cmp(big.NewInt(300)) != 0 || recorder.reinvests[0].Sender != botWallet {
+		t.Fatalf("unexpected reinvest recorded: %+v", recorder.reinvests[0])
+	}
+
+	// Only the new blocks are scanned, and the window slides
+	addReinvest(1040, ourWallet, 200)
+	ethService.SetHead(1060)
+	found, err = tracker.Poll(context.Background(), pool)
+	if err != nil || found != 1 || len(recorder.reinvests) != 3 {
+		t.Fatalf("unexpected second poll: found %v, err %v, recorded %v", found, err, len(recorder.reinvests))
+	}
+
+	summary := tracker.Summary()
+	if summary.Reinvests != 2 || summary.Ours().Wins != 2 || summary.Ours().WinRate != 100 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
@@ -0,0 +1,353 @@
+package protocols
+
+import (
+	"defibotgo/internal/config"
+	"defibotgo/internal/models"
+	protocolconfig "defibotgo/internal/protocols/config"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var exampleWallets = map[string]string{
+	"TAROT_ONE":    "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318",
+	"TAROT_TWO":    "0000000000000000000000000000000000000000000000000000000000000a02",
+	"TAROT_THREE":  "0000000000000000000000000000000000000000000000000000000000000a03",
+	"IMPERMAX_ONE": "0000000000000000000000000000000000000000000000000000000000000b01",
+}
+
+func setExampleEnv(t *testing.T) {
+	for name, privateKey := range exampleWallets {
+		t.Setenv("ACCOUNT_PRIVATE_KEY_"+name, privateKey)
+	}
+	t.Setenv("RPC_NODE_BASE_READ", "https://read-a.example,https://read-b.example|Authorization=Bearer abc")
+	t.Setenv("RPC_NODE_BASE_WRITE", "https://write.example")
+}
+
+// unsetEnv unsets an environment variable for the duration of the test.
+func unsetEnv(t *testing.T, name string) {
+	// Setenv restores the variable at the end of the test
+	t.Setenv(name, "")
+	if err := os.Unsetenv(name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// walletAddress returns the address of a wallet of the example configuration.
+func walletAddress(t *testing.T, name string) common.Address {
+	key, err := crypto.HexToECDSA(exampleWallets[name])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crypto.PubkeyToAddress(key.PublicKey)
+}
+
+// everyPool selects every pool of the file, with the private keys of their wallets
+var everyPool = protocolconfig.Selection{Keys: true}
+
+func TestLoadExampleConfig(t *testing.T) {
+	setExampleEnv(t)
+
+	cfg, err := protocolconfig.Load("../../config.example.yaml", everyPool)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if got := len(cfg.Pools); got != 4 {
+		t.Fatalf("got %d pools, want 4", got)
+	}
+	if got := cfg.Chains[models.Base].RpcRead; len(got) != 2 || got[1].Headers["Authorization"] != "Bearer abc" {
+		t.Errorf("read endpoints = %+v", got)
+	}
+
+	protocolOpts, err := cfg.Pool(models.Base, models.Tarot, models.UsdcAero)
+	if err != nil {
+		t.Fatalf("Pool: %v", err)
+	}
+	tarotOpts, ok := protocolOpts.(*models.TarotOpts)
+	if !ok {
+		t.Fatalf("USDC_AERO options are %T, want *models.TarotOpts", protocolOpts)
+	}
+	if tarotOpts.RewardRate.Cmp(big.NewInt(1059238100440517689)) != 0 || tarotOpts.ReinvestBounty.Cmp(big.NewInt(20000000000000000)) != 0 {
+		t.Errorf("reward rate %s, bounty %s", tarotOpts.RewardRate, tarotOpts.ReinvestBounty)
+	}
+	if tarotOpts.Sender != walletAddress(t, "TAROT_ONE") {
+		t.Errorf("sender = %s", tarotOpts.Sender.Hex())
+	}
+	if tarotOpts.ContractGasPriceOracle != protocolconfig.BaseGasPriceOracleAddress {
+		t.Errorf("gas price oracle = %s", tarotOpts.ContractGasPriceOracle.Hex())
+	}
+	if tarotOpts.ExtraPriorityFeePercent != [2]int{2, 7} || tarotOpts.TipEstimator != "" {
+		t.Errorf("extra priority fee %v, tip estimator %s", tarotOpts.ExtraPriorityFeePercent, tarotOpts.TipEstimator)
+	}
+
+	// A pool only switches its tip estimator explicitly
+	example, err := os.ReadFile("../../config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	switched := strings.Replace(string(example), "extra_priority_fee_percent: [2, 7]", "extra_priority_fee_percent: [2, 7]\n    tip_estimator: block_receipts", 1)
+	switchedCfg, err := protocolconfig.Parse([]byte(switched), everyPool)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	switchedOpts, _ := switchedCfg.Pool(models.Base, models.Tarot, models.UsdcAero)
+	otherOpts, _ := switchedCfg.Pool(models.Base, models.Tarot, models.WethTarot)
+	if switchedOpts.Opts().TipEstimator != models.TipEstimatorBlockReceipts || otherOpts.Opts().TipEstimator != "" {
+		t.Errorf("tip estimators %q and %q", switchedOpts.Opts().TipEstimator, otherOpts.Opts().TipEstimator)
+	}
+
+	protocolOpts, err = cfg.Pool(models.Base, models.Impermax, models.FbombCbbtc)
+	if err != nil {
+		t.Fatalf("Pool: %v", err)
+	}
+	if _, ok := protocolOpts.(*models.ImpermaxOpts); !ok {
+		t.Errorf("FBOMB_CBBTC options are %T, want *models.ImpermaxOpts", protocolOpts)
+	}
+
+	if got := len(cfg.Senders(models.Base)); got != 4 {
+		t.Errorf("got %d senders, want 4", got)
+	}
+	if privateKey, err := cfg.PrivateKey(walletAddress(t, "IMPERMAX_ONE")); err != nil || privateKey != exampleWallets["IMPERMAX_ONE"] {
+		t.Errorf("PrivateKey = %q, %v", privateKey, err)
+	}
+	if _, err := cfg.Pool(models.Base, models.Tarot, models.FbombCbbtc); err == nil {
+		t.Error("Pool found a pool missing from the file")
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	_, err := protocolconfig.Parse([]byte(`
+chains:
+  BASE:
+    rpc_read: ${CONFIG_TEST_MISSING_RPC}
+    rpc_write: ${CONFIG_TEST_MISSING_RPC}
+  GNOSIS:
+    rpc_read: ${CONFIG_TEST_MISSING_RPC}
+    rpc_write: ${CONFIG_TEST_MISSING_RPC}
+wallets:
+  ONE:
+    address: "0x00000000000000000000000000000000000000a1"
+    private_key: "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+pools:
+  - chain: BASE
+    protocol: SUSHI
+    pool: USDC_AERO
+    wallet: TWO
+    lender: "0x123"
+    gauge: "0x4F09bAb2f0E15e2A078A227FE1537665F55b8360"
+    reinvest_bounty: "20000000000000000"
+    reward_rate: 0
+    block_range: 10
+    gas_used_default: 426244
+    extra_priority_fee_percent: [7, 2]
+`), everyPool)
+	if err == nil {
+		t.Fatal("Parse accepted an invalid file")
+	}
+	for _, want := range []string{
+		"chain GNOSIS is not supported",
+		`unknown protocol "SUSHI"`,
+		`wallet "TWO" is not configured`,
+		`lender: invalid address "0x123"`,
+		"priority_fee: missing",
+		"reward_rate: 0 is not positive",
+		"extra_priority_fee_percent: invalid range [7, 2]",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not report %q", err, want)
+		}
+	}
+
+	// The secrets are only read once the file is valid
+	if strings.Contains(err.Error(), "CONFIG_TEST_MISSING_RPC") {
+		t.Errorf("error %q reports the secrets of an invalid file", err)
+	}
+
+	if _, err := protocolconfig.Parse([]byte("pools:\n  - chain: BASE\n    lendr: \"0x1\"\n"), everyPool); err == nil || !strings.Contains(err.Error(), "lendr") {
+		t.Errorf("unknown field not reported: %v", err)
+	}
+}
+
+func TestLoadSelectedSecrets(t *testing.T) {
+	// No .env file sets the private keys expected unset, whether it was loaded by an earlier test or not
+	t.Setenv("APP_ENV", "production")
+	config.LookupEnv("APP_ENV")
+	for _, name := range []string{"TAROT_ONE", "TAROT_TWO", "TAROT_THREE"} {
+		unsetEnv(t, "ACCOUNT_PRIVATE_KEY_"+name)
+	}
+
+	// Only the private key of the wallet of the selected pool and the endpoints of its chain are set
+	t.Setenv("ACCOUNT_PRIVATE_KEY_IMPERMAX_ONE", exampleWallets["IMPERMAX_ONE"])
+	t.Setenv("RPC_NODE_BASE_READ", "https://read.example")
+	t.Setenv("RPC_NODE_BASE_WRITE", "https://write.example")
+
+	selection := protocolconfig.Selection{
+		Match: func(opts *models.PoolOpts) bool { return opts.Pool == models.FbombCbbtc },
+		Keys:  true,
+	}
+	cfg, err := protocolconfig.Load("../../config.example.yaml", selection)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := len(cfg.Pools); got != 1 {
+		t.Fatalf("got %d pools, want 1", got)
+	}
+	if sender := cfg.Pools[0].Opts().Sender; sender != walletAddress(t, "IMPERMAX_ONE") {
+		t.Errorf("sender = %s, want the address of the private key", sender.Hex())
+	}
+
+	// The missing secrets of the selected pools are listed together, not the ones of the other pools
+	_, err = protocolconfig.Load("../../config.example.yaml", protocolconfig.Selection{
+		Match: func(opts *models.PoolOpts) bool { return opts.Protocol == models.Tarot },
+		Keys:  true,
+	})
+	if err == nil {
+		t.Fatal("Load accepted unset private keys")
+	}
+	for _, want := range []string{
+		"ACCOUNT_PRIVATE_KEY_TAROT_ONE (wallet TAROT_ONE private_key)",
+		"ACCOUNT_PRIVATE_KEY_TAROT_TWO (wallet TAROT_TWO private_key)",
+		"ACCOUNT_PRIVATE_KEY_TAROT_THREE (wallet TAROT_THREE private_key)",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not report %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "IMPERMAX_ONE") || strings.Contains(err.Error(), "RPC_NODE") {
+		t.Errorf("error %q reports the secrets set or not selected", err)
+	}
+}
+
+func TestParseWalletAddress(t *testing.T) {
+	file := `
+chains:
+  BASE:
+    rpc_read: https://read.example
+    rpc_write: https://write.example
+wallets:
+  ONE:
+    address: "%s"
+    private_key: ${CONFIG_TEST_PRIVATE_KEY}
+pools:
+  - chain: BASE
+    protocol: TAROT
+    pool: USDC_AERO
+    wallet: ONE
+    lender: "0x042c37762d1d126bc61eac2f5ceb7a96318f5db9"
+    gauge: "0x4F09bAb2f0E15e2A078A227FE1537665F55b8360"
+    reinvest_bounty: "20000000000000000"
+    reward_rate: "1059238100440517689"
+    priority_fee: 12368
+    block_range: 10
+    gas_used_default: 426244
+`
+	address := "0x00000000000000000000000000000000000000a1"
+	readOnly := protocolconfig.Selection{Keys: false}
+
+	// Reading the chain only requires the address
+	cfg, err := protocolconfig.Parse([]byte(fmt.Sprintf(file, address)), readOnly)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if sender := cfg.Pools[0].Opts().Sender; sender != common.HexToAddress(address) {
+		t.Errorf("sender = %s, want %s", sender.Hex(), address)
+	}
+	if _, err := cfg.PrivateKey(common.HexToAddress(address)); err == nil {
+		t.Error("PrivateKey returned a key which was not loaded")
+	}
+
+	// Sending transactions requires the private key
+	if _, err := protocolconfig.Parse([]byte(fmt.Sprintf(file, address)), everyPool); err == nil || !strings.Contains(err.Error(), "CONFIG_TEST_PRIVATE_KEY (wallet ONE private_key)") {
+		t.Errorf("unset private key not reported: %v", err)
+	}
+
+	// The address must be the one of the private key
+	t.Setenv("CONFIG_TEST_PRIVATE_KEY", exampleWallets["TAROT_ONE"])
+	if _, err := protocolconfig.Parse([]byte(fmt.Sprintf(file, address)), everyPool); err == nil || !strings.Contains(err.Error(), "is not the address") {
+		t.Errorf("mismatching address not reported: %v", err)
+	}
+	if _, err := protocolconfig.Parse([]byte(fmt.Sprintf(file, walletAddress(t, "TAROT_ONE").Hex())), everyPool); err != nil {
+		t.Errorf("Parse: %v", err)
+	}
+}
+
+func TestReloaderAppliesTunables(t *testing.T) {
+	setExampleEnv(t)
+	example, err := os.ReadFile("../../config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, example, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := protocolconfig.Load(path, everyPool)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	protocolOpts, _ := cfg.Pool(models.Base, models.Tarot, models.UsdcAero)
+
+	reloader := protocolconfig.NewReloader(path, everyPool, cfg)
+	live := reloader.Opts(protocolOpts)
+	if reloader.Opts(protocolOpts) != live {
+		t.Fatal("Opts returned another holder for the same pool")
+	}
+
+	// An invalid file is not applied
+	if err := os.WriteFile(path, []byte(strings.Replace(string(example), "profitable_threshold: -3", "profitable_threshold: -1\n    unknown: 1", 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloader.Reload(); err == nil {
+		t.Fatal("Reload accepted an invalid file")
+	}
+	if live.Load() != protocolOpts {
+		t.Fatal("the options changed after an invalid reload")
+	}
+
+	// The tunables are applied, the other options are kept until a restart
+	changed := strings.Replace(string(example), "profitable_threshold: -3", "profitable_threshold: -1", 1)
+	changed = strings.Replace(changed, "extra_priority_fee_percent: [2, 7]", "extra_priority_fee_percent: [3, 9]", 1)
+	changed = strings.Replace(changed, "0x4F09bAb2f0E15e2A078A227FE1537665F55b8360", "0x00000000000000000000000000000000000000c1", 1)
+	if err := os.WriteFile(path, []byte(changed), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	reloaded, ok := live.Load().(*models.TarotOpts)
+	if !ok {
+		t.Fatalf("reloaded options are %T, want *models.TarotOpts", live.Load())
+	}
+	if reloaded.ProfitableThreshold != -1 || reloaded.ExtraPriorityFeePercent != [2]int{3, 9} {
+		t.Errorf("threshold %v, extra priority fee %v", reloaded.ProfitableThreshold, reloaded.ExtraPriorityFeePercent)
+	}
+	if reloaded.ContractGauge != protocolOpts.Opts().ContractGauge || reloaded.RewardRate != protocolOpts.(*models.TarotOpts).RewardRate {
+		t.Error("options other than the tunables were reloaded")
+	}
+	if protocolOpts.Opts().ProfitableThreshold != -3 {
+		t.Error("the options loaded at startup were modified")
+	}
+}
+
+func TestTunablesDiff(t *testing.T) {
+	before := models.Tunables{PriorityFee: big.NewInt(100), ProfitableThreshold: -3, GasUsedDefault: 400000, ExtraPriorityFeePercent: [2]int{2, 7}}
+	after := before
+	after.PriorityFee = big.NewInt(150)
+	after.ExtraPriorityFeePercent = [2]int{2, 9}
+
+	changes := before.Diff(after)
+	want := []string{"priority_fee: 100 -> 150", "extra_priority_fee_percent: [2 7] -> [2 9]"}
+	if strings.Join(changes, ";") != strings.Join(want, ";") {
+		t.Errorf("Diff = %q, want %q", changes, want)
+	}
+	if changes := before.Diff(before); len(changes) != 0 {
+		t.Errorf("Diff of the same tunables = %q", changes)
+	}
+}
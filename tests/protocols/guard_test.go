@@ -0,0 +1,155 @@
+package protocols
+
+import (
+	"context"
+	"defibotgo/internal/accounting"
+	"defibotgo/internal/competition"
+	"defibotgo/internal/models"
+	"defibotgo/internal/protocols/harvest"
+	"defibotgo/internal/protocols/tarot"
+	"defibotgo/internal/web3/web3test"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+)
+
+// reinvestLedger records the competitor reinvests, the other records are ignored
+type reinvestLedger struct {
+	mu        sync.Mutex
+	reinvests []competition.Reinvest
+}
+
+func (l *reinvestLedger) RecordPool(models.ProtocolOpts)               {}
+func (l *reinvestLedger) RecordIteration(accounting.Iteration)         {}
+func (l *reinvestLedger) RecordTransaction(accounting.SentTransaction) {}
+func (l *reinvestLedger) Record(accounting.Harvest)                    {}
+func (l *reinvestLedger) Totals(accounting.Position) accounting.Totals {
+	return accounting.Totals{}
+}
+
+func (l *reinvestLedger) RecordReinvest(reinvest competition.Reinvest) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reinvests = append(l.reinvests, reinvest)
+}
+
+func (l *reinvestLedger) recorded() []competition.Reinvest {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]competition.Reinvest(nil), l.reinvests...)
+}
+
+// polledClient signals the first head read, once the log watcher started from it
+type polledClient struct {
+	*web3test.Client
+	once   sync.Once
+	polled chan struct{}
+}
+
+func (c *polledClient) BlockNumber(ctx context.Context) (uint64, error) {
+	head, err := c.Client.BlockNumber(ctx)
+	c.once.Do(func() { close(c.polled) })
+	return head, err
+}
+
+func isCanceled(ctx context.Context) bool {
+	return ctx.Err() != nil
+}
+
+func TestGuardHarvested(t *testing.T) {
+	guard := &harvest.Guard{}
+
+	ctx, cancel := guard.Start(context.Background(), big.NewInt(100))
+	// A harvest mined in the block the iteration read was already counted in its values
+	if guard.Harvested(100) || isCanceled(ctx) {
+		t.Fatal("a harvest of the same block should not cancel the iteration")
+	}
+	if !guard.Harvested(101) || !isCanceled(ctx) {
+		t.Fatal("a harvest of a newer block should cancel the iteration")
+	}
+	cancel()
+
+	// The heads up to the harvest are outdated
+	if !guard.Stale(big.NewInt(100)) || guard.Stale(big.NewInt(101)) {
+		t.Fatal("only the heads older than the harvest should be stale")
+	}
+
+	// An iteration started on an outdated head is canceled right away
+	ctx, cancel = guard.Start(context.Background(), big.NewInt(100))
+	if !isCanceled(ctx) {
+		t.Fatal("an iteration older than the last harvest should be canceled")
+	}
+	cancel()
+
+	// Once done, an iteration is not canceled anymore
+	ctx, cancel = guard.Start(context.Background(), big.NewInt(101))
+	cancel()
+	if guard.Harvested(102) {
+		t.Fatal("a finished iteration should not be reported as canceled")
+	}
+	if !guard.Stale(big.NewInt(101)) {
+		t.Fatal("the last harvest should be the newest one")
+	}
+}
+
+func TestWatchHarvests(t *testing.T) {
+	ours := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	competitor := common.HexToAddress("0x00000000000000000000000000000000000000b1")
+
+	client := &polledClient{Client: web3test.NewClient(8453), polled: make(chan struct{})}
+	client.AddHeader(&types.Header{Number: big.NewInt(100)})
+	tarotOpts := &models.TarotOpts{PoolOpts: models.PoolOpts{Chain: models.Base, Pool: models.UsdcAero, Protocol: models.Tarot, Sender: ours, ContractLender: fakeLender, ContractGauge: fakeGauge}, ReinvestBounty: reinvestBounty}
+	harvester, err := tarot.NewHarvester(client, tarotOpts)
+	if err != nil {
+		t.Fatalf("failed to build harvester: %v", err)
+	}
+	reinvest := harvester.Abi().Events["Reinvest"]
+	addReinvest := func(block uint64, sender common.Address) {
+		data, err := reinvest.Inputs.NonIndexed().Pack(big.NewInt(1000), big.NewInt(20))
+		if err != nil {
+			t.Fatalf("failed to pack reinvest: %v", err)
+		}
+		tx := types.NewTx(&types.LegacyTx{Nonce: block})
+		client.AddTransaction(tx, &types.Log{Address: fakeLender, Topics: []common.Hash{reinvest.ID, common.BytesToHash(sender.Bytes())}, Data: data, BlockNumber: block})
+		client.AddHeader(&types.Header{Number: new(big.Int).SetUint64(block)})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	guard := &harvest.Guard{}
+	ledger := &reinvestLedger{}
+	iterCtx, iterCancel := guard.Start(ctx, big.NewInt(100))
+	defer iterCancel()
+
+	go harvest.WatchHarvests(ctx, client, harvester, guard, ledger)
+	<-client.polled
+
+	// Our own reinvest is followed by its receipt, not by the guard
+	addReinvest(101, ours)
+	time.Sleep(time.Millisecond * 300)
+	if isCanceled(iterCtx) || len(ledger.recorded()) != 0 {
+		t.Fatal("our own reinvest should be skipped")
+	}
+
+	// The reinvest of a competitor cancels the iteration and is recorded
+	addReinvest(102, competitor)
+	select {
+	case <-iterCtx.Done():
+	case <-ctx.Done():
+		t.Fatal("the reinvest of a competitor should cancel the iteration")
+	}
+	for len(ledger.recorded()) == 0 && ctx.Err() == nil {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	recorded := ledger.recorded()
+	if len(recorded) != 1 || recorded[0].Sender != competitor || recorded[0].BlockNumber != 102 || recorded[0].Bounty.Cmp(big.NewInt(20)) != 0 {
+		t.Fatalf("unexpected reinvests recorded: %+v", recorded)
+	}
+	if !guard.Stale(big.NewInt(101)) {
+		t.Fatal("the heads older than the competitor reinvest should be stale")
+	}
+}
@@ -0,0 +1,215 @@
+package protocols
+
+import (
+	"context"
+	"defibotgo/internal/contract_abi"
+	"defibotgo/internal/models"
+	"defibotgo/internal/protocols/harvest"
+	"defibotgo/internal/protocols/impermax"
+	"defibotgo/internal/protocols/tarot"
+	"defibotgo/internal/web3"
+	"defibotgo/internal/web3/web3test"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"testing"
+)
+
+var (
+	fakeLender = common.HexToAddress("0xAa9F575a3fBF36d54FA3270fE25D4bB7Bb3bA3aE")
+	fakeGauge  = common.HexToAddress("0xA95EbEfbCB77Ae1daf0d2123784594F8ccE90274")
+	registry   = harvest.Registry{models.Tarot: tarot.NewHarvester, models.Impermax: impermax.NewHarvester}
+)
+
+type fakeCall3 struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type fakeResult struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// fakeGaugeMulticall answers aggregate3 with the given gauge values, the methods missing revert
+func fakeGaugeMulticall(t *testing.T, gaugeAbi abi.ABI, values map[string]*big.Int) func(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	multicallAbi, err := web3.LoadAbi(contract_abi.CONTRACT_ABI_MULTICALL3)
+	if err != nil {
+		t.Fatalf("failed to load multicall abi: %v", err)
+	}
+
+	return func(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+		inputs, err := multicallAbi.Methods["aggregate3"].Inputs.Unpack(msg.Data[4:])
+		if err != nil {
+			t.Fatalf("failed to unpack aggregate3 input: %v", err)
+		}
+
+		calls := *abi.ConvertType(inputs[0], new([]fakeCall3)).(*[]fakeCall3)
+		results := make([]fakeResult, len(calls))
+		for i, call := range calls {
+			method, err := gaugeAbi.MethodById(call.CallData[:4])
+			if err != nil {
+				t.Fatalf("unknown method: %v", err)
+			}
+			if value, ok := values[method.Name]; ok {
+				out, _ := method.Outputs.Pack(value)
+				results[i] = fakeResult{Success: true, ReturnData: out}
+			}
+		}
+
+		return multicallAbi.Methods["aggregate3"].Outputs.Pack(results)
+	}
+}
+
+func newImpermaxOpts() *models.ImpermaxOpts {
+	return &models.ImpermaxOpts{
+		PoolOpts: models.PoolOpts{
+			Chain:          models.Base,
+			Protocol:       models.Impermax,
+			Pool:           models.FbombCbbtc,
+			ContractLender: fakeLender,
+			ContractGauge:  fakeGauge,
+		},
+		ReinvestBounty: reinvestBounty,
+		RewardRate:     big.NewInt(1071909015217126497),
+	}
+}
+
+func TestHarvesterRegistry(t *testing.T) {
+	client := web3test.NewClient(8453)
+
+	harvester, err := registry.New(client, newImpermaxOpts())
+	if err != nil {
+		t.Fatalf("failed to build impermax harvester: %v", err)
+	}
+	if _, ok := harvester.(*impermax.Harvester); !ok {
+		t.Fatalf("unexpected harvester: expected %T, got %T", &impermax.Harvester{}, harvester)
+	}
+
+	tarotOpts := &models.TarotOpts{PoolOpts: models.PoolOpts{Protocol: models.Tarot, ContractLender: fakeLender, ContractGauge: fakeGauge}, ReinvestBounty: reinvestBounty}
+	if harvester, err = registry.New(client, tarotOpts); err != nil {
+		t.Fatalf("failed to build tarot harvester: %v", err)
+	}
+	if _, ok := harvester.(*tarot.Harvester); !ok {
+		t.Fatalf("unexpected harvester: expected %T, got %T", &tarot.Harvester{}, harvester)
+	}
+
+	// The options of a protocol are never given to the harvester of another one
+	mismatched := newImpermaxOpts()
+	mismatched.Protocol = models.Tarot
+	if _, err := registry.New(client, mismatched); err == nil {
+		t.Fatal("the tarot harvester must reject impermax options")
+	}
+
+	unknown := newImpermaxOpts()
+	unknown.Protocol = "UNKNOWN"
+	if _, err := registry.New(client, unknown); err == nil {
+		t.Fatal("a protocol without harvester must be rejected")
+	}
+}
+
+func TestImpermaxFetchState(t *testing.T) {
+	gaugeAbi, err := web3.LoadAbi(contract_abi.CONTRACT_ABI_GAUGE)
+	if err != nil {
+		t.Fatalf("failed to load gauge abi: %v", err)
+	}
+
+	// The reward rate reverts, the configured one is used
+	client := web3test.NewClient(8453)
+	client.CallContractFn = fakeGaugeMulticall(t, gaugeAbi, map[string]*big.Int{
+		"earned":      big.NewInt(891792427871174773),
+		"balanceOf":   big.NewInt(10547979589919134),
+		"totalSupply": big.NewInt(608561762745652518),
+	})
+
+	harvester, err := impermax.NewHarvester(client, newImpermaxOpts())
+	if err != nil {
+		t.Fatalf("failed to build harvester: %v", err)
+	}
+
+	batch := web3.NewBatch()
+	resolve := harvester.FetchState(batch, &types.Header{Number: big.NewInt(29525546)})
+	if err := batch.Send(context.Background(), client); err != nil {
+		t.Fatalf("failed to send batch: %v", err)
+	}
+
+	state, err := resolve()
+	if err != nil {
+		t.Fatalf("failed to fetch state: %v", err)
+	}
+	if expected := big.NewInt(928950445699140388); state.Earned.Cmp(expected) != 0 {
+		t.Fatalf("unexpected earned: expected %v, got %v", expected, state.Earned)
+	}
+	if expected, reward := big.NewInt(18579008913982807), harvester.ComputeOpportunity(state); reward.Cmp(expected) != 0 {
+		t.Fatalf("unexpected reward: expected %v, got %v", expected, reward)
+	}
+
+	// Without the balance of the staked LP token, there is no state
+	client.CallContractFn = fakeGaugeMulticall(t, gaugeAbi, map[string]*big.Int{"earned": big.NewInt(1)})
+	batch = web3.NewBatch()
+	resolve = harvester.FetchState(batch, &types.Header{Number: big.NewInt(29525547)})
+	_ = batch.Send(context.Background(), client)
+	if _, err := resolve(); err == nil {
+		t.Fatal("a state without balance must fail")
+	}
+}
+
+func TestTarotSimulatedReward(t *testing.T) {
+	client := web3test.NewClient(8453)
+	head := &types.Header{Number: big.NewInt(29525546)}
+
+	for _, tc := range []struct {
+		estimator models.RewardEstimatorKind
+		validate  bool
+		calls     int
+	}{
+		{estimator: "", calls: 1},
+		{estimator: models.RewardEstimatorExtrapolate, calls: 1},
+		{estimator: models.RewardEstimatorExtrapolate, validate: true, calls: 2},
+		{estimator: models.RewardEstimatorSimulate, calls: 2},
+	} {
+		tarotOpts := &models.TarotOpts{PoolOpts: models.PoolOpts{Protocol: models.Tarot, ContractLender: fakeLender, ContractGauge: fakeGauge}, ReinvestBounty: reinvestBounty, RewardEstimator: tc.estimator, ValidateReward: tc.validate}
+		harvester, err := tarot.NewHarvester(client, tarotOpts)
+		if err != nil {
+			t.Fatalf("failed to build harvester: %v", err)
+		}
+
+		// The next block is only simulated when its earned is used or validated
+		batch := web3.NewBatch()
+		harvester.FetchState(batch, head)
+		if batch.Len() != tc.calls {
+			t.Errorf("estimator %q, validate %v: expected %v calls, got %v", tc.estimator, tc.validate, tc.calls, batch.Len())
+		}
+	}
+}
+
+func TestTarotIsHarvest(t *testing.T) {
+	client := web3test.NewClient(8453)
+	tarotOpts := &models.TarotOpts{PoolOpts: models.PoolOpts{Protocol: models.Tarot, ContractLender: fakeLender, ContractGauge: fakeGauge}, ReinvestBounty: reinvestBounty}
+	harvester, err := tarot.NewHarvester(client, tarotOpts)
+	if err != nil {
+		t.Fatalf("failed to build harvester: %v", err)
+	}
+
+	query := harvester.HarvestQuery()
+	reinvestId, rewardPaidId := query.Topics[0][0], query.Topics[0][1]
+	other := common.HexToAddress("0x01")
+
+	for _, test := range []struct {
+		name     string
+		log      types.Log
+		expected bool
+	}{
+		{"reinvest", types.Log{Address: fakeLender, Topics: []common.Hash{reinvestId, common.BytesToHash(other.Bytes())}}, true},
+		{"reward paid to the lender", types.Log{Address: fakeGauge, Topics: []common.Hash{rewardPaidId, common.BytesToHash(fakeLender.Bytes())}}, true},
+		{"reward paid to another depositor", types.Log{Address: fakeGauge, Topics: []common.Hash{rewardPaidId, common.BytesToHash(other.Bytes())}}, false},
+		{"anonymous log", types.Log{Address: fakeLender}, false},
+	} {
+		if isHarvest := harvester.IsHarvest(test.log); isHarvest != test.expected {
+			t.Fatalf("%s: expected %v, got %v", test.name, test.expected, isHarvest)
+		}
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"defibotgo/internal/config"
 	"defibotgo/internal/contract_abi"
 	"defibotgo/internal/models"
+	"defibotgo/internal/protocols/harvest"
 	"defibotgo/internal/protocols/tarot"
 	"defibotgo/internal/utils"
 	"defibotgo/internal/web3"
+	"fmt"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"math/big"
@@ -22,7 +24,7 @@ func TestComputeRewardToEth(t *testing.T) {
 	vaultPendingReward := big.NewInt(795946798735693857)
 	pairValue := big.NewInt(31920000000000)
 
-	rewardToken := tarot.ComputeReward(vaultPendingReward, reinvestBounty)
+	rewardToken := harvest.ComputeReward(vaultPendingReward, reinvestBounty)
 	rewardConverted := utils.ConvertToEth(rewardToken, pairValue)
 	if rewardConverted.Cmp(expected) != 0 {
 		t.Fatalf("rewardToken is incorrect: expecting %v got %v", expected, rewardConverted)
@@ -55,7 +57,7 @@ func TestComputeRewardOnChain(t *testing.T) {
 		t.Fatalf("failed to call earned contract: %v", err)
 	}
 
-	rewardToken := tarot.ComputeReward(vaultPendingReward, reinvestBounty)
+	rewardToken := harvest.ComputeReward(vaultPendingReward, reinvestBounty)
 	if rewardExpected.Cmp(rewardToken) != 0 {
 		t.Fatalf("the reward token is incorrect: expecting %v got %v", rewardExpected, rewardToken)
 	}
@@ -73,8 +75,8 @@ func TestGetVaultPendingReward(t *testing.T) {
 	expectedVaultPendingReward := big.NewInt(928950445699140388)
 	expectedReward := big.NewInt(18579008913982807)
 
-	estimateEarned := tarot.GetVaultPendingReward(earned, rewardRate, secondInterval, balance, totalSupply)
-	estimateReward := tarot.ComputeReward(estimateEarned, reinvestBounty)
+	estimateEarned := harvest.GetVaultPendingReward(earned, rewardRate, secondInterval, balance, totalSupply)
+	estimateReward := harvest.ComputeReward(estimateEarned, reinvestBounty)
 	if estimateEarned.Cmp(expectedVaultPendingReward) != 0 {
 		t.Fatalf("the estimation of Earned is inccorect: expecting %v got %v", expectedVaultPendingReward, estimateEarned)
 	}
@@ -94,8 +96,7 @@ func TestGetTransactionGasFees(t *testing.T) {
 	gasFeeExpected := big.NewInt(1909636)
 	gasTipExpected := big.NewInt(5678)
 
-	protocolOpts := &models.TarotOpts{
-		ReinvestBounty: reinvestBounty,
+	protocolOpts := &models.PoolOpts{
 		PriorityFee:    big.NewInt(5678),
 		Sender:         common.HexToAddress(config.GetSecret(config.WalletTestPrivateKey)),
 		Chain:          chain,
@@ -104,14 +105,15 @@ func TestGetTransactionGasFees(t *testing.T) {
 	}
 
 	//https://basescan.org/tx/0x93efd0f572de355f5cd34120af45360cc1d22765df8ae7fe91528ff2801b210b
-	tarotCalculationOpts := &tarot.ProtocolCalculationOpts{}
+	tarotCalculationOpts := &harvest.CalculationOpts{}
 	tarotCalculationOpts.VaultPendingRewardValue = big.NewInt(276513852697572252)
+	tarotCalculationOpts.RewardValue = harvest.ComputeReward(tarotCalculationOpts.VaultPendingRewardValue, reinvestBounty)
 	tarotCalculationOpts.RewardPairValue = big.NewInt(269300000000000)
 	tarotCalculationOpts.BaseFeeValue = big.NewInt(1903958)
 	tarotCalculationOpts.EstimateGasLimitValue = 426244
 	tarotCalculationOpts.PriorityFeeValue = big.NewInt(5678)
 
-	isWorth, gasOpts, _, err := tarot.GetL2TransactionGasFees(
+	isWorth, gasOpts, _, err := harvest.GetL2TransactionGasFees(
 		protocolOpts,
 		tarotCalculationOpts,
 		priorityFeeIncreasePercent,
@@ -143,3 +145,22 @@ func TestGetTransactionGasFees(t *testing.T) {
 	}
 
 }
+
+func TestSelectVaultPendingReward(t *testing.T) {
+	estimated := big.NewInt(928950445699140388)
+	simulated := models.WeiResult{Value: big.NewInt(928950445699140000)}
+	blockNumber := big.NewInt(29525546)
+
+	if reward := tarot.SelectVaultPendingReward("", estimated, simulated, blockNumber); reward.Cmp(estimated) != 0 {
+		t.Fatalf("the extrapolated earned should be used by default: got %v", reward)
+	}
+
+	if reward := tarot.SelectVaultPendingReward(models.RewardEstimatorSimulate, estimated, simulated, blockNumber); reward.Cmp(simulated.Value) != 0 {
+		t.Fatalf("the simulated earned should be used: got %v", reward)
+	}
+
+	failed := models.WeiResult{Err: fmt.Errorf("eth_call overrides not supported")}
+	if reward := tarot.SelectVaultPendingReward(models.RewardEstimatorSimulate, estimated, failed, blockNumber); reward.Cmp(estimated) != 0 {
+		t.Fatalf("the extrapolated earned should be used when the simulation failed: got %v", reward)
+	}
+}
@@ -0,0 +1,187 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"defibotgo/internal/accounting"
+	"defibotgo/internal/config"
+	"defibotgo/internal/contract_abi"
+	"defibotgo/internal/models"
+	"defibotgo/internal/report"
+	"defibotgo/internal/web3"
+	"defibotgo/internal/web3/web3test"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"testing"
+	"time"
+)
+
+var wallet = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+var otherWallet = common.HexToAddress("0x00000000000000000000000000000000000000bb")
+var lender = common.HexToAddress("0x042c37762d1d126bc61eac2f5ceb7a96318f5db9")
+
+var testPoolOpts = web3.PoolOpts{
+	MaxHeadLag:             3,
+	HealthCheckInterval:    time.Second,
+	MaxConsecutiveFailures: 2,
+	FailureCooldown:        time.Minute,
+}
+
+func newHarvest(day int, pool models.Pool, harvestWallet common.Address, success bool, bounty int64, fee int64) accounting.Harvest {
+	return accounting.Harvest{
+		Time:        time.Date(2026, 10, day, 12, 0, 0, 0, time.UTC),
+		Chain:       models.Base,
+		Pool:        pool,
+		Wallet:      harvestWallet,
+		Success:     success,
+		RewardToken: big.NewInt(bounty * 2),
+		RewardEth:   big.NewInt(bounty),
+		L2Fee:       big.NewInt(fee),
+		L1Fee:       big.NewInt(1),
+		ProfitEth:   big.NewInt(bounty - fee - 1),
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	rows := report.Aggregate([]accounting.Harvest{
+		newHarvest(2, models.UsdcAero, wallet, true, 100, 9),
+		newHarvest(1, models.UsdcAero, wallet, true, 100, 9),
+		newHarvest(1, models.UsdcAero, wallet, false, 0, 4),
+		newHarvest(1, models.UsdcAero, otherWallet, true, 50, 9),
+		newHarvest(1, models.AeroTarot, wallet, true, 10, 9),
+	})
+
+	if len(rows) != 4 {
+		t.Fatalf("unexpected rows: expected 4, got %v", len(rows))
+	}
+
+	// Ordered by day, chain, pool and wallet
+	expected := []struct {
+		day    string
+		pool   models.Pool
+		wallet common.Address
+	}{
+		{"2026-10-01", models.AeroTarot, wallet},
+		{"2026-10-01", models.UsdcAero, wallet},
+		{"2026-10-01", models.UsdcAero, otherWallet},
+		{"2026-10-02", models.UsdcAero, wallet},
+	}
+	for i, row := range rows {
+		if row.Day != expected[i].day || row.Pool != expected[i].pool || row.Wallet != expected[i].wallet {
+			t.Fatalf("unexpected row %d: expected %v, got %+v", i, expected[i], row)
+		}
+	}
+
+	row := rows[1]
+	if row.Count != 2 || row.Failed != 1 {
+		t.Fatalf("unexpected count: expected 2 with 1 failed, got %v with %v failed", row.Count, row.Failed)
+	}
+	if row.GrossBounty.Cmp(big.NewInt(200)) != 0 || row.GrossBountyEth.Cmp(big.NewInt(100)) != 0 || row.GasSpent.Cmp(big.NewInt(15)) != 0 || row.NetProfitEth.Cmp(big.NewInt(85)) != 0 {
+		t.Fatalf("unexpected amounts: %+v", row)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	rows := report.Aggregate([]accounting.Harvest{newHarvest(1, models.UsdcAero, wallet, true, 100, 9)})
+
+	var csvOut bytes.Buffer
+	if err := report.Write(&csvOut, report.FormatCsv, rows); err != nil {
+		t.Fatalf("failed to write csv: %v", err)
+	}
+	expectedCsv := "day,chain,pool,wallet,count,failed,gross_bounty,gross_bounty_eth,gas_spent,net_profit_eth\n" +
+		"2026-10-01,BASE,USDC_AERO," + wallet.Hex() + ",1,0,200,100,10,90\n"
+	if csvOut.String() != expectedCsv {
+		t.Fatalf("unexpected csv:\n%s", csvOut.String())
+	}
+
+	var jsonOut bytes.Buffer
+	if err := report.Write(&jsonOut, report.FormatJson, rows); err != nil {
+		t.Fatalf("failed to write json: %v", err)
+	}
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(jsonOut.Bytes(), &decoded); err != nil {
+		t.Fatalf("failed to decode json: %v", err)
+	}
+	if len(decoded) != 1 || decoded[0]["netProfitEth"] != "90" || decoded[0]["pool"] != "USDC_AERO" {
+		t.Fatalf("unexpected json: %s", jsonOut.String())
+	}
+
+	if err := report.Write(&jsonOut, report.Format("XML"), rows); err == nil {
+		t.Fatalf("expected an error for an unknown format")
+	}
+}
+
+func TestScanReinvests(t *testing.T) {
+	lenderAbi, err := web3.LoadAbi(contract_abi.CONTRACT_ABI_LENDER)
+	if err != nil {
+		t.Fatalf("failed to load abi: %v", err)
+	}
+	reinvest := lenderAbi.Events["Reinvest"]
+
+	reinvestLog := func(block uint64, caller common.Address, bounty int64) types.Log {
+		data, err := reinvest.Inputs.NonIndexed().Pack(big.NewInt(bounty*100), big.NewInt(bounty))
+		if err != nil {
+			t.Fatalf("failed to pack reinvest: %v", err)
+		}
+		return types.Log{
+			Address:     lender,
+			Topics:      []common.Hash{reinvest.ID, common.BytesToHash(caller.Bytes())},
+			Data:        data,
+			BlockNumber: block,
+			TxHash:      common.BigToHash(big.NewInt(int64(block))),
+		}
+	}
+
+	ethService := &web3test.EthService{
+		Head:    20000,
+		ChainID: 8453,
+		Logs: []types.Log{
+			reinvestLog(100, wallet, 1000),
+			reinvestLog(7000, otherWallet, 5000),
+			reinvestLog(12000, wallet, 2000),
+			// Out of the scanned range
+			reinvestLog(19000, wallet, 3000),
+		},
+		Headers: map[uint64]*types.Header{
+			100:   {Number: big.NewInt(100), Time: uint64(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC).Unix()), Difficulty: big.NewInt(0)},
+			12000: {Number: big.NewInt(12000), Time: uint64(time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC).Unix()), Difficulty: big.NewInt(0)},
+		},
+		Receipts: map[common.Hash]json.RawMessage{},
+	}
+	for _, block := range []int64{100, 12000} {
+		hash := common.BigToHash(big.NewInt(block))
+		ethService.Receipts[hash] = json.RawMessage(`{"transactionHash":"` + hash.Hex() + `","status":"0x1","gasUsed":"0x64","effectiveGasPrice":"0x2","l1Fee":"0x32","logs":[]}`)
+	}
+
+	server := web3test.NewRPCServer(map[string]interface{}{"eth": ethService})
+	defer server.Close()
+
+	pool, err := web3.NewRpcPool(context.Background(), config.ParseRpcEndpoints(server.URL), testPoolOpts)
+	if err != nil {
+		t.Fatalf("failed to build pool: %v", err)
+	}
+	defer pool.Close()
+
+	// The reward token is worth half an ETH
+	scan := report.ReinvestScan{Chain: models.Base, Pool: models.UsdcAero, Lender: lender, Wallet: wallet, FromBlock: 0, ToBlock: 15000}
+	harvests, err := report.ScanReinvests(context.Background(), pool, scan, big.NewInt(5e17))
+	if err != nil {
+		t.Fatalf("failed to scan reinvests: %v", err)
+	}
+
+	if len(harvests) != 2 {
+		t.Fatalf("unexpected harvests: expected 2, got %v", len(harvests))
+	}
+	first := harvests[0]
+	if first.BlockNumber != 100 || first.RewardToken.Cmp(big.NewInt(1000)) != 0 || first.RewardEth.Cmp(big.NewInt(500)) != 0 {
+		t.Fatalf("unexpected harvest: %+v", first)
+	}
+	if first.Fee().Cmp(big.NewInt(250)) != 0 || first.ProfitEth.Cmp(big.NewInt(250)) != 0 {
+		t.Fatalf("unexpected fee and profit: %v and %v", first.Fee(), first.ProfitEth)
+	}
+	if day := harvests[1].Time.Format("2006-01-02"); day != "2026-10-02" {
+		t.Fatalf("unexpected harvest day: %v", day)
+	}
+}
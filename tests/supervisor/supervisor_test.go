@@ -0,0 +1,113 @@
+package supervisor
+
+import (
+	"context"
+	"defibotgo/internal/supervisor"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testOpts = supervisor.Opts{
+	MinBackoff:      time.Millisecond,
+	MaxBackoff:      10 * time.Millisecond,
+	StableAfter:     time.Minute,
+	ShutdownTimeout: time.Second,
+}
+
+func TestSupervisorRestartsFailedWorkers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var panics, failures atomic.Int32
+	restarted := make(chan struct{})
+	sup := supervisor.New(testOpts)
+	sup.Add(supervisor.Worker{Name: "panicking", Run: func(ctx context.Context) error {
+		if panics.Add(1) < 3 {
+			panic("cache init failed")
+		}
+		<-ctx.Done()
+		return nil
+	}})
+	sup.Add(supervisor.Worker{Name: "failing", Run: func(ctx context.Context) error {
+		if failures.Add(1) < 3 {
+			return errors.New("rpc unavailable")
+		}
+		close(restarted)
+		<-ctx.Done()
+		return nil
+	}})
+
+	done := make(chan error, 1)
+	go func() { done <- sup.Run(ctx) }()
+
+	select {
+	case <-restarted:
+	case <-time.After(time.Second):
+		t.Fatal("the failing worker was not restarted")
+	}
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := panics.Load(); got != 3 {
+		t.Errorf("panicking worker started %d times, want 3", got)
+	}
+	if got := failures.Load(); got != 3 {
+		t.Errorf("failing worker started %d times, want 3", got)
+	}
+}
+
+func TestSupervisorWaitsForWorkers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var stopped atomic.Bool
+	started := make(chan struct{})
+	sup := supervisor.New(testOpts)
+	sup.Add(supervisor.Worker{Name: "slow", Run: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond)
+		stopped.Store(true)
+		return nil
+	}})
+
+	done := make(chan error, 1)
+	go func() { done <- sup.Run(ctx) }()
+	<-started
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !stopped.Load() {
+		t.Error("Run returned before the worker stopped")
+	}
+}
+
+func TestSupervisorShutdownTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	opts := testOpts
+	opts.ShutdownTimeout = 10 * time.Millisecond
+	sup := supervisor.New(opts)
+	sup.Add(supervisor.Worker{Name: "stuck", Run: func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}})
+
+	done := make(chan error, 1)
+	go func() { done <- sup.Run(ctx) }()
+	<-started
+	cancel()
+
+	if err := <-done; err == nil {
+		t.Fatal("Run returned no error while a worker was stuck")
+	}
+}
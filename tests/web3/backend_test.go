@@ -0,0 +1,70 @@
+package web3
+
+import (
+	"crypto/ecdsa"
+	"defibotgo/internal/web3"
+	"defibotgo/internal/web3/web3test"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"testing"
+)
+
+var fakeChainID = int64(8453)
+var fakeLender = common.HexToAddress("0x042c37762d1d126bc61eac2f5ceb7a96318f5db9")
+
+func signFakeTx(t *testing.T, key *ecdsa.PrivateKey, nonce uint64, tip int64) *types.Transaction {
+	t.Helper()
+	chainID := big.NewInt(fakeChainID)
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		To:        &fakeLender,
+		Gas:       400000,
+		GasTipCap: big.NewInt(tip),
+		GasFeeCap: big.NewInt(tip + 1000000),
+	})
+	signedTx, err := types.SignTx(tx, types.LatestSignerForChainID(chainID), key)
+	if err != nil {
+		t.Fatalf("failed to sign tx: %v", err)
+	}
+	return signedTx
+}
+
+func TestGetBaseFeePerGasOffline(t *testing.T) {
+	client := web3test.NewClient(fakeChainID)
+	client.AddHeader(&types.Header{Number: big.NewInt(100), BaseFee: big.NewInt(1903958)})
+
+	baseFee, err := web3.GetBaseFeePerGas(client, big.NewInt(100))
+	if err != nil {
+		t.Fatalf("Failed to get base fee per gas: %v", err)
+	}
+
+	if baseFee.Cmp(big.NewInt(1903958)) != 0 {
+		t.Fatalf("GetBaseFeePerGas failed, expected %v, got %v", 1903958, baseFee)
+	}
+}
+
+func TestGetPriorityFeeOffline(t *testing.T) {
+	ourKey, _ := crypto.GenerateKey()
+	competitorKey, _ := crypto.GenerateKey()
+	ourAddress := crypto.PubkeyToAddress(ourKey.PublicKey)
+
+	client := web3test.NewClient(fakeChainID)
+	client.Head = 100
+	client.AddTransaction(signFakeTx(t, ourKey, 0, 900000), &types.Log{Address: fakeLender, BlockNumber: 95})
+	client.AddTransaction(signFakeTx(t, competitorKey, 0, 70260), &types.Log{Address: fakeLender, BlockNumber: 96})
+	client.AddTransaction(signFakeTx(t, competitorKey, 1, 154275), &types.Log{Address: fakeLender, BlockNumber: 98})
+	// Out of the block range
+	client.AddTransaction(signFakeTx(t, competitorKey, 2, 999999), &types.Log{Address: fakeLender, BlockNumber: 80})
+
+	priorityFee, err := web3.GetPriorityFee(client, ourAddress, fakeLender, big.NewInt(10), nil)
+	if err != nil {
+		t.Fatalf("failed to get priority fee: %v", err)
+	}
+
+	if priorityFee.Cmp(big.NewInt(154275)) != 0 {
+		t.Fatalf("getPriorityFee failed, expected %v, got %v", 154275, priorityFee)
+	}
+}
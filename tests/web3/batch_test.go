@@ -0,0 +1,124 @@
+package web3
+
+import (
+	"context"
+	"defibotgo/internal/config"
+	"defibotgo/internal/web3"
+	"defibotgo/internal/web3/web3test"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"math/big"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBatchSingleRoundTrip(t *testing.T) {
+	account := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	ethService := &web3test.EthService{
+		Head:        150,
+		ChainID:     fakeChainID,
+		Nonces:      map[common.Address]uint64{account: 7},
+		GasEstimate: 420000,
+	}
+
+	var httpRequests atomic.Int64
+	server := httptest.NewServer(web3test.CountRequests(web3test.NewRPCHandler(map[string]interface{}{"eth": ethService}), &httpRequests))
+	defer server.Close()
+
+	pool, err := web3.NewRpcPool(context.Background(), config.ParseRpcEndpoints(server.URL), testPoolOpts)
+	if err != nil {
+		t.Fatalf("failed to build pool: %v", err)
+	}
+	defer pool.Close()
+
+	batch := web3.NewBatch()
+	nonce := batch.PendingNonceAt(account)
+	gas := batch.EstimateGas(ethereum.CallMsg{From: account, To: &fakeLender}, big.NewInt(150))
+	// eth_getLogs is not served, only its own result must fail
+	logs := batch.FilterLogs(web3.PastTransactionsQuery(fakeLender, big.NewInt(10), big.NewInt(150)))
+
+	httpRequests.Store(0)
+	before := web3.GetBatchMetrics()
+
+	if err := batch.Send(context.Background(), pool); err != nil {
+		t.Fatalf("failed to send batch: %v", err)
+	}
+
+	if httpRequests.Load() != 1 {
+		t.Fatalf("the batch should cost one HTTP request, got %v", httpRequests.Load())
+	}
+	if nonce.Err != nil || nonce.Value != 7 {
+		t.Fatalf("unexpected nonce: %v, %v", nonce.Value, nonce.Err)
+	}
+	if gas.Err != nil || gas.Value != 420000 {
+		t.Fatalf("unexpected gas estimate: %v, %v", gas.Value, gas.Err)
+	}
+	if logs.Err == nil {
+		t.Fatalf("the logs request should fail")
+	}
+
+	after := web3.GetBatchMetrics()
+	if after.Requests-before.Requests != 3 || after.RoundTrips-before.RoundTrips != 1 {
+		t.Fatalf("unexpected batch metrics: before %+v, after %+v", before, after)
+	}
+}
+
+func TestBatchSequentialFallback(t *testing.T) {
+	client := web3test.NewClient(fakeChainID)
+	account := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	client.Nonces[account] = 3
+	client.GasEstimate = 210000
+
+	batch := web3.NewBatch()
+	nonce := batch.PendingNonceAt(account)
+	gas := batch.EstimateGas(ethereum.CallMsg{From: account, To: &fakeLender}, nil)
+	tx := batch.TransactionByHash(common.HexToHash("0x01"))
+
+	before := web3.GetBatchMetrics()
+
+	if err := batch.Send(context.Background(), client); err != nil {
+		t.Fatalf("failed to send batch: %v", err)
+	}
+
+	if nonce.Err != nil || nonce.Value != 3 {
+		t.Fatalf("unexpected nonce: %v, %v", nonce.Value, nonce.Err)
+	}
+	if gas.Err != nil || gas.Value != 210000 {
+		t.Fatalf("unexpected gas estimate: %v, %v", gas.Value, gas.Err)
+	}
+	if tx.Err != ethereum.NotFound {
+		t.Fatalf("unexpected transaction error: %v", tx.Err)
+	}
+
+	after := web3.GetBatchMetrics()
+	if after.Requests-before.Requests != 3 || after.RoundTrips-before.RoundTrips != 3 {
+		t.Fatalf("unexpected batch metrics: before %+v, after %+v", before, after)
+	}
+}
+
+func TestBatchCallContractWithOverrides(t *testing.T) {
+	ethService := &web3test.EthService{Head: 150, ChainID: fakeChainID, CallResult: []byte{0x2a}}
+	server := web3test.NewRPCServer(map[string]interface{}{"eth": ethService})
+	defer server.Close()
+
+	pool, err := web3.NewRpcPool(context.Background(), config.ParseRpcEndpoints(server.URL), testPoolOpts)
+	if err != nil {
+		t.Fatalf("failed to build pool: %v", err)
+	}
+	defer pool.Close()
+
+	batch := web3.NewBatch()
+	result := batch.CallContractWithOverrides(ethereum.CallMsg{To: &fakeLender}, big.NewInt(150), nil, gethclient.BlockOverrides{Number: big.NewInt(151), Time: 1700000002})
+	if err := batch.Send(context.Background(), pool); err != nil {
+		t.Fatalf("failed to send batch: %v", err)
+	}
+
+	if result.Err != nil || len(result.Value) != 1 || result.Value[0] != 0x2a {
+		t.Fatalf("unexpected call result: %v, %v", result.Value, result.Err)
+	}
+	if ethService.CallBlockOverrides["number"] != "0x97" || ethService.CallBlockOverrides["time"] != "0x6553f102" {
+		t.Fatalf("the block overrides should be sent with the call: %v", ethService.CallBlockOverrides)
+	}
+}
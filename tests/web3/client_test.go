@@ -142,7 +142,7 @@ func TestEstimateGas(t *testing.T) {
 		Value: big.NewInt(0),
 	}
 
-	estimateGas, err := web3.EstimateGas(ethClient, msg)
+	estimateGas, err := web3.EstimateGas(ethClient, msg, nil)
 
 	if err != nil {
 		t.Fatalf("Failed to estimate gas: %v", err)
@@ -0,0 +1,78 @@
+package web3
+
+import (
+	"context"
+	"defibotgo/internal/utils"
+	"defibotgo/internal/web3"
+	"defibotgo/internal/web3/web3test"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"testing"
+)
+
+func TestClassifyTxError(t *testing.T) {
+	testCases := []struct {
+		err   error
+		class error
+	}{
+		{errors.New("nonce too low: next nonce 5, tx nonce 4"), web3.ErrNonceTooLow},
+		{errors.New("nonce too high"), web3.ErrNonceTooHigh},
+		{errors.New("replacement transaction underpriced"), web3.ErrUnderpriced},
+		{errors.New("max fee per gas less than block base fee"), web3.ErrUnderpriced},
+		{errors.New("insufficient funds for gas * price + value"), web3.ErrInsufficientFunds},
+		{fmt.Errorf("send: %w", context.DeadlineExceeded), web3.ErrTimeout},
+		{&web3test.RevertError{Data: packErrorString(t, "Tarot: REINVEST")}, web3.ErrReverted},
+		{errors.New("connection refused"), nil},
+	}
+
+	for _, tc := range testCases {
+		err := web3.ClassifyTxError(tc.err)
+		if class := web3.ErrorClass(err); class != tc.class {
+			t.Fatalf("unexpected class of %q: expected %v, got %v", tc.err, tc.class, class)
+		}
+		if !errors.Is(err, tc.err) && tc.class != web3.ErrReverted {
+			t.Fatalf("the classified error should wrap the original one: %v", err)
+		}
+	}
+}
+
+func TestRecoveryFor(t *testing.T) {
+	if recovery := web3.RecoveryFor(errors.New("nonce too low")); !recovery.ResyncNonce || recovery.Backoff != 0 {
+		t.Fatalf("a nonce too low should resync without waiting: %+v", recovery)
+	}
+	if recovery := web3.RecoveryFor(errors.New("insufficient funds")); recovery.Backoff != utils.RetryExpiredContextSleep {
+		t.Fatalf("an empty wallet should back off: %+v", recovery)
+	}
+	if recovery := web3.RecoveryFor(errors.New("connection refused")); recovery.ResyncNonce || recovery.Backoff != utils.RetryErrorSleep {
+		t.Fatalf("an unclassified error should use the default recovery: %+v", recovery)
+	}
+}
+
+func TestReplayFailedReceipt(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.LatestSignerForChainID(big.NewInt(fakeChainID))
+	tx := signFakeTx(t, key, 0, 1000)
+
+	client := web3test.NewClient(fakeChainID)
+	client.CallContractFn = func(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+		if blockNumber.Uint64() != 99 || msg.From != crypto.PubkeyToAddress(key.PublicKey) {
+			t.Fatalf("the transaction should be replayed from its sender on the parent block: %v %v", blockNumber, msg.From)
+		}
+		return nil, &web3test.RevertError{Data: packErrorString(t, "Tarot: NOTHING_TO_REINVEST")}
+	}
+
+	receipt := &types.Receipt{TxHash: tx.Hash(), Status: types.ReceiptStatusFailed, BlockNumber: big.NewInt(100), GasUsed: tx.Gas() / 2}
+	revertErr := web3.ReplayFailedReceipt(context.Background(), client, signer, tx, receipt, nil)
+	if !errors.Is(revertErr, web3.ErrReverted) || revertErr.Reason != "Tarot: NOTHING_TO_REINVEST" {
+		t.Fatalf("unexpected revert: %v", revertErr)
+	}
+
+	receipt.GasUsed = tx.Gas()
+	if revertErr := web3.ReplayFailedReceipt(context.Background(), client, signer, tx, receipt, nil); revertErr.Reason != "out of gas" {
+		t.Fatalf("a transaction using all its gas should be out of gas: %v", revertErr)
+	}
+}
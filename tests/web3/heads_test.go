@@ -0,0 +1,45 @@
+package web3
+
+import (
+	"context"
+	"defibotgo/internal/web3"
+	"defibotgo/internal/web3/web3test"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestWatchHeadsPolling(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := web3test.NewClient(fakeChainID)
+	client.AddHeader(&types.Header{Number: big.NewInt(1)})
+
+	heads := web3.WatchHeads(ctx, client, 5*time.Millisecond)
+
+	if head := <-heads; head.Number.Uint64() != 1 {
+		t.Fatalf("unexpected first head: expected %v, got %v", 1, head.Number)
+	}
+
+	// The consumer is late: only the newest head must be delivered
+	client.AddHeader(&types.Header{Number: big.NewInt(2)})
+	time.Sleep(20 * time.Millisecond)
+	client.AddHeader(&types.Header{Number: big.NewInt(3)})
+	time.Sleep(20 * time.Millisecond)
+
+	if head := <-heads; head.Number.Uint64() != 3 {
+		t.Fatalf("unexpected head: expected %v, got %v", 3, head.Number)
+	}
+
+	select {
+	case head := <-heads:
+		t.Fatalf("the same block must not be delivered twice, got %v", head.Number)
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	cancel()
+	for range heads {
+	}
+}
@@ -67,7 +67,12 @@ func TestGetEstimateL1Fee(t *testing.T) {
 		t.Fatalf("Failed to build Base contract L1 Fee instance: %v", errCiph)
 	}
 
-	l1Fee, _, err := web3.GetL1GasFee(ctx, ethClient, chainId, callOpt, gasOpts, contractGasOracle, &lenderAddress, lenderData, walletPrivateKeyCiph)
+	nonce, err := ethClient.PendingNonceAt(ctx, crypto.PubkeyToAddress(walletPrivateKeyCiph.PublicKey))
+	if err != nil {
+		t.Fatalf("failed to get nonce: %v", err)
+	}
+
+	l1Fee, _, err := web3.GetL1GasFee(nonce, chainId, callOpt, gasOpts, contractGasOracle, &lenderAddress, lenderData, walletPrivateKeyCiph)
 	if err != nil {
 		t.Fatalf("failed to get estimate l1 fee: %v", err)
 	}
@@ -0,0 +1,51 @@
+package web3
+
+import (
+	"context"
+	"defibotgo/internal/web3"
+	"defibotgo/internal/web3/web3test"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestWatchLogsPolling(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lender := common.HexToAddress("0x01")
+	client := web3test.NewClient(fakeChainID)
+	client.AddHeader(&types.Header{Number: big.NewInt(1)})
+	// Mined before the watch, never delivered
+	client.Logs = append(client.Logs, types.Log{Address: lender, BlockNumber: 1, Index: 1})
+
+	logs := web3.WatchLogs(ctx, client, ethereum.FilterQuery{Addresses: []common.Address{lender}}, 5*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	client.AddTransaction(types.NewTx(&types.LegacyTx{Nonce: 1}), &types.Log{Address: lender, BlockNumber: 2, Index: 2})
+	client.AddTransaction(types.NewTx(&types.LegacyTx{Nonce: 2}), &types.Log{Address: lender, BlockNumber: 2, Index: 3, Removed: true})
+	client.AddTransaction(types.NewTx(&types.LegacyTx{Nonce: 3}), &types.Log{Address: common.HexToAddress("0x02"), BlockNumber: 2, Index: 4})
+	client.AddHeader(&types.Header{Number: big.NewInt(2)})
+
+	select {
+	case newLog := <-logs:
+		if newLog.BlockNumber != 2 || newLog.Index != 2 {
+			t.Fatalf("unexpected log: expected block %v index %v, got block %v index %v", 2, 2, newLog.BlockNumber, newLog.Index)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the new log was not delivered")
+	}
+
+	select {
+	case newLog := <-logs:
+		t.Fatalf("only the new log of the query must be delivered, got index %v", newLog.Index)
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	cancel()
+	for range logs {
+	}
+}
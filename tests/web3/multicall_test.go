@@ -0,0 +1,124 @@
+package web3
+
+import (
+	"context"
+	"defibotgo/internal/contract_abi"
+	"defibotgo/internal/web3"
+	"defibotgo/internal/web3/web3test"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"testing"
+)
+
+type fakeCall3 struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type fakeResult struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// fakeMulticall answers aggregate3 by running every call through handle, which returns nil on revert
+func fakeMulticall(t *testing.T, handle func(call fakeCall3) []byte) func(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	multicallAbi, err := web3.LoadAbi(contract_abi.CONTRACT_ABI_MULTICALL3)
+	if err != nil {
+		t.Fatalf("failed to load multicall abi: %v", err)
+	}
+
+	return func(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+		inputs, err := multicallAbi.Methods["aggregate3"].Inputs.Unpack(msg.Data[4:])
+		if err != nil {
+			t.Fatalf("failed to unpack aggregate3 input: %v", err)
+		}
+
+		calls := *abi.ConvertType(inputs[0], new([]fakeCall3)).(*[]fakeCall3)
+		results := make([]fakeResult, len(calls))
+		for i, call := range calls {
+			returnData := handle(call)
+			results[i] = fakeResult{Success: returnData != nil, ReturnData: returnData}
+		}
+
+		return multicallAbi.Methods["aggregate3"].Outputs.Pack(results)
+	}
+}
+
+func TestMulticallAggregate(t *testing.T) {
+	gaugeAbi, err := web3.LoadAbi(contract_abi.CONTRACT_ABI_GAUGE)
+	if err != nil {
+		t.Fatalf("failed to load gauge abi: %v", err)
+	}
+	gauge := common.HexToAddress("0x4f09bab2f0e15e2a078a227fe1537665f55b8360")
+
+	var pinnedBlock *big.Int
+	client := web3test.NewClient(fakeChainID)
+	handle := func(call fakeCall3) []byte {
+		method, err := gaugeAbi.MethodById(call.CallData[:4])
+		if err != nil {
+			t.Fatalf("unknown method: %v", err)
+		}
+
+		switch method.Name {
+		case "earned":
+			args, _ := method.Inputs.Unpack(call.CallData[4:])
+			if args[0].(common.Address) != fakeLender {
+				t.Fatalf("unexpected earned account %v", args[0])
+			}
+			out, _ := method.Outputs.Pack(big.NewInt(891792427871174773))
+			return out
+		case "totalSupply":
+			out, _ := method.Outputs.Pack(big.NewInt(608561762745652518))
+			return out
+		default:
+			return nil
+		}
+	}
+	multicallFn := fakeMulticall(t, handle)
+	client.CallContractFn = func(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+		pinnedBlock = blockNumber
+		return multicallFn(ctx, msg, blockNumber)
+	}
+
+	multicall, err := web3.NewMulticall(client, web3.Multicall3Address)
+	if err != nil {
+		t.Fatalf("failed to build multicall: %v", err)
+	}
+
+	calls := []web3.ContractCall{
+		{Address: gauge, Abi: &gaugeAbi, Method: "earned", Params: []interface{}{fakeLender}},
+		{Address: gauge, Abi: &gaugeAbi, Method: "totalSupply"},
+		{Address: gauge, Abi: &gaugeAbi, Method: "rewardRate", AllowFailure: true},
+	}
+	callOpts := &bind.CallOpts{BlockNumber: big.NewInt(29525546), Context: context.Background()}
+
+	results, err := multicall.Aggregate(callOpts, calls)
+	if err != nil {
+		t.Fatalf("failed to aggregate: %v", err)
+	}
+
+	if client.Calls["CallContract"] != 1 {
+		t.Fatalf("expected a single eth_call, got %v", client.Calls["CallContract"])
+	}
+	if pinnedBlock.Cmp(callOpts.BlockNumber) != 0 {
+		t.Fatalf("the multicall must be pinned to block %v, got %v", callOpts.BlockNumber, pinnedBlock)
+	}
+
+	earned, err := results[0].BigInt()
+	if err != nil || earned.Cmp(big.NewInt(891792427871174773)) != 0 {
+		t.Fatalf("unexpected earned result: %v %v", earned, err)
+	}
+
+	totalSupply, err := results[1].BigInt()
+	if err != nil || totalSupply.Cmp(big.NewInt(608561762745652518)) != 0 {
+		t.Fatalf("unexpected totalSupply result: %v %v", totalSupply, err)
+	}
+
+	if results[2].Success || results[2].Err == nil {
+		t.Fatalf("the rewardRate call should have failed: %+v", results[2])
+	}
+}
@@ -0,0 +1,167 @@
+package web3
+
+import (
+	"context"
+	"defibotgo/internal/models"
+	"defibotgo/internal/web3"
+	"defibotgo/internal/web3/web3test"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"sync"
+	"testing"
+)
+
+var nonceAccount = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+
+func acquire(t *testing.T, manager *web3.NonceManager, expected uint64) {
+	t.Helper()
+
+	nonce, err := manager.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("failed to acquire nonce: %v", err)
+	}
+	if nonce != expected {
+		t.Fatalf("unexpected nonce: expected %v, got %v", expected, nonce)
+	}
+}
+
+func TestNonceManagerAcquireRelease(t *testing.T) {
+	client := web3test.NewClient(fakeChainID)
+	client.Nonces[nonceAccount] = 5
+	manager := web3.NewNonceManager(client, nonceAccount)
+
+	acquire(t, manager, 5)
+	acquire(t, manager, 6)
+	acquire(t, manager, 7)
+
+	// The released nonces are handed out again, lowest first
+	manager.Release(6)
+	acquire(t, manager, 6)
+	manager.Release(7)
+	acquire(t, manager, 7)
+	manager.Sent(7, common.HexToHash("0x07"))
+	acquire(t, manager, 8)
+
+	// Only the first use reads the chain
+	if client.Calls["PendingNonceAt"] != 1 {
+		t.Fatalf("unexpected PendingNonceAt calls: %v", client.Calls["PendingNonceAt"])
+	}
+}
+
+func TestNonceManagerResync(t *testing.T) {
+	client := web3test.NewClient(fakeChainID)
+	client.Nonces[nonceAccount] = 5
+	manager := web3.NewNonceManager(client, nonceAccount)
+
+	acquire(t, manager, 5)
+	manager.Sent(5, common.HexToHash("0x05"))
+
+	// Another process used the wallet, the node rejects our nonce
+	client.Nonces[nonceAccount] = 9
+	acquire(t, manager, 6)
+	if err := manager.SendFailed(context.Background(), 6, errors.New("nonce too low: address 0xaa, tx: 6 state: 9")); err != nil {
+		t.Fatalf("failed to resync: %v", err)
+	}
+	acquire(t, manager, 9)
+
+	if len(manager.InFlight()) != 0 {
+		t.Fatalf("the mined transactions should not be in flight: %v", manager.InFlight())
+	}
+}
+
+func TestNonceManagerGap(t *testing.T) {
+	client := web3test.NewClient(fakeChainID)
+	client.Nonces[nonceAccount] = 5
+	manager := web3.NewNonceManager(client, nonceAccount)
+
+	acquire(t, manager, 5)
+	manager.Sent(5, common.HexToHash("0x05"))
+	manager.Done(5)
+	acquire(t, manager, 6)
+	manager.Sent(6, common.HexToHash("0x06"))
+
+	// The transaction 6 was dropped from the mempool
+	client.Nonces[nonceAccount] = 6
+	manager.Done(6)
+	if err := manager.Sync(context.Background()); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	acquire(t, manager, 6)
+}
+
+func TestNonceManagerSharedSync(t *testing.T) {
+	client := web3test.NewClient(fakeChainID)
+	client.Nonces[nonceAccount] = 5
+	manager := web3.NewNonceManager(client, nonceAccount)
+
+	// Pool A is still signing with 5 when pool B syncs the shared manager
+	acquire(t, manager, 5)
+	if err := manager.Sync(context.Background()); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	acquire(t, manager, 6)
+
+	// Once pool A gives its nonce back, the gap is reused
+	manager.Release(6)
+	manager.Release(5)
+	if err := manager.Sync(context.Background()); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	acquire(t, manager, 5)
+}
+
+func TestNonceManagerConcurrentPools(t *testing.T) {
+	client := web3test.NewClient(fakeChainID)
+	client.Nonces[nonceAccount] = 5
+	manager := web3.NewNonceManager(client, nonceAccount)
+
+	// Every pool syncs then acquires, while the others are signing, and no transaction is mined
+	const pools, txs = 4, 25
+	nonces := make(chan uint64, pools*txs)
+	var wg sync.WaitGroup
+	for range pools {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range txs {
+				if err := manager.Sync(context.Background()); err != nil {
+					t.Errorf("failed to sync: %v", err)
+					return
+				}
+				nonce, err := manager.Acquire(context.Background())
+				if err != nil {
+					t.Errorf("failed to acquire nonce: %v", err)
+					return
+				}
+				manager.Sent(nonce, common.BigToHash(new(big.Int).SetUint64(nonce)))
+				nonces <- nonce
+			}
+		}()
+	}
+	wg.Wait()
+	close(nonces)
+
+	seen := map[uint64]bool{}
+	for nonce := range nonces {
+		if seen[nonce] {
+			t.Fatalf("nonce %d handed out twice", nonce)
+		}
+		seen[nonce] = true
+	}
+	if len(seen) != pools*txs {
+		t.Fatalf("unexpected nonces: %v", len(seen))
+	}
+}
+
+func TestNonceRegistryShared(t *testing.T) {
+	client := web3test.NewClient(fakeChainID)
+	registry := web3.NewNonceRegistry()
+
+	if registry.Get(models.Base, client, nonceAccount) != registry.Get(models.Base, client, nonceAccount) {
+		t.Fatalf("the pools of a wallet should share its nonce manager")
+	}
+	if registry.Get(models.Base, client, nonceAccount) == registry.Get(models.Optimism, client, nonceAccount) {
+		t.Fatalf("the nonces of a wallet should be managed per chain")
+	}
+}
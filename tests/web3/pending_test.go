@@ -0,0 +1,241 @@
+package web3
+
+import (
+	"context"
+	"defibotgo/internal/models"
+	"defibotgo/internal/web3"
+	"defibotgo/internal/web3/web3test"
+	"errors"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"testing"
+	"time"
+)
+
+var testPendingTxOpts = web3.PendingTxOpts{
+	PollInterval:    time.Millisecond * 5,
+	StuckAfter:      time.Millisecond * 20,
+	MaxReplacements: 3,
+}
+
+// minedOnSendClient mines every transaction as soon as it is sent, so only the replacements get mined
+type minedOnSendClient struct {
+	*web3test.Client
+}
+
+func (c minedOnSendClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if err := c.Client.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+	c.AddReceipt(&types.Receipt{TxHash: tx.Hash(), Status: types.ReceiptStatusSuccessful})
+	return nil
+}
+
+func waitStuckTx(t *testing.T, isProfitable web3.ProfitCheck) (*web3.PendingTxResult, *types.Transaction, *web3test.Client) {
+	t.Helper()
+
+	key, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	signer := types.LatestSignerForChainID(big.NewInt(fakeChainID))
+
+	client := web3test.NewClient(fakeChainID)
+	client.AddHeader(&types.Header{Number: big.NewInt(100), BaseFee: big.NewInt(2000000)})
+	client.Nonces[address] = 4
+	nonces := web3.NewNonceManager(client, address)
+
+	nonce, err := nonces.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("failed to acquire nonce: %v", err)
+	}
+
+	// The original transaction is never mined
+	stuckTx := signFakeTx(t, key, nonce, 100000)
+	client.AddTransaction(stuckTx, nil)
+	nonces.Sent(nonce, stuckTx.Hash())
+
+	sender := minedOnSendClient{client}
+	submitter, _ := web3.NewSubmitter(models.SubmissionPublic, sender, "", nil)
+	manager := web3.NewPendingTxManager(sender, submitter, nonces, signer, key, testPendingTxOpts)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	result, err := manager.Wait(ctx, stuckTx, isProfitable)
+	if err != nil {
+		t.Fatalf("failed to wait transaction: %v", err)
+	}
+
+	if len(nonces.InFlight()) != 0 {
+		t.Fatalf("the nonce should be done once mined: %v", nonces.InFlight())
+	}
+
+	return result, stuckTx, client
+}
+
+func TestPendingTxSpeedUp(t *testing.T) {
+	result, stuckTx, _ := waitStuckTx(t, func(gasOpts *web3.GasOpts) bool { return true })
+
+	if result.Cancelled || result.Replacements != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.Tx.Nonce() != stuckTx.Nonce() || *result.Tx.To() != *stuckTx.To() || result.Tx.Gas() != stuckTx.Gas() {
+		t.Fatalf("the speed-up should replay the stuck transaction")
+	}
+	if result.Tx.GasTipCap().Cmp(big.NewInt(110000)) < 0 || result.Tx.GasFeeCap().Cmp(web3.BumpGasOpts(stuckTx, big.NewInt(0), 0).GasFeeCap) < 0 {
+		t.Fatalf("the speed-up fees should be bumped by 10%%: tip %v, fee cap %v", result.Tx.GasTipCap(), result.Tx.GasFeeCap())
+	}
+}
+
+func TestPendingTxCancel(t *testing.T) {
+	result, stuckTx, client := waitStuckTx(t, func(gasOpts *web3.GasOpts) bool { return false })
+
+	if !result.Cancelled || result.Replacements != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	cancelTx := client.SentTransactions()[0]
+	sender, _ := types.Sender(types.LatestSignerForChainID(big.NewInt(fakeChainID)), cancelTx)
+	if cancelTx.Nonce() != stuckTx.Nonce() || *cancelTx.To() != sender || cancelTx.Value().Sign() != 0 || cancelTx.Gas() != 21000 {
+		t.Fatalf("the cancellation should be a zero-value self-transfer at the same nonce")
+	}
+	if cancelTx.GasTipCap().Cmp(big.NewInt(110000)) < 0 {
+		t.Fatalf("the cancellation tip should be bumped by 10%%: %v", cancelTx.GasTipCap())
+	}
+}
+
+func TestPendingTxCancelUnderpriced(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	signer := types.LatestSignerForChainID(big.NewInt(fakeChainID))
+
+	client := web3test.NewClient(fakeChainID)
+	client.AddHeader(&types.Header{Number: big.NewInt(100), BaseFee: big.NewInt(2000000)})
+	client.Nonces[address] = 4
+	nonces := web3.NewNonceManager(client, address)
+	acquire(t, nonces, 4)
+
+	stuckTx := signFakeTx(t, key, 4, 100000)
+	client.AddTransaction(stuckTx, nil)
+	nonces.Sent(4, stuckTx.Hash())
+
+	// Every cancellation is rejected, none of them is sent
+	client.SendErr = errors.New("replacement transaction underpriced")
+	submitter, _ := web3.NewSubmitter(models.SubmissionPublic, client, "", nil)
+	manager := web3.NewPendingTxManager(client, submitter, nonces, signer, key, testPendingTxOpts)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	result, err := manager.Wait(ctx, stuckTx, func(gasOpts *web3.GasOpts) bool { return false })
+	if !errors.Is(err, web3.ErrTimeout) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if result.Cancelled || result.Replacements != 0 {
+		t.Fatalf("the result should describe the stuck transaction only: %+v", result)
+	}
+	if client.Calls["SendTransaction"] < 2 {
+		t.Fatalf("the cancellation should be retried: %v", client.Calls["SendTransaction"])
+	}
+}
+
+func TestBumpGasOpts(t *testing.T) {
+	tx := types.NewTx(&types.DynamicFeeTx{GasTipCap: big.NewInt(15), GasFeeCap: big.NewInt(1000)})
+
+	gasOpts := web3.BumpGasOpts(tx, big.NewInt(500), 21000)
+	if gasOpts.GasTipCap.Cmp(big.NewInt(17)) != 0 || gasOpts.GasFeeCap.Cmp(big.NewInt(1100)) != 0 {
+		t.Fatalf("unexpected bumped fees: tip %v, fee cap %v", gasOpts.GasTipCap, gasOpts.GasFeeCap)
+	}
+
+	// The fee cap covers a base fee which rose meanwhile
+	gasOpts = web3.BumpGasOpts(tx, big.NewInt(5000), 21000)
+	if gasOpts.GasFeeCap.Cmp(big.NewInt(5017)) != 0 || gasOpts.TransactionFee.Cmp(big.NewInt(5017*21000)) != 0 {
+		t.Fatalf("unexpected bumped fees: fee cap %v, transaction fee %v", gasOpts.GasFeeCap, gasOpts.TransactionFee)
+	}
+}
+
+func TestPendingTxDropped(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	signer := types.LatestSignerForChainID(big.NewInt(fakeChainID))
+
+	client := web3test.NewClient(fakeChainID)
+	client.AddHeader(&types.Header{Number: big.NewInt(100), BaseFee: big.NewInt(2000000)})
+	client.Nonces[address] = 5
+	nonces := web3.NewNonceManager(client, address)
+	submitter, _ := web3.NewSubmitter(models.SubmissionPublic, client, "", nil)
+	manager := web3.NewPendingTxManager(client, submitter, nonces, signer, key, testPendingTxOpts)
+
+	// The transaction left the mempool of the node
+	nonce, err := nonces.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("failed to acquire nonce: %v", err)
+	}
+	droppedTx := signFakeTx(t, key, nonce, 100000)
+	nonces.Sent(nonce, droppedTx.Hash())
+	_, err = manager.Wait(context.Background(), droppedTx, func(gasOpts *web3.GasOpts) bool { return true })
+	if !errors.Is(err, web3.ErrDropped) {
+		t.Fatalf("the transaction should be dropped: %v", err)
+	}
+	if len(client.SentTransactions()) != 0 {
+		t.Fatalf("a dropped transaction should not be replaced")
+	}
+
+	// The harvest loop resyncs after a drop, the nonce is handed out again instead of leaving a gap
+	if err := nonces.Sync(context.Background()); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	nonce, err = nonces.Acquire(context.Background())
+	if err != nil || nonce != 5 {
+		t.Fatalf("the dropped nonce should be reused: got %v, %v", nonce, err)
+	}
+}
+
+func TestPendingBundle(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	signer := types.LatestSignerForChainID(big.NewInt(fakeChainID))
+
+	client := web3test.NewClient(fakeChainID)
+	client.AddHeader(&types.Header{Number: big.NewInt(100), BaseFee: big.NewInt(2000000)})
+	client.Nonces[address] = 5
+	nonces := web3.NewNonceManager(client, address)
+	submitter, _ := web3.NewSubmitter(models.SubmissionPublic, client, "", nil)
+	manager := web3.NewPendingTxManager(client, submitter, nonces, signer, key, testPendingTxOpts)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// The bundle targeting the block 101 is mined in it
+	nonce, _ := nonces.Acquire(ctx)
+	minedTx := signFakeTx(t, key, nonce, 100000)
+	nonces.Sent(nonce, minedTx.Hash())
+	client.AddReceipt(&types.Receipt{TxHash: minedTx.Hash(), Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(101)})
+	result, err := manager.WaitBundle(ctx, minedTx, big.NewInt(101))
+	if err != nil || result.Receipt == nil || result.Tx != minedTx {
+		t.Fatalf("the bundle should be mined: %+v, %v", result, err)
+	}
+	if len(nonces.InFlight()) != 0 {
+		t.Fatalf("the nonce should be done once mined: %v", nonces.InFlight())
+	}
+
+	// The bundle targeting the block 102 is not included, it is waited for until a later block
+	client.Nonces[address] = 6
+	nonce, _ = nonces.Acquire(ctx)
+	missedTx := signFakeTx(t, key, nonce, 100000)
+	nonces.Sent(nonce, missedTx.Hash())
+	go func() {
+		time.Sleep(testPendingTxOpts.StuckAfter)
+		client.AddHeader(&types.Header{Number: big.NewInt(103), BaseFee: big.NewInt(2000000)})
+	}()
+	_, err = manager.WaitBundle(ctx, missedTx, big.NewInt(102))
+	if !errors.Is(err, web3.ErrNotIncluded) {
+		t.Fatalf("the bundle should not be included: %v", err)
+	}
+	if len(client.SentTransactions()) != 0 {
+		t.Fatalf("a bundle should not be replaced")
+	}
+
+	// The nonce is given back without resync, the next bundle uses it
+	if len(nonces.InFlight()) != 0 {
+		t.Fatalf("the nonce of the bundle should not be in flight: %v", nonces.InFlight())
+	}
+	acquire(t, nonces, 6)
+}
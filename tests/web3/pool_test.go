@@ -0,0 +1,105 @@
+package web3
+
+import (
+	"context"
+	"defibotgo/internal/config"
+	"defibotgo/internal/web3"
+	"defibotgo/internal/web3/web3test"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testPoolOpts = web3.PoolOpts{
+	MaxHeadLag:             3,
+	HealthCheckInterval:    time.Second,
+	MaxConsecutiveFailures: 2,
+	FailureCooldown:        time.Minute,
+}
+
+func TestRpcPoolFailover(t *testing.T) {
+	failingServer := web3test.NewFailingServer(http.StatusServiceUnavailable)
+	defer failingServer.Close()
+
+	ethService := &web3test.EthService{Head: 150, ChainID: 8453}
+	authServer := httptest.NewServer(web3test.RequireHeader(web3test.NewRPCHandler(map[string]interface{}{"eth": ethService}), "Authorization", "Bearer abc"))
+	defer authServer.Close()
+
+	endpoints := config.ParseRpcEndpoints(failingServer.URL + "," + authServer.URL + "|Authorization=Bearer abc")
+	pool, err := web3.NewRpcPool(context.Background(), endpoints, testPoolOpts)
+	if err != nil {
+		t.Fatalf("failed to build pool: %v", err)
+	}
+	defer pool.Close()
+
+	for i := 0; i < 3; i++ {
+		blockNumber, err := pool.BlockNumber(context.Background())
+		if err != nil {
+			t.Fatalf("failed to get block number: %v", err)
+		}
+		if blockNumber != 150 {
+			t.Fatalf("unexpected block number: expected %v, got %v", 150, blockNumber)
+		}
+	}
+
+	// Once failed, the endpoint is ranked after the healthy one and is not tried anymore
+	stats := pool.Stats()
+	if stats[0].Errors != 1 || stats[0].Requests != 1 {
+		t.Fatalf("the failing endpoint should be tried once: %+v", stats[0])
+	}
+	if stats[1].Errors != 0 || stats[1].Requests != 3 {
+		t.Fatalf("the healthy endpoint should answer every request: %+v", stats[1])
+	}
+}
+
+func TestRpcPoolStaleHead(t *testing.T) {
+	laggingService := &web3test.EthService{Head: 100, ChainID: 8453}
+	laggingServer := web3test.NewRPCServer(map[string]interface{}{"eth": laggingService})
+	defer laggingServer.Close()
+
+	upToDateService := &web3test.EthService{Head: 110, ChainID: 8453}
+	upToDateServer := web3test.NewRPCServer(map[string]interface{}{"eth": upToDateService})
+	defer upToDateServer.Close()
+
+	endpoints := config.ParseRpcEndpoints(laggingServer.URL + "," + upToDateServer.URL)
+	pool, err := web3.NewRpcPool(context.Background(), endpoints, testPoolOpts)
+	if err != nil {
+		t.Fatalf("failed to build pool: %v", err)
+	}
+	defer pool.Close()
+
+	pool.CheckHealth(context.Background())
+	if stats := pool.Stats(); !stats[0].Stale || stats[1].Stale {
+		t.Fatalf("only the lagging endpoint should be stale: %+v", stats)
+	}
+
+	blockNumber, err := pool.BlockNumber(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get block number: %v", err)
+	}
+	if blockNumber != 110 {
+		t.Fatalf("the stale endpoint should be demoted: expected %v, got %v", 110, blockNumber)
+	}
+
+	// The endpoint catches up
+	laggingService.SetHead(111)
+	pool.CheckHealth(context.Background())
+	if stats := pool.Stats(); stats[0].Stale {
+		t.Fatalf("the endpoint should not be stale anymore: %+v", stats[0])
+	}
+}
+
+func TestParseRpcEndpoints(t *testing.T) {
+	endpoints := config.ParseRpcEndpoints(" https://a.example , ,wss://b.example/key|Authorization=Bearer abc|X-Api-Key=xyz")
+
+	if len(endpoints) != 2 {
+		t.Fatalf("expected 2 endpoints, got %v", len(endpoints))
+	}
+	if endpoints[0].Url != "https://a.example" || endpoints[0].Headers != nil {
+		t.Fatalf("unexpected first endpoint: %+v", endpoints[0])
+	}
+	if endpoints[1].Url != "wss://b.example/key" || endpoints[1].Headers["Authorization"] != "Bearer abc" || endpoints[1].Headers["X-Api-Key"] != "xyz" {
+		t.Fatalf("unexpected second endpoint: %+v", endpoints[1])
+	}
+}
@@ -0,0 +1,64 @@
+package web3
+
+import (
+	"context"
+	"defibotgo/internal/config"
+	"defibotgo/internal/web3"
+	"defibotgo/internal/web3/web3test"
+	"encoding/json"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"testing"
+)
+
+var fakeRewardToken = common.HexToAddress("0x940181a94A35A4569E4529A3CDfB74e38FD98631")
+var fakeWallet = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+
+// transferLogJson returns the JSON of an ERC-20 Transfer log of amount from the lender to recipient.
+func transferLogJson(token common.Address, recipient common.Address, amount int64) string {
+	return `{"address":"` + token.Hex() + `","topics":["` + web3.TransferTopic.Hex() + `","` + common.BytesToHash(fakeLender.Bytes()).Hex() + `","` + common.BytesToHash(recipient.Bytes()).Hex() + `"],` +
+		`"data":"` + common.BigToHash(big.NewInt(amount)).Hex() + `","blockNumber":"0x64","transactionHash":"` + common.HexToHash("0x01").Hex() + `","transactionIndex":"0x0","blockHash":"` + common.HexToHash("0x02").Hex() + `","logIndex":"0x0","removed":false}`
+}
+
+func TestGetOpReceipt(t *testing.T) {
+	hash := common.HexToHash("0x01")
+	receipt := `{"transactionHash":"` + hash.Hex() + `","blockNumber":"0x64","from":"` + fakeWallet.Hex() + `","to":"` + fakeLender.Hex() + `",` +
+		`"status":"0x1","gasUsed":"0x3e8","effectiveGasPrice":"0x5","l1Fee":"0x7","logs":[` +
+		transferLogJson(fakeRewardToken, fakeWallet, 100) + `,` +
+		transferLogJson(fakeRewardToken, fakeLender, 1000) + `,` +
+		transferLogJson(fakeLender, fakeWallet, 10) + `]}`
+
+	ethService := &web3test.EthService{Head: 100, ChainID: fakeChainID, Receipts: map[common.Hash]json.RawMessage{hash: json.RawMessage(receipt)}}
+	server := web3test.NewRPCServer(map[string]interface{}{"eth": ethService})
+	defer server.Close()
+
+	pool, err := web3.NewRpcPool(context.Background(), config.ParseRpcEndpoints(server.URL), testPoolOpts)
+	if err != nil {
+		t.Fatalf("failed to build pool: %v", err)
+	}
+	defer pool.Close()
+
+	opReceipt, err := web3.GetOpReceipt(context.Background(), pool, hash)
+	if err != nil {
+		t.Fatalf("failed to get receipt: %v", err)
+	}
+
+	if opReceipt.L2Fee().Cmp(big.NewInt(5000)) != 0 {
+		t.Fatalf("unexpected l2 fee: expected %v, got %v", 5000, opReceipt.L2Fee())
+	}
+	if opReceipt.L1DataFee().Cmp(big.NewInt(7)) != 0 {
+		t.Fatalf("unexpected l1 fee: expected %v, got %v", 7, opReceipt.L1DataFee())
+	}
+	if received := opReceipt.TransfersTo(fakeWallet, fakeRewardToken); received.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("unexpected reward token received: expected %v, got %v", 100, received)
+	}
+	if received := opReceipt.TransfersTo(fakeWallet, common.Address{}); received.Cmp(big.NewInt(110)) != 0 {
+		t.Fatalf("unexpected tokens received: expected %v, got %v", 110, received)
+	}
+
+	if _, err := web3.GetOpReceipt(context.Background(), pool, common.HexToHash("0x404")); !errors.Is(err, ethereum.NotFound) {
+		t.Fatalf("unexpected error: expected %v, got %v", ethereum.NotFound, err)
+	}
+}
@@ -0,0 +1,126 @@
+package web3
+
+import (
+	"context"
+	"defibotgo/internal/config"
+	"defibotgo/internal/models"
+	"defibotgo/internal/web3"
+	"defibotgo/internal/web3/web3test"
+	"encoding/json"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+	"math/big"
+	"testing"
+)
+
+func TestGetSenderTxTypes(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	signer, err := web3.SignerForChain(models.Base)
+	if err != nil {
+		t.Fatalf("failed to get signer: %v", err)
+	}
+
+	chainID := uint256.NewInt(uint64(fakeChainID))
+	testCases := []struct {
+		name   string
+		txData types.TxData
+	}{
+		{"Legacy", &types.LegacyTx{Nonce: 0, To: &fakeLender, Gas: 21000, GasPrice: big.NewInt(1)}},
+		{"AccessList", &types.AccessListTx{ChainID: big.NewInt(fakeChainID), To: &fakeLender, Gas: 21000, GasPrice: big.NewInt(1)}},
+		{"DynamicFee", &types.DynamicFeeTx{ChainID: big.NewInt(fakeChainID), To: &fakeLender, Gas: 21000, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2)}},
+		{"Blob", &types.BlobTx{ChainID: chainID, To: fakeLender, Gas: 21000, GasTipCap: uint256.NewInt(1), GasFeeCap: uint256.NewInt(2), BlobFeeCap: uint256.NewInt(1), BlobHashes: []common.Hash{{0x01}}}},
+		{"SetCode", &types.SetCodeTx{ChainID: chainID, To: fakeLender, Gas: 21000, GasTipCap: uint256.NewInt(1), GasFeeCap: uint256.NewInt(2), AuthList: []types.SetCodeAuthorization{{ChainID: *chainID}}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tx, err := types.SignNewTx(key, signer, tc.txData)
+			if err != nil {
+				t.Fatalf("failed to sign tx: %v", err)
+			}
+
+			sender, err := web3.GetSender(signer, tx)
+			if err != nil {
+				t.Fatalf("failed to get sender: %v", err)
+			}
+			if sender != address {
+				t.Fatalf("unexpected sender: expected %v, got %v", address, sender)
+			}
+		})
+	}
+}
+
+func TestGetSenderChainIDMismatch(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	optimismSigner, _ := web3.SignerForChain(models.Optimism)
+	baseSigner, _ := web3.SignerForChain(models.Base)
+
+	tx, err := types.SignNewTx(key, optimismSigner, &types.DynamicFeeTx{ChainID: big.NewInt(10), To: &fakeLender, Gas: 21000, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2)})
+	if err != nil {
+		t.Fatalf("failed to sign tx: %v", err)
+	}
+
+	if _, err := web3.GetSender(baseSigner, tx); !errors.Is(err, web3.ErrChainIDMismatch) {
+		t.Fatalf("unexpected error: expected %v, got %v", web3.ErrChainIDMismatch, err)
+	}
+}
+
+func TestGetPriorityFeeFromLogsIgnoredTxs(t *testing.T) {
+	ourKey, _ := crypto.GenerateKey()
+	competitorKey, _ := crypto.GenerateKey()
+	ourAddress := crypto.PubkeyToAddress(ourKey.PublicKey)
+
+	ethService := &web3test.EthService{Head: 100, ChainID: fakeChainID, Transactions: map[common.Hash]json.RawMessage{}}
+	var logs []types.Log
+	addTx := func(hash common.Hash, raw []byte) {
+		ethService.Transactions[hash] = raw
+		logs = append(logs, types.Log{Address: fakeLender, TxHash: hash})
+	}
+	addSignedTx := func(tx *types.Transaction) {
+		raw, err := tx.MarshalJSON()
+		if err != nil {
+			t.Fatalf("failed to marshal tx: %v", err)
+		}
+		addTx(tx.Hash(), raw)
+	}
+
+	addSignedTx(signFakeTx(t, ourKey, 0, 900000))
+	addSignedTx(signFakeTx(t, competitorKey, 0, 154275))
+	// Signed for Optimism
+	optimismTx, _ := types.SignNewTx(competitorKey, types.LatestSignerForChainID(big.NewInt(10)), &types.DynamicFeeTx{ChainID: big.NewInt(10), To: &fakeLender, Gas: 21000, GasTipCap: big.NewInt(999999), GasFeeCap: big.NewInt(999999)})
+	addSignedTx(optimismTx)
+	// OP stack deposit transactions cannot be decoded by go-ethereum
+	depositHash := common.HexToHash("0x7e")
+	addTx(depositHash, []byte(`{"type":"0x7e","hash":"`+depositHash.Hex()+`","from":"0xdeaddeaddeaddeaddeaddeaddeaddeaddead0001","to":"`+fakeLender.Hex()+`","gas":"0xf4240","value":"0x0","input":"0x","mint":"0x0","sourceHash":"`+depositHash.Hex()+`","nonce":"0x1"}`))
+	// Unknown to the node
+	logs = append(logs, types.Log{Address: fakeLender, TxHash: common.HexToHash("0x404")})
+
+	server := web3test.NewRPCServer(map[string]interface{}{"eth": ethService})
+	defer server.Close()
+
+	pool, err := web3.NewRpcPool(context.Background(), config.ParseRpcEndpoints(server.URL), testPoolOpts)
+	if err != nil {
+		t.Fatalf("failed to build pool: %v", err)
+	}
+	defer pool.Close()
+
+	signer, _ := web3.SignerForChain(models.Base)
+	priorityFee, ignoredTxs, err := web3.GetPriorityFeeFromLogs(context.Background(), pool, signer, ourAddress, logs)
+	if err != nil {
+		t.Fatalf("failed to get priority fee: %v", err)
+	}
+
+	if priorityFee.Cmp(big.NewInt(154275)) != 0 {
+		t.Fatalf("unexpected priority fee: expected %v, got %v", 154275, priorityFee)
+	}
+
+	expected := web3.IgnoredTxs{Deposit: 1, ChainIDMismatch: 1, Unavailable: 1}
+	if ignoredTxs != expected {
+		t.Fatalf("unexpected ignored transactions: expected %+v, got %+v", expected, ignoredTxs)
+	}
+}
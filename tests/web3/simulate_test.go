@@ -0,0 +1,86 @@
+package web3
+
+import (
+	"context"
+	"defibotgo/internal/config"
+	"defibotgo/internal/web3"
+	"defibotgo/internal/web3/web3test"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// packErrorString encodes a require message as the Error(string) revert data
+func packErrorString(t *testing.T, reason string) []byte {
+	t.Helper()
+
+	stringType, _ := abi.NewType("string", "", nil)
+	data, err := abi.Arguments{{Type: stringType}}.Pack(reason)
+	if err != nil {
+		t.Fatalf("failed to pack reason: %v", err)
+	}
+	return append(crypto.Keccak256([]byte("Error(string)"))[:4], data...)
+}
+
+func TestSimulateCallRevertReason(t *testing.T) {
+	ethService := &web3test.EthService{Head: 150, ChainID: fakeChainID, CallErr: &web3test.RevertError{Data: packErrorString(t, "Tarot: INSUFFICIENT_REWARD")}}
+	server := web3test.NewRPCServer(map[string]interface{}{"eth": ethService})
+	defer server.Close()
+
+	pool, err := web3.NewRpcPool(context.Background(), config.ParseRpcEndpoints(server.URL), testPoolOpts)
+	if err != nil {
+		t.Fatalf("failed to build pool: %v", err)
+	}
+	defer pool.Close()
+
+	err = web3.SimulateCall(context.Background(), pool, ethereum.CallMsg{To: &fakeLender}, nil)
+	if !errors.Is(err, web3.ErrReverted) {
+		t.Fatalf("the simulation should revert: %v", err)
+	}
+
+	var revertErr *web3.RevertError
+	if !errors.As(err, &revertErr) || revertErr.Reason != "Tarot: INSUFFICIENT_REWARD" {
+		t.Fatalf("unexpected revert reason: %v", err)
+	}
+
+	// A successful simulation lets the transaction through
+	ethService.CallErr = nil
+	if err := web3.SimulateCall(context.Background(), pool, ethereum.CallMsg{To: &fakeLender}, nil); err != nil {
+		t.Fatalf("the simulation should succeed: %v", err)
+	}
+}
+
+func TestSimulateCallCustomError(t *testing.T) {
+	lenderAbi, err := web3.LoadAbi(`[{"type":"error","name":"NothingToReinvest","inputs":[{"name":"earned","type":"uint256"}]}]`)
+	if err != nil {
+		t.Fatalf("failed to load abi: %v", err)
+	}
+	nothingToReinvest := lenderAbi.Errors["NothingToReinvest"]
+	data, _ := nothingToReinvest.Inputs.Pack(big.NewInt(7))
+	data = append(nothingToReinvest.ID.Bytes()[:4], data...)
+
+	client := web3test.NewClient(fakeChainID)
+	client.CallContractFn = func(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+		if blockNumber == nil || blockNumber.Sign() >= 0 {
+			t.Fatalf("the simulation should run at the pending block: %v", blockNumber)
+		}
+		return nil, &web3test.RevertError{Data: data}
+	}
+
+	err = web3.SimulateCall(context.Background(), client, ethereum.CallMsg{To: &fakeLender}, &lenderAbi)
+	if !errors.Is(err, web3.ErrReverted) || !strings.Contains(err.Error(), "NothingToReinvest") {
+		t.Fatalf("the custom error should be decoded: %v", err)
+	}
+
+	// A failure of the node is not a revert
+	client.CallContractFn = func(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+		return nil, errors.New("connection refused")
+	}
+	if err := web3.SimulateCall(context.Background(), client, ethereum.CallMsg{To: &fakeLender}, &lenderAbi); err == nil || errors.Is(err, web3.ErrReverted) {
+		t.Fatalf("the node failure should not be a revert: %v", err)
+	}
+}
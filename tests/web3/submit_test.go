@@ -0,0 +1,225 @@
+package web3
+
+import (
+	"context"
+	"defibotgo/internal/config"
+	"defibotgo/internal/models"
+	"defibotgo/internal/web3"
+	"defibotgo/internal/web3/web3test"
+	"errors"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newRelayPool(t *testing.T, relay *web3test.RelayService) *web3.RpcPool {
+	t.Helper()
+
+	server := web3test.NewRPCServer(map[string]interface{}{"eth": relay})
+	t.Cleanup(server.Close)
+
+	pool, err := web3.NewRpcPool(context.Background(), config.ParseRpcEndpoints(server.URL), testPoolOpts)
+	if err != nil {
+		t.Fatalf("failed to build pool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+func TestPublicSubmitter(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	relay := &web3test.RelayService{}
+
+	submitter, err := web3.NewSubmitter("", newRelayPool(t, relay), "", nil)
+	if err != nil {
+		t.Fatalf("failed to build submitter: %v", err)
+	}
+
+	tx := signFakeTx(t, key, 0, 1000)
+	if err := submitter.Submit(context.Background(), tx, big.NewInt(101)); err != nil {
+		t.Fatalf("failed to submit: %v", err)
+	}
+
+	rawTx, _ := tx.MarshalBinary()
+	if len(relay.RawTransactions) != 1 || hexutil.Encode(relay.RawTransactions[0]) != hexutil.Encode(rawTx) {
+		t.Fatalf("the transaction should be sent with eth_sendRawTransaction: %v", relay.RawTransactions)
+	}
+}
+
+func TestBundleSubmitter(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	authKey, _ := crypto.GenerateKey()
+	relay := &web3test.RelayService{}
+
+	var captured atomic.Value
+	server := httptest.NewServer(web3test.CaptureRequest(web3test.NewRPCHandler(map[string]interface{}{"eth": relay}), web3.FlashbotsSignatureHeader, &captured))
+	defer server.Close()
+
+	submitter, err := web3.NewSubmitter(models.SubmissionBundle, web3test.NewClient(fakeChainID), server.URL, authKey)
+	if err != nil {
+		t.Fatalf("failed to build submitter: %v", err)
+	}
+
+	tx := signFakeTx(t, key, 0, 1000)
+	if err := submitter.Submit(context.Background(), tx, big.NewInt(101)); err != nil {
+		t.Fatalf("failed to submit: %v", err)
+	}
+
+	rawTx, _ := tx.MarshalBinary()
+	if len(relay.RawTransactions) != 0 {
+		t.Fatalf("the transaction should not reach the public mempool")
+	}
+	if len(relay.Bundles) != 1 || relay.Bundles[0].BlockNumber != 101 || len(relay.Bundles[0].Txs) != 1 || hexutil.Encode(relay.Bundles[0].Txs[0]) != hexutil.Encode(rawTx) {
+		t.Fatalf("unexpected bundles: %+v", relay.Bundles)
+	}
+
+	// The signature recovers to the auth key
+	request := captured.Load().(web3test.CapturedRequest)
+	address, signature, found := strings.Cut(request.Header, ":")
+	if !found {
+		t.Fatalf("malformed signature header: %q", request.Header)
+	}
+	hash := accounts.TextHash([]byte(hexutil.Encode(crypto.Keccak256(request.Body))))
+	publicKey, err := crypto.SigToPub(hash, hexutil.MustDecode(signature))
+	if err != nil {
+		t.Fatalf("failed to recover signature: %v", err)
+	}
+	if recovered := crypto.PubkeyToAddress(*publicKey); recovered.Hex() != address || recovered != crypto.PubkeyToAddress(authKey.PublicKey) {
+		t.Fatalf("the signature should recover to the auth key: header %v, recovered %v", address, recovered)
+	}
+}
+
+func TestConditionalSubmitter(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	relay := &web3test.RelayService{}
+
+	submitter, err := web3.NewSubmitter(models.SubmissionConditional, newRelayPool(t, relay), "", nil)
+	if err != nil {
+		t.Fatalf("failed to build submitter: %v", err)
+	}
+
+	if err := submitter.Submit(context.Background(), signFakeTx(t, key, 0, 1000), big.NewInt(101)); err != nil {
+		t.Fatalf("failed to submit: %v", err)
+	}
+
+	if len(relay.Conditionals) != 1 || relay.Conditionals[0].Options.BlockNumberMax == nil || *relay.Conditionals[0].Options.BlockNumberMax < 101 {
+		t.Fatalf("the transaction should be sent with a block number max from the target block: %+v", relay.Conditionals)
+	}
+}
+
+func TestUnknownSubmitter(t *testing.T) {
+	if _, err := web3.NewSubmitter("MEMPOOL", web3test.NewClient(fakeChainID), "", nil); err == nil {
+		t.Fatalf("an unknown submission should be rejected")
+	}
+}
+
+func TestBroadcastFanOut(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	accepting := &web3test.RelayService{}
+	knowing := &web3test.RelayService{SendErr: errors.New("already known")}
+
+	acceptingServer := web3test.NewRPCServer(map[string]interface{}{"eth": accepting})
+	defer acceptingServer.Close()
+	knowingServer := web3test.NewRPCServer(map[string]interface{}{"eth": knowing})
+	defer knowingServer.Close()
+	failingServer := web3test.NewFailingServer(http.StatusServiceUnavailable)
+	defer failingServer.Close()
+
+	endpoints := config.ParseRpcEndpoints(failingServer.URL + "," + knowingServer.URL + "," + acceptingServer.URL)
+	pool, err := web3.NewRpcPool(context.Background(), endpoints, testPoolOpts)
+	if err != nil {
+		t.Fatalf("failed to build pool: %v", err)
+	}
+	defer pool.Close()
+
+	report, err := pool.Broadcast(context.Background(), signFakeTx(t, key, 0, 1000))
+	if err != nil {
+		t.Fatalf("failed to broadcast: %v", err)
+	}
+	if report.First == "" {
+		t.Fatalf("the broadcast should return on the first acceptance: %+v", report)
+	}
+
+	report = <-report.Complete
+	if len(report.Acks) != 3 || report.Accepted() != 2 || report.First == "" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	for _, ack := range report.Acks {
+		if ack.Latency <= 0 {
+			t.Fatalf("every answer should have a latency: %+v", ack)
+		}
+		if ack.Url == knowingServer.URL && !ack.AlreadyKnown {
+			t.Fatalf("an already known transaction should count as accepted: %+v", ack)
+		}
+	}
+	if len(accepting.RawTransactions) != 1 {
+		t.Fatalf("the transaction should reach every endpoint")
+	}
+}
+
+func TestBroadcastSlowEndpoint(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	fast := &web3test.RelayService{}
+	slow := &web3test.RelayService{SendDelay: time.Second}
+
+	fastServer := web3test.NewRPCServer(map[string]interface{}{"eth": fast})
+	defer fastServer.Close()
+	slowServer := web3test.NewRPCServer(map[string]interface{}{"eth": slow})
+	defer slowServer.Close()
+
+	pool, err := web3.NewRpcPool(context.Background(), config.ParseRpcEndpoints(slowServer.URL+","+fastServer.URL), testPoolOpts)
+	if err != nil {
+		t.Fatalf("failed to build pool: %v", err)
+	}
+	defer pool.Close()
+
+	// The slow endpoint does not hold the broadcast, even once the context of the caller is canceled
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	start := time.Now()
+	report, err := pool.Broadcast(ctx, signFakeTx(t, key, 0, 1000))
+	elapsed := time.Since(start)
+	cancel()
+	if err != nil {
+		t.Fatalf("failed to broadcast: %v", err)
+	}
+	if elapsed >= slow.SendDelay || report.First != fastServer.URL || len(report.Acks) != 1 {
+		t.Fatalf("the broadcast should return on the first acceptance: %v, %+v", elapsed, report)
+	}
+
+	complete := <-report.Complete
+	if len(complete.Acks) != 2 || complete.Accepted() != 2 || complete.Acks[1].Latency < slow.SendDelay {
+		t.Fatalf("the slow endpoint should be reported in the background: %+v", complete)
+	}
+}
+
+func TestBroadcastRejected(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	rejecting := &web3test.RelayService{SendErr: errors.New("nonce too low")}
+
+	rejectingServer := web3test.NewRPCServer(map[string]interface{}{"eth": rejecting})
+	defer rejectingServer.Close()
+	failingServer := web3test.NewFailingServer(http.StatusServiceUnavailable)
+	defer failingServer.Close()
+
+	pool, err := web3.NewRpcPool(context.Background(), config.ParseRpcEndpoints(rejectingServer.URL+","+failingServer.URL), testPoolOpts)
+	if err != nil {
+		t.Fatalf("failed to build pool: %v", err)
+	}
+	defer pool.Close()
+
+	report, err := pool.Broadcast(context.Background(), signFakeTx(t, key, 0, 1000))
+	if !web3.IsNonceError(err) {
+		t.Fatalf("the node rejection should be returned over the transport error: %v", err)
+	}
+	if report.Accepted() != 0 || report.First != "" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
@@ -0,0 +1,108 @@
+package web3
+
+import (
+	"context"
+	"defibotgo/internal/config"
+	"defibotgo/internal/models"
+	"defibotgo/internal/web3"
+	"defibotgo/internal/web3/web3test"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func newTipService() *web3test.EthService {
+	return &web3test.EthService{
+		Head:    150,
+		ChainID: fakeChainID,
+		BaseFees: map[uint64]*big.Int{
+			148: big.NewInt(1000), 149: big.NewInt(2000), 150: big.NewInt(3000), 151: big.NewInt(3000),
+		},
+		Rewards: map[uint64]*big.Int{
+			148: big.NewInt(100), 149: big.NewInt(300), 150: big.NewInt(200),
+		},
+	}
+}
+
+func estimateTip(t *testing.T, service *web3test.EthService, kind models.TipEstimatorKind, sender common.Address) (*big.Int, int64) {
+	t.Helper()
+
+	var httpRequests atomic.Int64
+	server := httptest.NewServer(web3test.CountRequests(web3test.NewRPCHandler(map[string]interface{}{"eth": service}), &httpRequests))
+	defer server.Close()
+
+	pool, err := web3.NewRpcPool(context.Background(), config.ParseRpcEndpoints(server.URL), testPoolOpts)
+	if err != nil {
+		t.Fatalf("failed to build pool: %v", err)
+	}
+	defer pool.Close()
+
+	estimator, err := web3.NewTipEstimator(kind, types.LatestSignerForChainID(big.NewInt(fakeChainID)), sender, fakeLender, big.NewInt(2), 90)
+	if err != nil {
+		t.Fatalf("failed to build tip estimator: %v", err)
+	}
+
+	batch := web3.NewBatch()
+	resolver := estimator.Prepare(batch, big.NewInt(150))
+
+	httpRequests.Store(0)
+	if err := batch.Send(context.Background(), pool); err != nil {
+		t.Fatalf("failed to send batch: %v", err)
+	}
+
+	tip, err := resolver(context.Background(), pool)
+	if err != nil {
+		t.Fatalf("failed to estimate tip: %v", err)
+	}
+
+	return tip, httpRequests.Load()
+}
+
+func TestFeeHistoryTipEstimator(t *testing.T) {
+	tip, httpRequests := estimateTip(t, newTipService(), models.TipEstimatorFeeHistory, common.Address{})
+
+	if tip.Cmp(big.NewInt(300)) != 0 {
+		t.Fatalf("unexpected tip: expected %v, got %v", 300, tip)
+	}
+	if httpRequests != 1 {
+		t.Fatalf("the estimation should cost one HTTP request, got %v", httpRequests)
+	}
+}
+
+func TestBlockReceiptsTipEstimator(t *testing.T) {
+	ourAddress := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	competitor := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	otherContract := common.HexToAddress("0x00000000000000000000000000000000000000cc")
+
+	receipt := func(from common.Address, to common.Address, effectiveGasPrice int64) *web3.ReceiptSummary {
+		return &web3.ReceiptSummary{From: from, To: &to, EffectiveGasPrice: (*hexutil.Big)(big.NewInt(effectiveGasPrice))}
+	}
+
+	service := newTipService()
+	service.BlockReceipts = map[uint64][]*web3.ReceiptSummary{
+		148: {receipt(competitor, fakeLender, 1400)},
+		149: {receipt(competitor, fakeLender, 2500), receipt(ourAddress, fakeLender, 2900), receipt(competitor, otherContract, 5000)},
+		150: {receipt(competitor, fakeLender, 3100)},
+		// Out of the block range
+		147: {receipt(competitor, fakeLender, 99999)},
+	}
+
+	tip, httpRequests := estimateTip(t, service, models.TipEstimatorBlockReceipts, ourAddress)
+
+	if tip.Cmp(big.NewInt(500)) != 0 {
+		t.Fatalf("unexpected tip: expected %v, got %v", 500, tip)
+	}
+	if httpRequests != 1 {
+		t.Fatalf("the estimation should cost one HTTP request, got %v", httpRequests)
+	}
+}
+
+func TestNewTipEstimatorUnknown(t *testing.T) {
+	if _, err := web3.NewTipEstimator("MEMPOOL", types.LatestSignerForChainID(big.NewInt(fakeChainID)), common.Address{}, fakeLender, big.NewInt(2), 0); err == nil {
+		t.Fatalf("an unknown estimator should be rejected")
+	}
+}